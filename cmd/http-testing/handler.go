@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+
+	"github.com/pinkgorilla/go-sample/pkg/http/server"
 )
 
 func HTTPHandlerString(w http.ResponseWriter, r *http.Request) {
@@ -11,7 +13,8 @@ func HTTPHandlerString(w http.ResponseWriter, r *http.Request) {
 	service := NewService()
 	str, err := service.String()
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		server.WriteError(w, r, err)
+		return
 	}
 	w.Write([]byte(str))
 }
@@ -21,7 +24,8 @@ func HTTPHandlerJSON(w http.ResponseWriter, r *http.Request) {
 	service := NewService()
 	data, err := service.Data()
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		server.WriteError(w, r, err)
+		return
 	}
 	json.NewEncoder(w).Encode(data)
 }
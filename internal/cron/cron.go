@@ -6,8 +6,10 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/pinkgorilla/go-sample/pkg/errors"
 	"github.com/pinkgorilla/go-sample/pkg/http/client"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -21,8 +23,11 @@ const (
 )
 
 var (
-	OffsetNow         = func() time.Time { return time.Now() }
-	ErrorInvalidJobID = fmt.Errorf("%s", "invalid job id")
+	OffsetNow = func() time.Time { return time.Now() }
+	// ErrorInvalidJobID is returned for malformed job id, empty or surrounded by spaces
+	ErrorInvalidJobID = errors.NewValidationError("invalid job id")
+	// ErrorJobNotFound is returned for well-formed id of job not registered in manager
+	ErrorJobNotFound = errors.NewNotFoundError("job not found")
 )
 
 type Config struct {
@@ -305,11 +310,23 @@ func (m *Manager) StopAll() {
 	}
 }
 
-// Stop stops job with specified ID
-func (m *Manager) Stop(ID string) error {
+// entry returns entry of job with specified ID
+func (m *Manager) entry(ID string) (*Entry, error) {
+	if ID == "" || strings.TrimSpace(ID) != ID {
+		return nil, ErrorInvalidJobID
+	}
 	e, ok := m.Entries[ID]
 	if !ok {
-		return ErrorInvalidJobID
+		return nil, ErrorJobNotFound
+	}
+	return e, nil
+}
+
+// Stop stops job with specified ID
+func (m *Manager) Stop(ID string) error {
+	e, err := m.entry(ID)
+	if err != nil {
+		return err
 	}
 	e.Stop()
 	return nil
@@ -317,9 +334,9 @@ func (m *Manager) Stop(ID string) error {
 
 // Start starts job with specified ID
 func (m *Manager) Start(ID string) error {
-	e, ok := m.Entries[ID]
-	if !ok {
-		return ErrorInvalidJobID
+	e, err := m.entry(ID)
+	if err != nil {
+		return err
 	}
 	e.Start(m.ctx)
 	return nil
@@ -327,9 +344,9 @@ func (m *Manager) Start(ID string) error {
 
 // Info returns job information with specified ID
 func (m *Manager) Info(ID string) (*Info, error) {
-	e, ok := m.Entries[ID]
-	if !ok {
-		return nil, ErrorInvalidJobID
+	e, err := m.entry(ID)
+	if err != nil {
+		return nil, err
 	}
 	info := e.Info()
 	return &info, nil
@@ -2,6 +2,7 @@ package cron_test
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"github.com/go-chi/chi"
 
 	"github.com/pinkgorilla/go-sample/internal/cron"
+	"github.com/pinkgorilla/go-sample/pkg/http/server"
 	"github.com/pinkgorilla/go-sample/pkg/http/server/middlewares"
 )
 
@@ -122,6 +124,19 @@ func Test_Manager(t *testing.T) {
 	log.Println(info)
 }
 
+func Test_Manager_UnknownJob(t *testing.T) {
+	manager := cron.NewManager(context.Background(), &cron.Config{})
+	if _, err := manager.Info("unknown"); !errors.Is(err, cron.ErrorJobNotFound) {
+		t.Fatalf("expected ErrorJobNotFound, got %v", err)
+	}
+	if err := manager.Start(" "); !errors.Is(err, cron.ErrorInvalidJobID) {
+		t.Fatalf("expected ErrorInvalidJobID, got %v", err)
+	}
+	if status := server.StatusOf(cron.ErrorInvalidJobID); status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected malformed id reported as validation error, got %v", status)
+	}
+}
+
 func getAPITarget(addr string) *httptest.Server {
 	auth := middlewares.AuthMiddleware(middlewares.StaticKeyAuthorizeFn("private-key"))
 	r := chi.NewRouter()
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/pinkgorilla/go-sample/pkg/http/server"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/go-chi/chi"
@@ -28,7 +29,7 @@ func (s *Server) HandlerFuncJobsInfoByID(w http.ResponseWriter, r *http.Request)
 	id := chi.URLParam(r, "id")
 	info, err := s.manager.Info(id)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		server.WriteError(w, r, err)
 		return
 	}
 	json.NewEncoder(w).Encode(info)
@@ -63,7 +64,7 @@ func GetHandler(m *Manager) http.Handler {
 		manager := ManagerFromContext(r.Context())
 		info, err := manager.Info(id)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			server.WriteError(w, r, err)
 			return
 		}
 		json.NewEncoder(w).Encode(info)
@@ -74,7 +75,7 @@ func GetHandler(m *Manager) http.Handler {
 		manager := ManagerFromContext(r.Context())
 		err := manager.Start(id)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			server.WriteError(w, r, err)
 			return
 		}
 	}))
@@ -84,7 +85,7 @@ func GetHandler(m *Manager) http.Handler {
 		manager := ManagerFromContext(r.Context())
 		err := manager.Stop(id)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			server.WriteError(w, r, err)
 			return
 		}
 	}))
@@ -0,0 +1,35 @@
+package circuit
+
+import (
+	"fmt"
+	"log"
+)
+
+// Alerter sends alert of error, alert.Alert implements Alerter
+type Alerter interface {
+	Error(err error) error
+}
+
+// StateChangeError describes circuit breaker state change sent through Alerter
+type StateChangeError struct {
+	Name string
+	From State
+	To   State
+}
+
+func (e StateChangeError) Error() string {
+	return fmt.Sprintf("circuit breaker '%s' changed state from %s to %s", e.Name, e.From, e.To)
+}
+
+// AlertStateChange returns StateChangeFunc sending StateChangeError through a,
+// alert is sent in a new goroutine so slow alert channel does not delay invocations
+func AlertStateChange(a Alerter) StateChangeFunc {
+	return func(name string, from, to State) {
+		go func() {
+			err := a.Error(StateChangeError{Name: name, From: from, To: to})
+			if err != nil {
+				log.Println("circuit: failed to send state change alert", err)
+			}
+		}()
+	}
+}
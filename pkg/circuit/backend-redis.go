@@ -0,0 +1,102 @@
+package circuit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// redisAddScript adds outcomes to hash of bucketed counts, removes buckets older than the window
+// and returns pooled counts, fields are t:<bucket> for total and f:<bucket> for failures
+var redisAddScript = redis.NewScript(`
+local bucket, total, failures, oldest = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+if total > 0 then
+	redis.call('HINCRBY', KEYS[1], 't:' .. ARGV[1], total)
+	redis.call('HINCRBY', KEYS[1], 'f:' .. ARGV[1], failures)
+	redis.call('PEXPIRE', KEYS[1], ARGV[5])
+end
+local fields = redis.call('HGETALL', KEYS[1])
+local t, f = 0, 0
+for i = 1, #fields, 2 do
+	if tonumber(string.sub(fields[i], 3)) < oldest then
+		redis.call('HDEL', KEYS[1], fields[i])
+	elseif string.sub(fields[i], 1, 1) == 't' then
+		t = t + tonumber(fields[i + 1])
+	else
+		f = f + tonumber(fields[i + 1])
+	end
+end
+return {t, f}
+`)
+
+// RedisBackend is Backend keeping shared state in redis
+type RedisBackend struct {
+	prefix string
+	r      *redis.Client
+}
+
+// NewRedisBackend returns new RedisBackend, keys are prefixed with prefix
+func NewRedisBackend(r *redis.Client, prefix string) *RedisBackend {
+	return &RedisBackend{
+		prefix: prefix,
+		r:      r,
+	}
+}
+
+func (b *RedisBackend) key(name, kind string) string {
+	return fmt.Sprintf("%s:%s:%s", b.prefix, name, kind)
+}
+
+// Add implements Backend
+func (b *RedisBackend) Add(ctx context.Context, name string, total, failures int, window time.Duration) (int, int, error) {
+	width := window / windowBuckets
+	if width <= 0 {
+		width = 1
+	}
+	bucket := time.Now().UnixNano() / int64(width)
+	res, err := redisAddScript.Run(b.r.WithContext(ctx), []string{b.key(name, "outcomes")},
+		bucket, total, failures, bucket-windowBuckets+1, window.Milliseconds()+1).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	pooled, ok := res.([]interface{})
+	if !ok || len(pooled) != 2 {
+		return 0, 0, fmt.Errorf("circuit: unexpected redis result %v", res)
+	}
+	pooledTotal, _ := pooled[0].(int64)
+	pooledFailures, _ := pooled[1].(int64)
+	return int(pooledTotal), int(pooledFailures), nil
+}
+
+// Load implements Backend
+func (b *RedisBackend) Load(ctx context.Context, name string) (SharedState, error) {
+	var state SharedState
+	bs, err := b.r.WithContext(ctx).Get(b.key(name, "state")).Bytes()
+	if err == redis.Nil {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(bs, &state)
+	return state, err
+}
+
+// Save implements Backend
+func (b *RedisBackend) Save(ctx context.Context, name string, state SharedState) error {
+	bs, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = b.r.WithContext(ctx).TxPipelined(func(p redis.Pipeliner) error {
+		p.Set(b.key(name, "state"), bs, 0)
+		if state.State == StateClosed {
+			p.Del(b.key(name, "outcomes"))
+		}
+		return nil
+	})
+	return err
+}
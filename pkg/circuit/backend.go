@@ -0,0 +1,258 @@
+package circuit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// SharedState is circuit breaker state shared between instances
+type SharedState struct {
+	State State     `json:"state"`
+	Since time.Time `json:"since"`
+}
+
+// Backend keeps circuit breaker state and invocation outcomes shared between instances, e.g. Redis
+type Backend interface {
+	// Add adds total invocations of which failures failed to outcomes of circuit breaker named name
+	// and returns outcomes pooled from all instances over the last window
+	Add(ctx context.Context, name string, total, failures int, window time.Duration) (pooledTotal, pooledFailures int, err error)
+	// Load returns shared state of circuit breaker named name, zero SharedState when not saved yet
+	Load(ctx context.Context, name string) (SharedState, error)
+	// Save saves shared state of circuit breaker named name, pooled outcomes are cleared when state is closed
+	Save(ctx context.Context, name string, state SharedState) error
+}
+
+// SharedConfig is configuration of circuit breaker sharing state through Backend.
+//
+// Outcomes are counted locally and added to Backend every SyncInterval, circuit opens when
+// ratio of failures pooled from all instances over Window reaches FailureRatio,
+// with at least MinRequests pooled invocations. State changes to open and closed are saved to Backend in background
+// and adopted by other instances on their next sync
+type SharedConfig struct {
+	Backend      Backend
+	SyncInterval time.Duration
+	Window       time.Duration
+	FailureRatio float64
+	MinRequests  int
+}
+
+// DefaultSharedConfig returns default shared config syncing with backend every second,
+// circuit opens when half of at least 20 invocations over the last minute failed
+func DefaultSharedConfig(backend Backend) *SharedConfig {
+	return &SharedConfig{
+		Backend:      backend,
+		SyncInterval: time.Second,
+		Window:       time.Minute,
+		FailureRatio: 0.5,
+		MinRequests:  20,
+	}
+}
+
+// counts is number of invocations not added to Backend yet
+type counts struct {
+	total    int
+	failures int
+}
+
+// count counts invocation outcome to be added to Backend, it must be called with lock held
+func (c *CircuitBreaker) count(failure bool) {
+	if c.Shared == nil {
+		return
+	}
+	c.pending.total++
+	if failure {
+		c.pending.failures++
+	}
+}
+
+// shouldSync returns true when background sync is due, it must be called with lock held
+func (c *CircuitBreaker) shouldSync(now time.Time) bool {
+	if c.Shared == nil || c.Name == "" || c.syncing || now.Sub(c.syncedAt) < c.Shared.SyncInterval {
+		return false
+	}
+	c.syncing = true
+	c.syncedAt = now
+	return true
+}
+
+func (c *CircuitBreaker) backgroundSync() {
+	ctx, cancel := c.withTimeout(context.Background())
+	defer cancel()
+	c.Sync(ctx)
+	c.finishSync(ctx)
+}
+
+// backgroundSave saves state changes recorded by invocations without waiting for sync interval
+func (c *CircuitBreaker) backgroundSave() {
+	ctx, cancel := c.withTimeout(context.Background())
+	defer cancel()
+	c.finishSync(ctx)
+}
+
+// finishSync saves state changes recorded while syncing and releases syncing flag,
+// state changes failed to be saved are kept for next sync
+func (c *CircuitBreaker) finishSync(ctx context.Context) {
+	for {
+		err := c.saveUnsaved(ctx)
+		c.mu.Lock()
+		if err != nil || c.unsaved == nil {
+			c.syncing = false
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+	}
+}
+
+// saveUnsaved saves latest state change not saved to Backend yet
+func (c *CircuitBreaker) saveUnsaved(ctx context.Context) error {
+	c.mu.Lock()
+	unsaved := c.unsaved
+	c.unsaved = nil
+	c.mu.Unlock()
+	if unsaved == nil {
+		return nil
+	}
+	err := c.save(ctx, *unsaved)
+	if err != nil {
+		c.mu.Lock()
+		// newer state change supersedes the failed one
+		if c.unsaved == nil {
+			c.unsaved = unsaved
+		}
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// save saves shared state to Backend, failure is logged and counted
+func (c *CircuitBreaker) save(ctx context.Context, state SharedState) error {
+	err := c.Shared.Backend.Save(ctx, c.Name, state)
+	if err != nil {
+		log.Println("circuit: failed to save shared state of", c.Name, err)
+		registerMetrics()
+		backendErrorsTotal.WithLabelValues(c.Name, "save").Inc()
+	}
+	return err
+}
+
+// Sync adds outcomes counted since last sync to Backend and adopts shared state,
+// it is called in background every SyncInterval by invocations. Circuit breaker without Shared or Name is not synced
+func (c *CircuitBreaker) Sync(ctx context.Context) error {
+	if c.Shared == nil || c.Name == "" {
+		return nil
+	}
+	// own state change is saved before shared state is loaded so it is not overridden
+	if err := c.saveUnsaved(ctx); err != nil {
+		return err
+	}
+	backend := c.Shared.Backend
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = counts{}
+	c.mu.Unlock()
+
+	total, failures, err := backend.Add(ctx, c.Name, pending.total, pending.failures, c.Shared.Window)
+	if err != nil {
+		c.mu.Lock()
+		c.pending.total += pending.total
+		c.pending.failures += pending.failures
+		c.mu.Unlock()
+		return err
+	}
+	shared, err := backend.Load(ctx, c.Name)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	state, changed := c.current(now)
+	var adopted, tripped *transition
+	switch {
+	case c.forced != "":
+		// state is not changed while forced
+	case shared.State == StateOpen && state != StateOpen && shared.Since.After(c.changedAt):
+		c.openedAt = shared.Since
+		adopted = c.change(StateOpen)
+	case shared.State == StateClosed && state != StateClosed && shared.Since.After(c.openedAt):
+		c.Strategy.Reset()
+		adopted = c.change(StateClosed)
+	case state == StateClosed && tripRatio(total, failures, c.Shared.MinRequests, c.Shared.FailureRatio):
+		tripped = c.open(now)
+	}
+	c.mu.Unlock()
+	c.notify(changed)
+	c.notify(adopted)
+	c.notify(tripped)
+	if tripped != nil {
+		return c.save(ctx, SharedState{State: StateOpen, Since: now})
+	}
+	return nil
+}
+
+// publish queues state change to open or closed to be saved to Backend in background,
+// it must be called with lock held and returns true when caller must start backgroundSave
+func (c *CircuitBreaker) publish(t *transition, now time.Time) bool {
+	if t == nil || c.Shared == nil || c.Name == "" || t.to == StateHalfOpen {
+		return false
+	}
+	c.unsaved = &SharedState{State: t.to, Since: now}
+	if c.syncing {
+		// running sync saves it when finished
+		return false
+	}
+	c.syncing = true
+	return true
+}
+
+// MemoryBackend is Backend keeping shared state in memory, it shares state between
+// circuit breakers of a process, e.g. in tests
+type MemoryBackend struct {
+	states  map[string]SharedState
+	windows map[string]*rollingWindow
+	mu      sync.Mutex
+}
+
+// NewMemoryBackend returns new MemoryBackend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		states:  map[string]SharedState{},
+		windows: map[string]*rollingWindow{},
+	}
+}
+
+// Add implements Backend
+func (b *MemoryBackend) Add(ctx context.Context, name string, total, failures int, window time.Duration) (int, int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w, ok := b.windows[name]
+	if !ok {
+		w = newRollingWindow(window)
+		b.windows[name] = w
+	}
+	now := time.Now()
+	w.addN(now, total, failures)
+	pooledTotal, pooledFailures := w.counts(now)
+	return pooledTotal, pooledFailures, nil
+}
+
+// Load implements Backend
+func (b *MemoryBackend) Load(ctx context.Context, name string) (SharedState, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.states[name], nil
+}
+
+// Save implements Backend
+func (b *MemoryBackend) Save(ctx context.Context, name string, state SharedState) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.states[name] = state
+	if w, ok := b.windows[name]; ok && state.State == StateClosed {
+		w.reset()
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package circuit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pinkgorilla/go-sample/pkg/circuit"
+)
+
+func backends(t *testing.T) map[string]circuit.Backend {
+	r := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	t.Cleanup(func() { r.Close() })
+	return map[string]circuit.Backend{
+		"memory": circuit.NewMemoryBackend(),
+		"redis":  circuit.NewRedisBackend(r, fmt.Sprintf("circuit-test-%d", time.Now().UnixNano())),
+	}
+}
+
+func newSharedBreaker(backend circuit.Backend) *circuit.CircuitBreaker {
+	config := circuit.DefaultConfig()
+	config.Name = "shared"
+	config.ResetTimeout = 50 * time.Millisecond
+	config.Strategy = circuit.ConsecutiveFailures(100)
+	config.Shared = circuit.DefaultSharedConfig(backend)
+	config.Shared.SyncInterval = time.Hour
+	config.Shared.MinRequests = 4
+	return circuit.NewCircuitBreakerWithConfig(config)
+}
+
+func Test_Backend(t *testing.T) {
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			state, err := backend.Load(ctx, "b")
+			if err != nil || state.State != "" {
+				t.Fatalf("expected empty state, got %v %v", state, err)
+			}
+			backend.Add(ctx, "b", 3, 1, time.Minute)
+			total, failures, err := backend.Add(ctx, "b", 2, 2, time.Minute)
+			if err != nil || total != 5 || failures != 3 {
+				t.Fatalf("expected 5 total and 3 failures, got %v %v %v", total, failures, err)
+			}
+
+			since := time.Now().Truncate(time.Millisecond)
+			if err := backend.Save(ctx, "b", circuit.SharedState{State: circuit.StateOpen, Since: since}); err != nil {
+				t.Fatal(err)
+			}
+			state, _ = backend.Load(ctx, "b")
+			if state.State != circuit.StateOpen || !state.Since.Equal(since) {
+				t.Fatalf("expected open since %v, got %v", since, state)
+			}
+
+			backend.Save(ctx, "b", circuit.SharedState{State: circuit.StateClosed, Since: time.Now()})
+			total, _, _ = backend.Add(ctx, "b", 0, 0, time.Minute)
+			if total != 0 {
+				t.Fatalf("expected outcomes cleared on close, got %v", total)
+			}
+		})
+	}
+}
+
+func Test_Backend_Window(t *testing.T) {
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			window := 100 * time.Millisecond
+			backend.Add(ctx, "w", 5, 5, window)
+			time.Sleep(2 * window)
+			total, failures, _ := backend.Add(ctx, "w", 1, 0, window)
+			if total != 1 || failures != 0 {
+				t.Fatalf("expected outcomes out of window discarded, got %v %v", total, failures)
+			}
+		})
+	}
+}
+
+func Test_Circuit_Shared(t *testing.T) {
+	failing := func() error { return errors.New("failed") }
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			cb1, cb2 := newSharedBreaker(backend), newSharedBreaker(backend)
+
+			// failures pooled from both instances trip the circuit
+			cb1.Invoke(ctx, failing)
+			cb1.Invoke(ctx, failing)
+			if err := cb1.Sync(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if cb1.State() != circuit.StateClosed {
+				t.Fatal("expected closed state below MinRequests")
+			}
+			cb2.Invoke(ctx, failing)
+			cb2.Invoke(ctx, failing)
+			if err := cb2.Sync(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if cb2.State() != circuit.StateOpen {
+				t.Fatalf("expected open state from pooled failures, got %s", cb2.State())
+			}
+
+			// open state is adopted by other instance
+			cb1.Sync(ctx)
+			if cb1.State() != circuit.StateOpen {
+				t.Fatalf("expected adopted open state, got %s", cb1.State())
+			}
+
+			// closed state after successful probe is adopted by other instance
+			time.Sleep(60 * time.Millisecond)
+			if err := cb1.Invoke(ctx, func() error { return nil }); err != nil {
+				t.Fatal(err)
+			}
+			if cb1.State() != circuit.StateClosed {
+				t.Fatalf("expected closed state, got %s", cb1.State())
+			}
+			// closed state is saved in background
+			deadline := time.Now().Add(time.Second)
+			for cb2.Sync(ctx); cb2.State() != circuit.StateClosed; cb2.Sync(ctx) {
+				if time.Now().After(deadline) {
+					t.Fatalf("expected adopted closed state, got %s", cb2.State())
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
+
+// failingBackend fails saving state while fail is set
+type failingBackend struct {
+	*circuit.MemoryBackend
+	fail  bool
+	saves int
+	mu    sync.Mutex
+}
+
+func (b *failingBackend) Save(ctx context.Context, name string, state circuit.SharedState) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.saves++
+	if b.fail {
+		return errors.New("backend unavailable")
+	}
+	return b.MemoryBackend.Save(ctx, name, state)
+}
+
+func Test_Circuit_Shared_SaveInBackground(t *testing.T) {
+	ctx := context.Background()
+	backend := &failingBackend{MemoryBackend: circuit.NewMemoryBackend(), fail: true}
+	config := circuit.DefaultConfig()
+	config.Name = "shared"
+	config.Strategy = circuit.ConsecutiveFailures(1)
+	config.Shared = circuit.DefaultSharedConfig(backend)
+	config.Shared.SyncInterval = time.Hour
+	cb := circuit.NewCircuitBreakerWithConfig(config)
+	cb.Invoke(ctx, func() error { return errors.New("failed") })
+	if cb.State() != circuit.StateOpen {
+		t.Fatal("expected open state regardless of backend")
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		backend.mu.Lock()
+		saves := backend.saves
+		backend.mu.Unlock()
+		if saves > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected state saved in background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// failed save is retried on next sync
+	backend.mu.Lock()
+	backend.fail = false
+	backend.mu.Unlock()
+	deadline = time.Now().Add(time.Second)
+	for {
+		cb.Sync(ctx)
+		if state, _ := backend.Load(ctx, "shared"); state.State == circuit.StateOpen {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected open state saved on sync")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func Test_Circuit_Shared_BackgroundSync(t *testing.T) {
+	backend := circuit.NewMemoryBackend()
+	ctx := context.Background()
+	backend.Save(ctx, "shared", circuit.SharedState{State: circuit.StateOpen, Since: time.Now()})
+
+	cb := newSharedBreaker(backend)
+	cb.Shared.SyncInterval = 0
+	deadline := time.Now().Add(time.Second)
+	for cb.Invoke(ctx, func() error { return nil }) != circuit.ErrCircuitOpen {
+		if time.Now().After(deadline) {
+			t.Fatal("expected shared open state adopted by background sync")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -3,6 +3,7 @@ package circuit
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -19,17 +20,69 @@ const (
 	StateHalfOpen = "halfopen"
 )
 
-// CircuitBreaker is implementation of circuit breaker pattern
+// StateChangeFunc is called after circuit breaker named name changes state
+type StateChangeFunc func(name string, from, to State)
+
+// CircuitBreaker is implementation of circuit breaker pattern, it is safe for concurrent use.
+// Exported fields must not be changed after the first Invoke
 type CircuitBreaker struct {
+	// Name identifies circuit breaker in state change callbacks and prometheus metrics,
+	// metrics are not reported when empty
+	Name string
 	// FailTreshold is failure limit until circuit state changed to open
 	FailTreshold int
-	// InvocationTimeout is allowed duration of Invoke method
+	// InvocationTimeout is allowed duration of Invoke method, invocation is not limited when 0
 	InvocationTimeout time.Duration
 	// ResetTimeout is minimum wait duration until circuit will try to call Invoke
 	ResetTimeout time.Duration
+	// Strategy decides when circuit opens, it is set by constructors,
+	// NewCircuitBreaker trips the circuit after consecutive FailTreshold failures
+	Strategy TripStrategy
+	// HalfOpenProbes is number of successful invocations in half open state required to close the circuit,
+	// at most HalfOpenProbes invocations are allowed in total each time circuit is half open. Defaults to 1
+	HalfOpenProbes int
+	// IsFailure classifies errors returned by invoked function, every error is a failure when nil
+	IsFailure FailureClassifier
+	// Shared shares state with circuit breakers of the same Name in other instances, state is local when nil
+	Shared *SharedConfig
+
+	state        State
+	openedAt     time.Time
+	changedAt    time.Time
+	probes       int
+	probeSuccess int
+	forced       State
+	pending      counts
+	syncedAt     time.Time
+	syncing      bool
+	unsaved      *SharedState
+	callbacks    []StateChangeFunc
+	mu           sync.Mutex
+}
 
-	failCount   int
-	lastFailure time.Time
+// Config is circuit breaker configuration,
+// Strategy keeps invocation outcomes so it must not be shared between circuit breakers
+type Config struct {
+	Name              string
+	InvocationTimeout time.Duration
+	ResetTimeout      time.Duration
+	Strategy          TripStrategy
+	HalfOpenProbes    int
+	IsFailure         FailureClassifier
+	Shared            *SharedConfig
+	// Alert is sent every state change when not nil, alert.Alert implements Alerter
+	Alert Alerter
+}
+
+// DefaultConfig returns default circuit breaker config,
+// circuit opens after 5 consecutive failures
+func DefaultConfig() *Config {
+	return &Config{
+		InvocationTimeout: 5 * time.Second,
+		ResetTimeout:      30 * time.Second,
+		Strategy:          ConsecutiveFailures(5),
+		HalfOpenProbes:    1,
+	}
 }
 
 // NewCircuitBreaker returns new circuit breaker
@@ -38,57 +91,318 @@ func NewCircuitBreaker(failTreshold int, invocationTimeout, resetTimeout time.Du
 		FailTreshold:      failTreshold,
 		InvocationTimeout: invocationTimeout,
 		ResetTimeout:      resetTimeout,
+		Strategy:          ConsecutiveFailures(failTreshold),
 	}
 }
 
-// Invoke invokes fn, error returned by fn is considered as failure
+// NewCircuitBreakerWithConfig returns new circuit breaker with specified config,
+// zero timeouts, strategy and probes are taken from DefaultConfig
+func NewCircuitBreakerWithConfig(config *Config) *CircuitBreaker {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	c := &CircuitBreaker{
+		Name:              config.Name,
+		InvocationTimeout: config.InvocationTimeout,
+		ResetTimeout:      config.ResetTimeout,
+		Strategy:          config.Strategy,
+		HalfOpenProbes:    config.HalfOpenProbes,
+		IsFailure:         config.IsFailure,
+		Shared:            config.Shared,
+	}
+	defaults := DefaultConfig()
+	if c.InvocationTimeout <= 0 {
+		c.InvocationTimeout = defaults.InvocationTimeout
+	}
+	if c.ResetTimeout <= 0 {
+		c.ResetTimeout = defaults.ResetTimeout
+	}
+	if c.Strategy == nil {
+		c.Strategy = defaults.Strategy
+	}
+	if c.HalfOpenProbes < 1 {
+		c.HalfOpenProbes = defaults.HalfOpenProbes
+	}
+	if config.Name != "" {
+		registerMetrics()
+		stateGauge.WithLabelValues(config.Name).Set(stateValue(StateClosed))
+	}
+	if config.Alert != nil {
+		c.OnStateChange(AlertStateChange(config.Alert))
+	}
+	return c
+}
+
+// OnStateChange registers fn called after every state change,
+// fn is called synchronously by the goroutine causing the change, outside of circuit breaker lock
+func (c *CircuitBreaker) OnStateChange(fn StateChangeFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.callbacks = append(c.callbacks, fn)
+}
+
+// Invoke invokes fn, error returned by fn is considered as failure.
+// fn keeps running after InvocationTimeout, use Execute or Do to have it cancelled
 func (c *CircuitBreaker) Invoke(ctx context.Context, fn func() error) error {
-	switch c.State() {
-	case StateOpen:
-		return ErrCircuitOpen
-	case StateHalfOpen:
-		fallthrough
-	case StateClosed:
-		ch := make(chan error, 1)
-		go func() {
-			ch <- fn()
-		}()
-		timeout, cancel := context.WithTimeout(ctx, c.InvocationTimeout)
-		defer cancel()
-		defer close(ch)
-
-		select {
-		case <-timeout.Done():
-			c.recordFailure()
-			return ErrCircuitInvocationTimeoutExceeded
-		case e := <-ch:
-			if e != nil {
-				c.recordFailure()
-				return fmt.Errorf("circuit error: %v", e)
-			}
-			c.reset()
+	err := c.Execute(ctx, func(context.Context) error { return fn() })
+	if err != nil && err != ErrCircuitOpen && err != ErrCircuitInvocationTimeoutExceeded {
+		return fmt.Errorf("circuit error: %v", err)
+	}
+	return err
+}
+
+// Execute implements Policy, fn is invoked with context cancelled after InvocationTimeout
+// and its error is returned as is
+func (c *CircuitBreaker) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, err := c.Do(ctx, func(ctx context.Context) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
+
+type result struct {
+	value interface{}
+	err   error
+}
+
+// Do invokes fn with context cancelled after InvocationTimeout and returns its value and error as is.
+// Error is recorded as failure when IsFailure returns true for it
+func (c *CircuitBreaker) Do(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if !c.allow() {
+		c.observe(resultRejected)
+		return nil, ErrCircuitOpen
+	}
+	start := time.Now()
+	timeout, cancel := c.withTimeout(ctx)
+	defer cancel()
+	// buffered so fn finishing after timeout does not block
+	ch := make(chan result, 1)
+	go func() {
+		v, err := fn(timeout)
+		ch <- result{v, err}
+	}()
+
+	select {
+	case <-timeout.Done():
+		c.record(true, time.Since(start))
+		c.observe(resultTimeout)
+		return nil, ErrCircuitInvocationTimeoutExceeded
+	case r := <-ch:
+		if r.err != nil && c.isFailure(r.err) {
+			c.record(true, time.Since(start))
+			c.observe(resultFailure)
+			return r.value, r.err
 		}
+		c.record(false, time.Since(start))
+		c.observe(resultSuccess)
+		return r.value, r.err
 	}
-	return nil
+}
+
+// withTimeout returns ctx cancelled after InvocationTimeout, it is only cancelled with ctx when InvocationTimeout is 0
+func (c *CircuitBreaker) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.InvocationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.InvocationTimeout)
+}
+
+func (c *CircuitBreaker) isFailure(err error) bool {
+	if c.IsFailure == nil {
+		return true
+	}
+	return c.IsFailure(err)
 }
 
 // State returns circuit state
 func (c *CircuitBreaker) State() State {
+	c.mu.Lock()
+	state, changed := c.current(time.Now())
+	c.mu.Unlock()
+	c.notify(changed)
+	return state
+}
+
+// transition is a state change notified after circuit breaker lock is released
+type transition struct {
+	from, to  State
+	callbacks []StateChangeFunc
+}
+
+// current returns current state changing open state to half open after ResetTimeout,
+// it must be called with lock held
+func (c *CircuitBreaker) current(now time.Time) (State, *transition) {
+	if c.state == "" {
+		c.state = StateClosed
+	}
+	if c.forced != "" {
+		return c.forced, nil
+	}
+	if c.state == StateOpen && now.Sub(c.openedAt) > c.ResetTimeout {
+		c.probes = 0
+		c.probeSuccess = 0
+		return StateHalfOpen, c.change(StateHalfOpen)
+	}
+	return c.state, nil
+}
+
+// change changes state and returns the transition, it must be called with lock held
+func (c *CircuitBreaker) change(to State) *transition {
+	from := c.state
+	c.state = to
+	c.changedAt = time.Now()
+	if c.Name != "" {
+		registerMetrics()
+		stateGauge.WithLabelValues(c.Name).Set(stateValue(to))
+		transitionsTotal.WithLabelValues(c.Name, string(from), string(to)).Inc()
+	}
+	return &transition{from: from, to: to, callbacks: c.callbacks}
+}
+
+func (c *CircuitBreaker) notify(t *transition) {
+	if t == nil {
+		return
+	}
+	for _, fn := range t.callbacks {
+		fn(c.Name, t.from, t.to)
+	}
+}
+
+// allow returns true when invocation is allowed in current state,
+// in half open state only HalfOpenProbes invocations are allowed until circuit closes or opens again
+func (c *CircuitBreaker) allow() bool {
+	c.mu.Lock()
+	state, changed := c.current(time.Now())
+	allowed := true
+	switch state {
+	case StateOpen:
+		allowed = false
+	case StateHalfOpen:
+		if c.probes >= c.halfOpenProbes() {
+			allowed = false
+		} else {
+			c.probes++
+		}
+	}
+	sync := c.shouldSync(time.Now())
+	c.mu.Unlock()
+	c.notify(changed)
+	if sync {
+		go c.backgroundSync()
+	}
+	return allowed
+}
+
+// record records invocation outcome and changes circuit state accordingly
+func (c *CircuitBreaker) record(failure bool, d time.Duration) {
+	now := time.Now()
+	c.mu.Lock()
+	var changed *transition
 	switch {
-	case c.failCount >= c.FailTreshold &&
-		time.Now().Sub(c.lastFailure) > c.ResetTimeout:
-		return StateHalfOpen
-	case c.failCount >= c.FailTreshold:
-		return StateOpen
+	case c.forced != "":
+		// state is not changed while forced
+		c.count(failure)
+	case c.state == StateHalfOpen:
+		if failure {
+			changed = c.open(now)
+			break
+		}
+		c.probeSuccess++
+		if c.probeSuccess >= c.halfOpenProbes() {
+			c.Strategy.Reset()
+			changed = c.change(StateClosed)
+		}
+	case c.state == StateOpen:
+		// invocation started before circuit opened
 	default:
-		return StateClosed
+		c.count(failure)
+		s := c.Strategy
+		s.Record(Outcome{Failure: failure, Duration: d, At: now})
+		if s.ShouldTrip(now) {
+			changed = c.open(now)
+		}
+	}
+	save := c.publish(changed, now)
+	c.mu.Unlock()
+	if save {
+		go c.backgroundSave()
 	}
+	c.notify(changed)
 }
 
-func (c *CircuitBreaker) recordFailure() {
-	c.failCount++
-	c.lastFailure = time.Now()
+// ForceOpen keeps circuit open, rejecting all invocations, until Reset
+func (c *CircuitBreaker) ForceOpen() {
+	c.override(StateOpen)
 }
-func (c *CircuitBreaker) reset() {
-	c.failCount = 0
+
+// ForceClose keeps circuit closed, allowing all invocations, until Reset
+func (c *CircuitBreaker) ForceClose() {
+	c.override(StateClosed)
+}
+
+// Reset releases forced state and closes circuit clearing recorded invocations
+func (c *CircuitBreaker) Reset() {
+	c.override("")
+}
+
+// Forced returns forced state, empty when state is not forced
+func (c *CircuitBreaker) Forced() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.forced
+}
+
+// override forces state, empty forced releases forced state and closes the circuit
+func (c *CircuitBreaker) override(forced State) {
+	c.mu.Lock()
+	from, changed := c.current(time.Now())
+	c.forced = forced
+	to := forced
+	if forced == "" {
+		c.Strategy.Reset()
+		c.state = StateClosed
+		c.changedAt = time.Now()
+		to = StateClosed
+	}
+	var overridden *transition
+	if from != to {
+		overridden = &transition{from: from, to: to, callbacks: c.callbacks}
+	}
+	if c.Name != "" {
+		registerMetrics()
+		stateGauge.WithLabelValues(c.Name).Set(stateValue(to))
+		if overridden != nil {
+			transitionsTotal.WithLabelValues(c.Name, string(from), string(to)).Inc()
+		}
+	}
+	c.mu.Unlock()
+	c.notify(changed)
+	c.notify(overridden)
+}
+
+func (c *CircuitBreaker) open(now time.Time) *transition {
+	c.openedAt = now
+	return c.change(StateOpen)
+}
+
+func (c *CircuitBreaker) halfOpenProbes() int {
+	if c.HalfOpenProbes < 1 {
+		return 1
+	}
+	return c.HalfOpenProbes
+}
+
+func (c *CircuitBreaker) observe(result string) {
+	if c.Name == "" {
+		return
+	}
+	registerMetrics()
+	switch result {
+	case resultRejected:
+		rejectionsTotal.WithLabelValues(c.Name).Inc()
+		return
+	case resultTimeout:
+		timeoutsTotal.WithLabelValues(c.Name).Inc()
+	}
+	callsTotal.WithLabelValues(c.Name, result).Inc()
 }
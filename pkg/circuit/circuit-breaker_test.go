@@ -3,10 +3,12 @@ package circuit_test
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/pinkgorilla/go-sample/pkg/circuit"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
@@ -66,3 +68,344 @@ func Test_Circuit_TresholdLimit(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func failing() error { return ErrTesterBelowSuccessTresshold }
+
+func succeeding() error { return nil }
+
+func Test_Circuit_ConfigDefaults(t *testing.T) {
+	ctx := context.Background()
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{ResetTimeout: time.Minute})
+	for i := 0; i < 3; i++ {
+		if err := cb.Invoke(ctx, succeeding); err != nil {
+			t.Fatalf("expected invocation without configured timeout to succeed, got %v", err)
+		}
+	}
+	if cb.State() != circuit.StateClosed {
+		t.Fatalf("expected closed state after successes, got %s", cb.State())
+	}
+	// default strategy trips after 5 consecutive failures
+	for i := 0; i < 4; i++ {
+		cb.Invoke(ctx, failing)
+	}
+	if cb.State() != circuit.StateClosed {
+		t.Fatalf("expected closed state below default treshold, got %s", cb.State())
+	}
+	cb.Invoke(ctx, failing)
+	if cb.State() != circuit.StateOpen {
+		t.Fatalf("expected open state at default treshold, got %s", cb.State())
+	}
+
+	// circuit breaker without invocation timeout does not limit invocations
+	cb = circuit.NewCircuitBreaker(1, 0, time.Minute)
+	if err := cb.Invoke(ctx, func() error { <-time.After(10 * time.Millisecond); return nil }); err != nil {
+		t.Fatalf("expected invocation not limited, got %v", err)
+	}
+}
+
+func Test_Circuit_FailureRatio(t *testing.T) {
+	ctx := context.Background()
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		InvocationTimeout: 100 * time.Millisecond,
+		ResetTimeout:      time.Second,
+		Strategy:          circuit.FailureRatio(0.5, time.Second, 10),
+	})
+
+	// alternating failures never trip consecutive failure strategy
+	for i := 0; i < 4; i++ {
+		cb.Invoke(ctx, failing)
+		cb.Invoke(ctx, succeeding)
+	}
+	if cb.State() != circuit.StateClosed {
+		t.Fatalf("expected closed state below min requests, got %s", cb.State())
+	}
+	cb.Invoke(ctx, failing)
+	cb.Invoke(ctx, succeeding)
+	if cb.State() != circuit.StateOpen {
+		t.Fatalf("expected open state at 50%% failure ratio, got %s", cb.State())
+	}
+}
+
+func Test_Circuit_FailureRatio_Window(t *testing.T) {
+	ctx := context.Background()
+	window := 200 * time.Millisecond
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		InvocationTimeout: 100 * time.Millisecond,
+		ResetTimeout:      time.Second,
+		Strategy:          circuit.FailureRatio(0.5, window, 4),
+	})
+	for i := 0; i < 3; i++ {
+		cb.Invoke(ctx, failing)
+	}
+	// failures fall out of the window
+	<-time.After(window + 50*time.Millisecond)
+	for i := 0; i < 4; i++ {
+		cb.Invoke(ctx, succeeding)
+	}
+	cb.Invoke(ctx, failing)
+	if cb.State() != circuit.StateClosed {
+		t.Fatalf("expected closed state, got %s", cb.State())
+	}
+}
+
+func Test_Circuit_SlowCallRatio(t *testing.T) {
+	ctx := context.Background()
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		InvocationTimeout: time.Second,
+		ResetTimeout:      time.Second,
+		Strategy:          circuit.SlowCallRatio(0.5, 20*time.Millisecond, time.Minute, 4),
+	})
+	slow := func() error { <-time.After(30 * time.Millisecond); return nil }
+	cb.Invoke(ctx, succeeding)
+	cb.Invoke(ctx, slow)
+	cb.Invoke(ctx, succeeding)
+	if cb.State() != circuit.StateClosed {
+		t.Fatalf("expected closed state, got %s", cb.State())
+	}
+	cb.Invoke(ctx, slow)
+	if cb.State() != circuit.StateOpen {
+		t.Fatalf("expected open state at 50%% slow call ratio, got %s", cb.State())
+	}
+}
+
+func Test_Circuit_HalfOpenProbes(t *testing.T) {
+	ctx := context.Background()
+	resetTimeout := 100 * time.Millisecond
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		InvocationTimeout: time.Second,
+		ResetTimeout:      resetTimeout,
+		Strategy:          circuit.ConsecutiveFailures(1),
+		HalfOpenProbes:    3,
+	})
+	cb.Invoke(ctx, failing)
+	<-time.After(resetTimeout + 10*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := cb.Invoke(ctx, succeeding); err != nil {
+			t.Fatal(err)
+		}
+		if cb.State() != circuit.StateHalfOpen {
+			t.Fatalf("expected halfopen state after %v probes, got %s", i+1, cb.State())
+		}
+	}
+	cb.Invoke(ctx, succeeding)
+	if cb.State() != circuit.StateClosed {
+		t.Fatalf("expected closed state after 3 probes, got %s", cb.State())
+	}
+
+	// a failing probe opens the circuit again
+	cb.Invoke(ctx, failing)
+	<-time.After(resetTimeout + 10*time.Millisecond)
+	cb.Invoke(ctx, succeeding)
+	cb.Invoke(ctx, failing)
+	if cb.State() != circuit.StateOpen {
+		t.Fatalf("expected open state, got %s", cb.State())
+	}
+}
+
+func Test_Circuit_HalfOpenProbes_Limit(t *testing.T) {
+	ctx := context.Background()
+	resetTimeout := 100 * time.Millisecond
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		InvocationTimeout: time.Second,
+		ResetTimeout:      resetTimeout,
+		Strategy:          circuit.ConsecutiveFailures(1),
+		HalfOpenProbes:    1,
+	})
+	cb.Invoke(ctx, failing)
+	<-time.After(resetTimeout + 10*time.Millisecond)
+
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- cb.Invoke(ctx, func() error {
+			close(started)
+			<-time.After(100 * time.Millisecond)
+			return nil
+		})
+	}()
+	<-started
+	if err := cb.Invoke(ctx, succeeding); err != circuit.ErrCircuitOpen {
+		t.Fatalf("expected ErrCircuitOpen while probe is in flight, got %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if cb.State() != circuit.StateClosed {
+		t.Fatalf("expected closed state, got %s", cb.State())
+	}
+}
+
+func Test_Circuit_HalfOpenProbes_Total(t *testing.T) {
+	ctx := context.Background()
+	resetTimeout := 100 * time.Millisecond
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		InvocationTimeout: time.Second,
+		ResetTimeout:      resetTimeout,
+		Strategy:          circuit.ConsecutiveFailures(1),
+		HalfOpenProbes:    2,
+	})
+	cb.Invoke(ctx, failing)
+	<-time.After(resetTimeout + 10*time.Millisecond)
+	if err := cb.Invoke(ctx, succeeding); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- cb.Invoke(ctx, func() error {
+			close(started)
+			<-time.After(100 * time.Millisecond)
+			return nil
+		})
+	}()
+	<-started
+	// completed probe is not released, so a third probe is rejected
+	if err := cb.Invoke(ctx, succeeding); err != circuit.ErrCircuitOpen {
+		t.Fatalf("expected ErrCircuitOpen after all probes allowed, got %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if cb.State() != circuit.StateClosed {
+		t.Fatalf("expected closed state, got %s", cb.State())
+	}
+}
+
+func Test_Circuit_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		InvocationTimeout: time.Second,
+		ResetTimeout:      10 * time.Millisecond,
+		Strategy:          circuit.FailureRatio(0.5, time.Second, 10),
+		HalfOpenProbes:    2,
+	})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if (i+j)%2 == 0 {
+					cb.Invoke(ctx, failing)
+				} else {
+					cb.Invoke(ctx, succeeding)
+				}
+				cb.State()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func Test_Circuit_OnStateChange(t *testing.T) {
+	ctx := context.Background()
+	resetTimeout := 50 * time.Millisecond
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		Name:              "test-state-change",
+		InvocationTimeout: time.Second,
+		ResetTimeout:      resetTimeout,
+		Strategy:          circuit.ConsecutiveFailures(1),
+	})
+	changes := []string{}
+	cb.OnStateChange(func(name string, from, to circuit.State) {
+		// callbacks are called outside of circuit breaker lock
+		cb.State()
+		changes = append(changes, fmt.Sprint(name, ":", from, "->", to))
+	})
+	cb.Invoke(ctx, failing)
+	<-time.After(resetTimeout + 10*time.Millisecond)
+	cb.Invoke(ctx, succeeding)
+
+	expected := []string{
+		"test-state-change:closed->open",
+		"test-state-change:open->halfopen",
+		"test-state-change:halfopen->closed",
+	}
+	if fmt.Sprint(changes) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, changes)
+	}
+}
+
+type recordingAlert struct {
+	errs chan error
+}
+
+func (a *recordingAlert) Error(err error) error {
+	a.errs <- err
+	return nil
+}
+
+func Test_Circuit_Alert(t *testing.T) {
+	a := &recordingAlert{errs: make(chan error, 1)}
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		Name:              "test-alert",
+		InvocationTimeout: time.Second,
+		ResetTimeout:      time.Second,
+		Strategy:          circuit.ConsecutiveFailures(1),
+		Alert:             a,
+	})
+	cb.Invoke(context.Background(), failing)
+	select {
+	case err := <-a.errs:
+		expected := circuit.StateChangeError{Name: "test-alert", From: circuit.StateClosed, To: circuit.StateOpen}
+		if err != expected {
+			t.Fatalf("expected %v, got %v", expected, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected state change alert")
+	}
+}
+
+func Test_Circuit_Metrics(t *testing.T) {
+	ctx := context.Background()
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		Name:              "test-metrics",
+		InvocationTimeout: 20 * time.Millisecond,
+		ResetTimeout:      time.Second,
+		Strategy:          circuit.ConsecutiveFailures(3),
+	})
+	cb.Invoke(ctx, succeeding)
+	cb.Invoke(ctx, failing)
+	cb.Invoke(ctx, func() error { <-time.After(50 * time.Millisecond); return nil })
+	cb.Invoke(ctx, failing)
+	cb.Invoke(ctx, succeeding)
+
+	values := map[string]float64{}
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["name"] != "test-metrics" {
+				continue
+			}
+			key := f.GetName() + labels["result"]
+			switch {
+			case m.GetCounter() != nil:
+				values[key] = m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				values[key] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	expected := map[string]float64{
+		"circuit_breaker_state":               2,
+		"circuit_breaker_calls_totalsuccess":  1,
+		"circuit_breaker_calls_totalfailure":  2,
+		"circuit_breaker_calls_totaltimeout":  1,
+		"circuit_breaker_timeouts_total":      1,
+		"circuit_breaker_rejections_total":    1,
+		"circuit_breaker_state_changes_total": 1,
+	}
+	for k, v := range expected {
+		if values[k] != v {
+			t.Fatalf("expected %s %v, got %v", k, v, values)
+		}
+	}
+}
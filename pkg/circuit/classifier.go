@@ -0,0 +1,65 @@
+package circuit
+
+import (
+	"errors"
+	"fmt"
+
+	perrors "github.com/pinkgorilla/go-sample/pkg/errors"
+)
+
+// FailureClassifier returns true when error returned by invoked function counts as failure,
+// errors not counting as failure are still returned to the caller
+type FailureClassifier func(err error) bool
+
+// IgnoreErrors returns FailureClassifier not counting errors matching any of targets
+func IgnoreErrors(targets ...error) FailureClassifier {
+	return func(err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// IgnoreValidationErrors is FailureClassifier not counting errors.ValidationError,
+// caused by invalid input rather than unhealthy dependency
+func IgnoreValidationErrors(err error) bool {
+	var verr perrors.ValidationError
+	if errors.As(err, &verr) {
+		return false
+	}
+	var pverr *perrors.ValidationError
+	return !errors.As(err, &pverr)
+}
+
+// StatusError is error of a response status code, e.g. HTTP status code
+type StatusError struct {
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.StatusCode)
+}
+
+// IgnoreClientErrors is FailureClassifier not counting StatusError with 4xx status code
+func IgnoreClientErrors(err error) bool {
+	var serr StatusError
+	if errors.As(err, &serr) {
+		return serr.StatusCode < 400 || serr.StatusCode >= 500
+	}
+	return true
+}
+
+// Classify returns FailureClassifier counting error as failure only when all classifiers count it
+func Classify(classifiers ...FailureClassifier) FailureClassifier {
+	return func(err error) bool {
+		for _, c := range classifiers {
+			if !c(err) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,99 @@
+package circuit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/circuit"
+	perrors "github.com/pinkgorilla/go-sample/pkg/errors"
+)
+
+func Test_Circuit_Do(t *testing.T) {
+	cb := circuit.NewCircuitBreaker(1, 50*time.Millisecond, time.Second)
+	v, err := cb.Do(context.Background(), func(ctx context.Context) (interface{}, error) {
+		return 42, nil
+	})
+	if err != nil || v != 42 {
+		t.Fatalf("expected 42, got %v %v", v, err)
+	}
+
+	cancelled := make(chan error, 1)
+	_, err = cb.Do(context.Background(), func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	if err != circuit.ErrCircuitInvocationTimeoutExceeded {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	select {
+	case err := <-cancelled:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected fn context to be cancelled on timeout")
+	}
+}
+
+func Test_Circuit_Invoke_LateResult(t *testing.T) {
+	// fn finishing after invocation timeout must not panic or block
+	cb := circuit.NewCircuitBreaker(5, 10*time.Millisecond, time.Second)
+	done := make(chan struct{})
+	err := cb.Invoke(context.Background(), func() error {
+		defer close(done)
+		time.Sleep(50 * time.Millisecond)
+		return errors.New("late")
+	})
+	if err != circuit.ErrCircuitInvocationTimeoutExceeded {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected fn to finish")
+	}
+}
+
+func Test_Circuit_IsFailure(t *testing.T) {
+	config := circuit.DefaultConfig()
+	config.Strategy = circuit.ConsecutiveFailures(1)
+	config.IsFailure = circuit.Classify(circuit.IgnoreValidationErrors, circuit.IgnoreClientErrors)
+	cb := circuit.NewCircuitBreakerWithConfig(config)
+	ctx := context.Background()
+
+	ignored := []error{
+		perrors.NewValidationError("invalid"),
+		fmt.Errorf("wrapped: %w", circuit.StatusError{StatusCode: 404}),
+	}
+	for _, e := range ignored {
+		_, err := cb.Do(ctx, func(ctx context.Context) (interface{}, error) { return nil, e })
+		if err == nil || err.Error() != e.Error() {
+			t.Fatalf("expected error returned as is, got %v", err)
+		}
+		if cb.State() != circuit.StateClosed {
+			t.Fatalf("expected %v not to count as failure", e)
+		}
+	}
+
+	cb.Do(ctx, func(ctx context.Context) (interface{}, error) {
+		return nil, circuit.StatusError{StatusCode: 503}
+	})
+	if cb.State() != circuit.StateOpen {
+		t.Fatalf("expected open state, got %s", cb.State())
+	}
+}
+
+func Test_IgnoreErrors(t *testing.T) {
+	notFound := errors.New("not found")
+	isFailure := circuit.IgnoreErrors(notFound)
+	if isFailure(fmt.Errorf("get: %w", notFound)) {
+		t.Fatal("expected wrapped ignored error not to be failure")
+	}
+	if !isFailure(errors.New("other")) {
+		t.Fatal("expected other error to be failure")
+	}
+}
@@ -0,0 +1,77 @@
+package circuit
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultSuccess  = "success"
+	resultFailure  = "failure"
+	resultTimeout  = "timeout"
+	resultRejected = "rejected"
+)
+
+var (
+	metricsOnce sync.Once
+
+	stateGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuit_breaker_state",
+			Help: "State of circuit breaker, 0 is closed, 1 is half open and 2 is open.",
+		},
+		[]string{"name"},
+	)
+	transitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_state_changes_total",
+			Help: "A counter for circuit breaker state changes.",
+		},
+		[]string{"name", "from", "to"},
+	)
+	callsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_calls_total",
+			Help: "A counter for invocations allowed by circuit breaker, partitioned by result.",
+		},
+		[]string{"name", "result"},
+	)
+	rejectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_rejections_total",
+			Help: "A counter for invocations rejected by open circuit breaker.",
+		},
+		[]string{"name"},
+	)
+	timeoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_timeouts_total",
+			Help: "A counter for invocations exceeding circuit breaker invocation timeout.",
+		},
+		[]string{"name"},
+	)
+	backendErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_backend_errors_total",
+			Help: "A counter for failed shared state backend operations, partitioned by operation.",
+		},
+		[]string{"name", "operation"},
+	)
+)
+
+func registerMetrics() {
+	metricsOnce.Do(func() {
+		prometheus.MustRegister(stateGauge, transitionsTotal, callsTotal, rejectionsTotal, timeoutsTotal, backendErrorsTotal)
+	})
+}
+
+func stateValue(s State) float64 {
+	switch s {
+	case StateHalfOpen:
+		return 1
+	case StateOpen:
+		return 2
+	}
+	return 0
+}
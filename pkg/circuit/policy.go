@@ -0,0 +1,197 @@
+package circuit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBulkheadFull = fmt.Errorf("circuit error: %s", "bulkhead is full")
+	ErrRateLimited  = fmt.Errorf("circuit error: %s", "rate limit exceeded")
+	ErrTimeout      = fmt.Errorf("circuit error: %s", "timeout exceeded")
+)
+
+// Policy executes fn applying resilience behavior, CircuitBreaker is a Policy
+type Policy interface {
+	Execute(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// PolicyFunc is an adapter to use ordinary function as Policy
+type PolicyFunc func(ctx context.Context, fn func(ctx context.Context) error) error
+
+// Execute calls p(ctx, fn)
+func (p PolicyFunc) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	return p(ctx, fn)
+}
+
+// Wrap combines policies into a single Policy, the first policy is the outermost,
+// e.g. Wrap(Fallback(f), breaker, NewBulkhead(10, 100), Timeout(time.Second))
+func Wrap(policies ...Policy) Policy {
+	return PolicyFunc(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		next := fn
+		for i := len(policies) - 1; i >= 0; i-- {
+			p, inner := policies[i], next
+			next = func(ctx context.Context) error {
+				return p.Execute(ctx, inner)
+			}
+		}
+		return next(ctx)
+	})
+}
+
+// Bulkhead limits number of concurrent executions, executions above the limit
+// wait in a queue of limited size and are rejected with ErrBulkheadFull when the queue is full
+type Bulkhead struct {
+	slots    chan struct{}
+	maxQueue int
+	queued   int
+	mu       sync.Mutex
+}
+
+// NewBulkhead returns new Bulkhead allowing maxConcurrent executions and maxQueue waiting executions
+func NewBulkhead(maxConcurrent, maxQueue int) *Bulkhead {
+	return &Bulkhead{
+		slots:    make(chan struct{}, maxConcurrent),
+		maxQueue: maxQueue,
+	}
+}
+
+// Execute executes fn when a slot is available, waiting in queue until ctx is done
+func (b *Bulkhead) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	select {
+	case b.slots <- struct{}{}:
+	default:
+		b.mu.Lock()
+		if b.queued >= b.maxQueue {
+			b.mu.Unlock()
+			return ErrBulkheadFull
+		}
+		b.queued++
+		b.mu.Unlock()
+		select {
+		case b.slots <- struct{}{}:
+			b.dequeue()
+		case <-ctx.Done():
+			b.dequeue()
+			return ctx.Err()
+		}
+	}
+	defer func() { <-b.slots }()
+	return fn(ctx)
+}
+
+func (b *Bulkhead) dequeue() {
+	b.mu.Lock()
+	b.queued--
+	b.mu.Unlock()
+}
+
+// RateLimiter is token bucket rate limiter, executions without available token are rejected
+// with ErrRateLimited
+type RateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// NewRateLimiter returns new RateLimiter refilling rate tokens per second up to burst tokens,
+// the bucket starts full
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a token and returns true when a token is available
+func (r *RateLimiter) Allow() bool {
+	ok, _ := r.reserve(false)
+	return ok
+}
+
+// Wait waits until a token is available or ctx is done
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	_, wait := r.reserve(true)
+	if wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		// give back reserved token
+		r.mu.Lock()
+		r.tokens = math.Min(r.burst, r.tokens+1)
+		r.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token, when no token is available and wait is true
+// a future token is reserved and duration until it is available is returned
+func (r *RateLimiter) reserve(wait bool) (bool, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.tokens = math.Min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	r.last = now
+	if r.tokens >= 1 {
+		r.tokens--
+		return true, 0
+	}
+	if !wait || r.rate <= 0 {
+		return false, 0
+	}
+	r.tokens--
+	return true, time.Duration(-r.tokens / r.rate * float64(time.Second))
+}
+
+// Execute executes fn when a token is available, otherwise returns ErrRateLimited
+func (r *RateLimiter) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !r.Allow() {
+		return ErrRateLimited
+	}
+	return fn(ctx)
+}
+
+// Timeout returns Policy cancelling fn context after d and returning ErrTimeout
+// without waiting for fn ignoring the cancellation, cancellation of ctx is returned as is
+func Timeout(d time.Duration) Policy {
+	return PolicyFunc(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		ch := make(chan error, 1)
+		go func() {
+			ch <- fn(ctx)
+		}()
+		select {
+		case err := <-ch:
+			return err
+		case <-ctx.Done():
+			if ctx.Err() == context.Canceled {
+				return ctx.Err()
+			}
+			return ErrTimeout
+		}
+	})
+}
+
+// Fallback returns Policy calling fallback with error of fn, error returned by fallback is returned
+func Fallback(fallback func(ctx context.Context, err error) error) Policy {
+	return PolicyFunc(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		return fallback(ctx, err)
+	})
+}
@@ -0,0 +1,148 @@
+package circuit_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/circuit"
+)
+
+var (
+	_ circuit.Policy = &circuit.CircuitBreaker{}
+	_ circuit.Policy = &circuit.Bulkhead{}
+	_ circuit.Policy = &circuit.RateLimiter{}
+)
+
+func Test_Bulkhead(t *testing.T) {
+	b := circuit.NewBulkhead(2, 1)
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+	blocking := func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- b.Execute(context.Background(), blocking)
+		}()
+	}
+	<-started
+	<-started
+	// wait for the third execution to be queued
+	<-time.After(20 * time.Millisecond)
+	if err := b.Execute(context.Background(), blocking); err != circuit.ErrBulkheadFull {
+		t.Fatalf("expected ErrBulkheadFull, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := circuit.NewBulkhead(0, 1).Execute(ctx, blocking); err != context.Canceled {
+		t.Fatalf("expected queued execution cancelled, got %v", err)
+	}
+}
+
+func Test_RateLimiter(t *testing.T) {
+	r := circuit.NewRateLimiter(50, 2)
+	ok := func(ctx context.Context) error { return nil }
+	for i := 0; i < 2; i++ {
+		if err := r.Execute(context.Background(), ok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Execute(context.Background(), ok); err != circuit.ErrRateLimited {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	<-time.After(25 * time.Millisecond)
+	if !r.Allow() {
+		t.Fatal("expected refilled token")
+	}
+
+	start := time.Now()
+	if err := r.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Fatalf("expected wait for token, waited %v", time.Since(start))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Wait(ctx); err != context.Canceled {
+		t.Fatalf("expected cancelled wait, got %v", err)
+	}
+}
+
+func Test_Timeout(t *testing.T) {
+	p := circuit.Timeout(20 * time.Millisecond)
+	err := p.Execute(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != circuit.ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	failure := errors.New("failed")
+	err = p.Execute(context.Background(), func(ctx context.Context) error { return failure })
+	if err != failure {
+		t.Fatalf("expected fn error, got %v", err)
+	}
+}
+
+func Test_Wrap(t *testing.T) {
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		InvocationTimeout: time.Second,
+		ResetTimeout:      time.Minute,
+		Strategy:          circuit.ConsecutiveFailures(2),
+	})
+	fallbacks := []error{}
+	p := circuit.Wrap(
+		circuit.Fallback(func(ctx context.Context, err error) error {
+			fallbacks = append(fallbacks, err)
+			return nil
+		}),
+		cb,
+		circuit.NewBulkhead(10, 10),
+		circuit.Timeout(20*time.Millisecond),
+	)
+
+	order := []string{}
+	failure := errors.New("failed")
+	err := p.Execute(context.Background(), func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Fatal("expected deadline of timeout policy")
+		}
+		order = append(order, "fn")
+		return failure
+	})
+	if err != nil {
+		t.Fatalf("expected fallback result, got %v", err)
+	}
+	p.Execute(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	p.Execute(context.Background(), func(ctx context.Context) error {
+		order = append(order, "fn")
+		return nil
+	})
+	if len(order) != 1 || len(fallbacks) != 3 {
+		t.Fatalf("expected circuit open after 2 failures, got %v %v", order, fallbacks)
+	}
+	if fallbacks[0] != failure || fallbacks[1] != circuit.ErrTimeout || fallbacks[2] != circuit.ErrCircuitOpen {
+		t.Fatalf("unexpected fallback errors %v", fallbacks)
+	}
+}
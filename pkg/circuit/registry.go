@@ -0,0 +1,137 @@
+package circuit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Registry keeps named circuit breakers, e.g. one per host or dependency
+type Registry struct {
+	factory  func(name string) *CircuitBreaker
+	breakers map[string]*CircuitBreaker
+	mu       sync.RWMutex
+}
+
+// NewRegistry returns new Registry, factory creates circuit breaker of a name on its first use.
+// Circuit breakers are created with DefaultConfig when factory is nil
+func NewRegistry(factory func(name string) *CircuitBreaker) *Registry {
+	if factory == nil {
+		factory = func(name string) *CircuitBreaker {
+			config := DefaultConfig()
+			config.Name = name
+			return NewCircuitBreakerWithConfig(config)
+		}
+	}
+	return &Registry{
+		factory:  factory,
+		breakers: map[string]*CircuitBreaker{},
+	}
+}
+
+// Get returns circuit breaker named name, creating it when it does not exist
+func (r *Registry) Get(name string) *CircuitBreaker {
+	r.mu.RLock()
+	cb, ok := r.breakers[name]
+	r.mu.RUnlock()
+	if ok {
+		return cb
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cb, ok := r.breakers[name]; ok {
+		return cb
+	}
+	cb = r.factory(name)
+	r.breakers[name] = cb
+	return cb
+}
+
+// Register registers circuit breaker under its Name
+func (r *Registry) Register(cb *CircuitBreaker) error {
+	if cb.Name == "" {
+		return fmt.Errorf("circuit: circuit breaker name is required")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.breakers[cb.Name]; ok {
+		return fmt.Errorf("circuit: circuit breaker '%s' is already registered", cb.Name)
+	}
+	r.breakers[cb.Name] = cb
+	return nil
+}
+
+// Lookup returns circuit breaker named name without creating it
+func (r *Registry) Lookup(name string) (*CircuitBreaker, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cb, ok := r.breakers[name]
+	return cb, ok
+}
+
+// Names returns names of registered circuit breakers in ascending order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.breakers))
+	for name := range r.breakers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BreakerStatus is circuit breaker status reported by AdminHandler
+type BreakerStatus struct {
+	Name   string `json:"name"`
+	State  State  `json:"state"`
+	Forced State  `json:"forced,omitempty"`
+}
+
+// AdminHandler returns http handler to inspect and force state of circuit breakers in r.
+//
+// GET lists circuit breakers, POST with name and action query parameters,
+// where action is open, close or reset, forces state of circuit breaker named name
+func AdminHandler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+			statuses := []BreakerStatus{}
+			for _, name := range r.Names() {
+				if cb, ok := r.Lookup(name); ok {
+					statuses = append(statuses, BreakerStatus{Name: name, State: cb.State(), Forced: cb.Forced()})
+				}
+			}
+			writeJSON(w, http.StatusOK, statuses)
+		case http.MethodPost:
+			name := req.URL.Query().Get("name")
+			cb, ok := r.Lookup(name)
+			if !ok {
+				writeJSON(w, http.StatusNotFound, map[string]string{"message": fmt.Sprintf("circuit breaker '%s' not found", name)})
+				return
+			}
+			switch req.URL.Query().Get("action") {
+			case "open":
+				cb.ForceOpen()
+			case "close":
+				cb.ForceClose()
+			case "reset":
+				cb.Reset()
+			default:
+				writeJSON(w, http.StatusBadRequest, map[string]string{"message": "action must be open, close or reset"})
+				return
+			}
+			writeJSON(w, http.StatusOK, BreakerStatus{Name: name, State: cb.State(), Forced: cb.Forced()})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
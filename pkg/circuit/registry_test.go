@@ -0,0 +1,120 @@
+package circuit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/circuit"
+)
+
+func Test_Registry(t *testing.T) {
+	r := circuit.NewRegistry(nil)
+	a := r.Get("payment")
+	if a != r.Get("payment") || a.Name != "payment" {
+		t.Fatal("expected the same named circuit breaker")
+	}
+	if err := r.Register(circuit.NewCircuitBreakerWithConfig(&circuit.Config{Name: "payment"})); err == nil {
+		t.Fatal("expected already registered error")
+	}
+	if err := r.Register(circuit.NewCircuitBreakerWithConfig(&circuit.Config{Name: "inventory"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Lookup("shipping"); ok {
+		t.Fatal("expected lookup not to create circuit breaker")
+	}
+	names := r.Names()
+	if len(names) != 2 || names[0] != "inventory" || names[1] != "payment" {
+		t.Fatalf("unexpected names %v", names)
+	}
+}
+
+func Test_CircuitBreaker_Force(t *testing.T) {
+	ctx := context.Background()
+	cb := circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+		InvocationTimeout: time.Second,
+		ResetTimeout:      10 * time.Millisecond,
+		Strategy:          circuit.ConsecutiveFailures(1),
+	})
+	changes := []circuit.State{}
+	cb.OnStateChange(func(name string, from, to circuit.State) {
+		changes = append(changes, to)
+	})
+
+	cb.ForceOpen()
+	<-time.After(20 * time.Millisecond)
+	if err := cb.Invoke(ctx, succeeding); err != circuit.ErrCircuitOpen {
+		t.Fatalf("expected forced open circuit to stay open, got %v", err)
+	}
+	cb.ForceClose()
+	for i := 0; i < 3; i++ {
+		cb.Invoke(ctx, failing)
+	}
+	if cb.State() != circuit.StateClosed || cb.Forced() != circuit.StateClosed {
+		t.Fatalf("expected forced closed circuit, got %s", cb.State())
+	}
+	cb.Reset()
+	if cb.Forced() != "" {
+		t.Fatal("expected forced state released")
+	}
+	cb.Invoke(ctx, failing)
+	if cb.State() != circuit.StateOpen {
+		t.Fatalf("expected open state after reset, got %s", cb.State())
+	}
+	expected := []circuit.State{circuit.StateOpen, circuit.StateClosed, circuit.StateOpen}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected changes %v, got %v", expected, changes)
+	}
+}
+
+func Test_AdminHandler(t *testing.T) {
+	r := circuit.NewRegistry(nil)
+	r.Get("payment")
+	r.Get("inventory")
+	svr := httptest.NewServer(circuit.AdminHandler(r))
+	defer svr.Close()
+
+	res, err := http.Post(svr.URL+"?name=payment&action=open", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %v, got %v", http.StatusOK, res.StatusCode)
+	}
+	if r.Get("payment").State() != circuit.StateOpen {
+		t.Fatal("expected forced open circuit")
+	}
+
+	res, err = http.Get(svr.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []circuit.BreakerStatus{}
+	if err := json.NewDecoder(res.Body).Decode(&statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[1].Name != "payment" || statuses[1].State != circuit.StateOpen || statuses[1].Forced != circuit.StateOpen {
+		t.Fatalf("unexpected statuses %+v", statuses)
+	}
+
+	cases := map[string]int{
+		"?name=unknown&action=open":  http.StatusNotFound,
+		"?name=payment&action=foo":   http.StatusBadRequest,
+		"?name=payment&action=reset": http.StatusOK,
+	}
+	for query, code := range cases {
+		res, err := http.Post(svr.URL+query, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != code {
+			t.Fatalf("%s: expected status %v, got %v", query, code, res.StatusCode)
+		}
+	}
+	if r.Get("payment").State() != circuit.StateClosed {
+		t.Fatal("expected reset circuit")
+	}
+}
@@ -0,0 +1,174 @@
+package circuit
+
+import (
+	"time"
+)
+
+// Outcome is outcome of an invocation recorded by TripStrategy
+type Outcome struct {
+	Failure  bool
+	Duration time.Duration
+	At       time.Time
+}
+
+// TripStrategy decides when closed circuit changes to open state
+type TripStrategy interface {
+	// Record records outcome of an invocation in closed state
+	Record(o Outcome)
+	// ShouldTrip returns true when circuit should change to open state
+	ShouldTrip(now time.Time) bool
+	// Reset clears recorded outcomes, it is called when circuit closes
+	Reset()
+}
+
+// ConsecutiveFailures returns TripStrategy which trips after threshold consecutive failures,
+// a success resets the count
+func ConsecutiveFailures(threshold int) TripStrategy {
+	return &consecutiveFailures{threshold: threshold}
+}
+
+type consecutiveFailures struct {
+	threshold int
+	count     int
+}
+
+func (s *consecutiveFailures) Record(o Outcome) {
+	if o.Failure {
+		s.count++
+		return
+	}
+	s.count = 0
+}
+
+func (s *consecutiveFailures) ShouldTrip(now time.Time) bool {
+	return s.count >= s.threshold
+}
+
+func (s *consecutiveFailures) Reset() {
+	s.count = 0
+}
+
+// FailureRatio returns TripStrategy which trips when ratio of failures over rolling window
+// reaches ratio, circuit does not trip until the window has at least minRequests invocations
+func FailureRatio(ratio float64, window time.Duration, minRequests int) TripStrategy {
+	return &failureRatio{
+		ratio:       ratio,
+		minRequests: minRequests,
+		window:      newRollingWindow(window),
+	}
+}
+
+type failureRatio struct {
+	ratio       float64
+	minRequests int
+	window      *rollingWindow
+}
+
+func (s *failureRatio) Record(o Outcome) {
+	s.window.add(o.At, o.Failure)
+}
+
+func (s *failureRatio) ShouldTrip(now time.Time) bool {
+	total, matched := s.window.counts(now)
+	return tripRatio(total, matched, s.minRequests, s.ratio)
+}
+
+func (s *failureRatio) Reset() {
+	s.window.reset()
+}
+
+// SlowCallRatio returns TripStrategy which trips when ratio of invocations taking at least
+// slowThreshold over rolling window reaches ratio, failed invocations are counted as slow.
+// Circuit does not trip until the window has at least minRequests invocations
+func SlowCallRatio(ratio float64, slowThreshold, window time.Duration, minRequests int) TripStrategy {
+	return &slowCallRatio{
+		ratio:         ratio,
+		slowThreshold: slowThreshold,
+		minRequests:   minRequests,
+		window:        newRollingWindow(window),
+	}
+}
+
+type slowCallRatio struct {
+	ratio         float64
+	slowThreshold time.Duration
+	minRequests   int
+	window        *rollingWindow
+}
+
+func (s *slowCallRatio) Record(o Outcome) {
+	s.window.add(o.At, o.Failure || o.Duration >= s.slowThreshold)
+}
+
+func (s *slowCallRatio) ShouldTrip(now time.Time) bool {
+	total, matched := s.window.counts(now)
+	return tripRatio(total, matched, s.minRequests, s.ratio)
+}
+
+func (s *slowCallRatio) Reset() {
+	s.window.reset()
+}
+
+func tripRatio(total, matched, minRequests int, ratio float64) bool {
+	if total == 0 || total < minRequests {
+		return false
+	}
+	return float64(matched)/float64(total) >= ratio
+}
+
+// windowBuckets is number of buckets rolling window is divided into
+const windowBuckets = 10
+
+// rollingWindow counts invocations over the last window duration using buckets,
+// a bucket is discarded as a whole once it falls out of the window
+type rollingWindow struct {
+	width   time.Duration
+	buckets [windowBuckets]bucket
+}
+
+type bucket struct {
+	start   int64
+	total   int
+	matched int
+}
+
+func newRollingWindow(window time.Duration) *rollingWindow {
+	width := window / windowBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &rollingWindow{width: width}
+}
+
+func (w *rollingWindow) add(at time.Time, matched bool) {
+	if matched {
+		w.addN(at, 1, 1)
+		return
+	}
+	w.addN(at, 1, 0)
+}
+
+func (w *rollingWindow) addN(at time.Time, total, matched int) {
+	start := at.UnixNano() / int64(w.width)
+	b := &w.buckets[start%windowBuckets]
+	if b.start != start {
+		*b = bucket{start: start}
+	}
+	b.total += total
+	b.matched += matched
+}
+
+func (w *rollingWindow) counts(now time.Time) (total, matched int) {
+	current := now.UnixNano() / int64(w.width)
+	for _, b := range w.buckets {
+		if b.total > 0 && current-b.start < windowBuckets {
+			total += b.total
+			matched += b.matched
+		}
+	}
+	return total, matched
+}
+
+func (w *rollingWindow) reset() {
+	w.buckets = [windowBuckets]bucket{}
+}
@@ -0,0 +1,166 @@
+package errors
+
+import (
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+)
+
+// maxStackDepth is max number of frames captured in stack trace
+const maxStackDepth = 32
+
+type stack []uintptr
+
+// callers returns stack trace skipping skip frames, the caller of callers is skipped when skip is 1
+func callers(skip int) stack {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+1, pcs)
+	return stack(pcs[:n])
+}
+
+func (s stack) String() string {
+	if len(s) == 0 {
+		return ""
+	}
+	b := strings.Builder{}
+	frames := runtime.CallersFrames(s)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// Is returns true when target is error of this package with the same code and message
+func (e BaseError) Is(target error) bool {
+	t, ok := target.(interface{ Base() BaseError })
+	if !ok {
+		return false
+	}
+	b := t.Base()
+	return b.Code == e.Code && b.Message == e.Message
+}
+
+// Base returns BaseError of error, promoted to every error embedding BaseError
+func (e BaseError) Base() BaseError {
+	return e
+}
+
+// Format formats error, %+v formats error with its field errors
+func (e ValidationError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('#') {
+			fmt.Fprintf(s, "errors.ValidationError{BaseError:%#v, Fields:%#v}", e.BaseError, e.Fields)
+			return
+		}
+		if s.Flag('+') {
+			io.WriteString(s, detail(e))
+			return
+		}
+		io.WriteString(s, e.Error())
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// Wrap returns err wrapping cause with stack trace of the caller,
+// errors.Is and errors.As match err before the cause chain
+func Wrap(err, cause error) error {
+	return &wrapped{err: err, cause: cause, stack: callers(2)}
+}
+
+// wrapped holds cause and stack trace outside of err so errors of this package stay comparable
+type wrapped struct {
+	err   error
+	cause error
+	stack stack
+}
+
+func (w *wrapped) Error() string {
+	return w.err.Error() + ": " + w.cause.Error()
+}
+
+// Err returns wrapped error without its cause
+func (w *wrapped) Err() error {
+	return w.err
+}
+
+// Cause returns wrapped cause
+func (w *wrapped) Cause() error {
+	return w.cause
+}
+
+// Unwrap returns wrapped cause for errors.Is and errors.As
+func (w *wrapped) Unwrap() error {
+	return w.cause
+}
+
+// Is reports whether err matches target
+func (w *wrapped) Is(target error) bool {
+	return goerrors.Is(w.err, target)
+}
+
+// As finds first error in chain of err matching target
+func (w *wrapped) As(target interface{}) bool {
+	return goerrors.As(w.err, target)
+}
+
+// StackTrace returns stack trace captured when error was wrapped,
+// one function per line followed by its indented file and line
+func (w *wrapped) StackTrace() string {
+	return w.stack.String()
+}
+
+// MarshalJSON marshals err without its cause and stack trace
+func (w *wrapped) MarshalJSON() ([]byte, error) {
+	return json.Marshal(w.err)
+}
+
+// Format formats error, %+v formats error with its stack trace followed by formatted cause
+func (w *wrapped) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('#') {
+			fmt.Fprintf(s, "errors.Wrap(%#v, %#v)", w.err, w.cause)
+			return
+		}
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%s\n%scaused by: %+v", detail(w.err), w.StackTrace(), w.cause)
+			return
+		}
+		io.WriteString(s, w.Error())
+	case 's':
+		io.WriteString(s, w.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", w.Error())
+	}
+}
+
+// detail returns code and message of err followed by its field errors, one per line
+func detail(err error) string {
+	b := strings.Builder{}
+	if e, ok := err.(interface{ Base() BaseError }); ok {
+		b.WriteString(e.Base().Code + ": ")
+	}
+	b.WriteString(err.Error())
+	var fields []ValidationErrorField
+	switch v := err.(type) {
+	case ValidationError:
+		fields = v.Fields
+	case *ValidationError:
+		fields = v.Fields
+	}
+	for _, f := range fields {
+		fmt.Fprintf(&b, "\n\t%s: %s", f.Name, f.Message)
+	}
+	return b.String()
+}
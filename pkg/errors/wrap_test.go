@@ -0,0 +1,101 @@
+package errors_test
+
+import (
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+)
+
+func loadUser() error {
+	return errors.Wrap(errors.NewServiceError("failed to load user"), fmt.Errorf("query: %w", io.EOF))
+}
+
+func TestWithCause(t *testing.T) {
+	err := loadUser()
+	if !goerrors.Is(err, io.EOF) {
+		t.Fatal("expected errors.Is to match wrapped cause")
+	}
+	var serr errors.ServiceError
+	if !goerrors.As(err, &serr) || serr.Code != "ServiceError" {
+		t.Fatalf("expected errors.As to ServiceError, got %v", err)
+	}
+	if err.Error() != "failed to load user: query: EOF" {
+		t.Fatalf("unexpected message %s", err.Error())
+	}
+
+	wrapped := fmt.Errorf("handler: %w", errors.Wrap(errors.NewAuthError("expired token"), err))
+	var serr2 errors.ServiceError
+	if !goerrors.As(wrapped, &serr2) || !goerrors.Is(wrapped, io.EOF) {
+		t.Fatal("expected cause chain to be traversed")
+	}
+	if !goerrors.Is(wrapped, errors.NewAuthError("expired token")) {
+		t.Fatal("expected errors.Is to match error of the same code and message")
+	}
+	if goerrors.Is(wrapped, errors.NewAuthError("other")) || goerrors.Is(wrapped, errors.NewNotFoundError("expired token")) {
+		t.Fatal("expected errors.Is not to match different error")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	if errors.NewNotFoundError("user not found") != errors.NewNotFoundError("user not found") {
+		t.Fatal("expected errors of the same code and message to be equal")
+	}
+	if errors.NewNotFoundError("user not found") == errors.NewNotFoundError("other") {
+		t.Fatal("expected errors of different message not to be equal")
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	err := errors.Wrap(errors.NewNotFoundError("user not found"), io.EOF)
+	trace := err.(interface{ StackTrace() string }).StackTrace()
+	if !strings.HasPrefix(trace, "github.com/pinkgorilla/go-sample/pkg/errors_test.TestStackTrace\n") {
+		t.Fatalf("expected stack trace to start at the caller, got %s", trace)
+	}
+
+	formatted := fmt.Sprintf("%+v", loadUser())
+	for _, s := range []string{"ServiceError: failed to load user\n", "errors_test.loadUser\n", "wrap_test.go:", "caused by: query: EOF"} {
+		if !strings.Contains(formatted, s) {
+			t.Fatalf("expected %q in %s", s, formatted)
+		}
+	}
+	if fmt.Sprintf("%v", err) != "user not found: EOF" || fmt.Sprintf("%q", err) != `"user not found: EOF"` {
+		t.Fatal("unexpected formatting")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	validation := errors.NewValidationError("invalid")
+	validation.FieldRequired("name")
+	if s := fmt.Sprintf("%+v", validation); s != "ValidationError: invalid\n\tname: field is required" {
+		t.Fatalf("expected field errors, got %s", s)
+	}
+	if s := fmt.Sprintf("%+v", errors.Wrap(validation, io.EOF)); !strings.HasPrefix(s, "ValidationError: invalid\n\tname: field is required\n") {
+		t.Fatalf("expected field errors of wrapped error, got %s", s)
+	}
+	expected := `errors.ValidationError{BaseError:errors.BaseError{Code:"ValidationError", Message:"invalid"}, Fields:[]errors.ValidationErrorField{errors.ValidationErrorField{Name:"name", Message:"field is required"}}}`
+	if s := fmt.Sprintf("%#v", validation); s != expected {
+		t.Fatalf("expected %s, got %s", expected, s)
+	}
+	if s := fmt.Sprintf("%#v", errors.Wrap(errors.NewNotFoundError("x"), io.EOF)); !strings.HasPrefix(s, `errors.Wrap(errors.NotFoundError{BaseError:errors.BaseError{Code:"NotFoundError", Message:"x"}}, `) {
+		t.Fatalf("unexpected Go syntax %s", s)
+	}
+}
+
+func TestJSON_Stable(t *testing.T) {
+	validation := errors.NewValidationError("invalid")
+	validation.FieldRequired("name")
+	bs, _ := json.Marshal(errors.Wrap(validation, goerrors.New("internal detail")))
+	expected := `{"code":"ValidationError","message":"invalid","fields":[{"field":"name","message":"field is required"}]}`
+	if string(bs) != expected {
+		t.Fatalf("expected %s, got %s", expected, bs)
+	}
+	bs, _ = json.Marshal(errors.Wrap(errors.NewServiceError("failed"), io.EOF))
+	if string(bs) != `{"code":"ServiceError","message":"failed"}` {
+		t.Fatalf("expected cause and stack not to leak, got %s", bs)
+	}
+}
@@ -0,0 +1,290 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrBusClosed is returned by Emit of closed Bus
+var ErrBusClosed = errors.New("event: bus is closed")
+
+// HandlerErrors is aggregated error of handlers failing to handle the same data
+type HandlerErrors []error
+
+func (e HandlerErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d event handler(s) failed: %s", len(e), strings.Join(messages, "; "))
+}
+
+// Is reports whether any of handler errors matches target
+func (e HandlerErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+type subscription struct {
+	id      int
+	typ     reflect.Type
+	handler ListenerHandler
+}
+
+func (s subscription) matches(data interface{}) bool {
+	if s.typ == nil {
+		return true
+	}
+	t := reflect.TypeOf(data)
+	if t == nil {
+		return false
+	}
+	if s.typ.Kind() == reflect.Interface {
+		return t.Implements(s.typ)
+	}
+	return t == s.typ
+}
+
+// Bus is in-process event bus implementing Emitter and Listener.
+// Data is delivered to subscribed handlers in subscription order and,
+// for a single emitting goroutine, in emit order
+type Bus struct {
+	subscriptions []subscription
+	next          int
+	mu            sync.RWMutex
+
+	// async delivery
+	queue      chan busData
+	onError    func(data interface{}, err error)
+	dispatcher uint64    // goroutine id of dispatch
+	pending    []busData // emitted by handlers while queue is full, only accessed by dispatch
+	senders    sync.WaitGroup
+	closing    chan struct{}
+	done       chan struct{}
+	closed     bool
+	closeMu    sync.Mutex
+}
+
+type busData struct {
+	ctx  context.Context
+	data interface{}
+}
+
+// NewSyncBus returns new Bus delivering data in Emit call,
+// Emit returns HandlerErrors of all failing handlers
+func NewSyncBus() *Bus {
+	return &Bus{}
+}
+
+// NewAsyncBus returns new Bus delivering data from a single background goroutine,
+// Emit blocks when buffer is full except when called by a handler, which would block the delivering goroutine,
+// data emitted by handlers is then kept until buffer has room. Errors of handlers are passed to onError, which may be nil
+func NewAsyncBus(buffer int, onError func(data interface{}, err error)) *Bus {
+	b := &Bus{
+		queue:   make(chan busData, buffer),
+		onError: onError,
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	started := make(chan struct{})
+	go b.dispatch(started)
+	<-started
+	return b
+}
+
+// Subscribe subscribes handler to all data, returned func unsubscribes it
+func (b *Bus) Subscribe(handler ListenerHandler) func() {
+	return b.subscribe(nil, handler)
+}
+
+// SubscribeType subscribes handler to data of the same type as sample, e.g. OrderCreated{}.
+// Pointer to interface, e.g. (*fmt.Stringer)(nil), subscribes to data implementing the interface.
+// Returned func unsubscribes handler
+func (b *Bus) SubscribeType(sample interface{}, handler ListenerHandler) func() {
+	t := reflect.TypeOf(sample)
+	if t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Interface {
+		t = t.Elem()
+	}
+	return b.subscribe(t, handler)
+}
+
+func (b *Bus) subscribe(t reflect.Type, handler ListenerHandler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.next++
+	id := b.next
+	// copy on write so delivery iterates subscriptions without holding the lock
+	subscriptions := make([]subscription, len(b.subscriptions), len(b.subscriptions)+1)
+	copy(subscriptions, b.subscriptions)
+	b.subscriptions = append(subscriptions, subscription{id: id, typ: t, handler: handler})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subscriptions := make([]subscription, 0, len(b.subscriptions))
+		for _, s := range b.subscriptions {
+			if s.id != id {
+				subscriptions = append(subscriptions, s)
+			}
+		}
+		b.subscriptions = subscriptions
+	}
+}
+
+// Listen subscribes handler to all data until ctx is done
+func (b *Bus) Listen(ctx context.Context, handler ListenerHandler) {
+	unsubscribe := b.Subscribe(handler)
+	defer unsubscribe()
+	<-ctx.Done()
+}
+
+// Emit delivers data to subscribed handlers
+func (b *Bus) Emit(data interface{}) error {
+	return b.EmitContext(context.Background(), data)
+}
+
+// EmitContext delivers data to subscribed handlers passing ctx to them
+func (b *Bus) EmitContext(ctx context.Context, data interface{}) error {
+	if b.queue == nil {
+		return b.deliver(ctx, data)
+	}
+	if !b.send() {
+		return ErrBusClosed
+	}
+	defer b.senders.Done()
+	d := busData{ctx, data}
+	select {
+	case b.queue <- d:
+		return nil
+	default:
+	}
+	if goid() == b.dispatcher {
+		// emitted by handler, blocking would stop the only goroutine draining queue
+		b.pending = append(b.pending, d)
+		return nil
+	}
+	select {
+	case b.queue <- d:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.closing:
+		return ErrBusClosed
+	}
+}
+
+// send registers sender to queue, false when bus is closed.
+// Close waits for registered senders before closing queue
+func (b *Bus) send() bool {
+	b.closeMu.Lock()
+	defer b.closeMu.Unlock()
+	if b.closed {
+		return false
+	}
+	b.senders.Add(1)
+	return true
+}
+
+// deliver calls all handlers subscribed to data, a failing handler does not stop delivery
+func (b *Bus) deliver(ctx context.Context, data interface{}) error {
+	b.mu.RLock()
+	subscriptions := b.subscriptions
+	b.mu.RUnlock()
+	var errs HandlerErrors
+	for _, s := range subscriptions {
+		if !s.matches(data) {
+			continue
+		}
+		if err := CallHandler(ctx, s.handler, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (b *Bus) dispatch(started chan<- struct{}) {
+	defer close(b.done)
+	b.dispatcher = goid()
+	close(started)
+	for d := range b.queue {
+		// fill slot just received so data emitted by handlers keeps its order
+		b.flush()
+		b.handle(d)
+		b.flush()
+	}
+	for len(b.pending) > 0 {
+		d := b.pending[0]
+		b.pending = b.pending[1:]
+		b.handle(d)
+	}
+}
+
+func (b *Bus) handle(d busData) {
+	err := b.deliver(d.ctx, d.data)
+	if err != nil && b.onError != nil {
+		b.onError(d.data, err)
+	}
+}
+
+// flush moves data emitted by handlers to queue while it has room,
+// data left after bus is closed is delivered once queue is drained
+func (b *Bus) flush() {
+	if len(b.pending) == 0 || !b.send() {
+		return
+	}
+	defer b.senders.Done()
+	for len(b.pending) > 0 {
+		select {
+		case b.queue <- b.pending[0]:
+			b.pending = b.pending[1:]
+		default:
+			return
+		}
+	}
+}
+
+// Close stops async bus after data already emitted is delivered.
+// Called by a handler, Close returns without waiting and remaining data is delivered after handler returns
+func (b *Bus) Close() {
+	if b.queue == nil {
+		return
+	}
+	b.closeMu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.closing)
+		b.closeMu.Unlock()
+		b.senders.Wait()
+		close(b.queue)
+	} else {
+		b.closeMu.Unlock()
+	}
+	if goid() == b.dispatcher {
+		return
+	}
+	<-b.done
+}
+
+// goid returns id of calling goroutine, parsed from first line of its stack trace, e.g. "goroutine 18 [running]:"
+func goid() uint64 {
+	var buf [32]byte
+	n := runtime.Stack(buf[:], false)
+	s := strings.TrimPrefix(string(buf[:n]), "goroutine ")
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	id, _ := strconv.ParseUint(s, 10, 64)
+	return id
+}
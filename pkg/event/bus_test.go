@@ -0,0 +1,240 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+)
+
+type orderCreated struct{ ID int }
+
+type orderPaid struct{ ID int }
+
+func (o orderPaid) String() string { return fmt.Sprint("order ", o.ID, " paid") }
+
+var (
+	_ event.Emitter  = &event.Bus{}
+	_ event.Listener = &event.Bus{}
+)
+
+func Test_SyncBus(t *testing.T) {
+	bus := event.NewSyncBus()
+	calls := []string{}
+	bus.SubscribeType(orderCreated{}, func(ctx context.Context, data interface{}) error {
+		calls = append(calls, fmt.Sprint("created-1 ", data.(orderCreated).ID))
+		return nil
+	})
+	bus.SubscribeType(orderCreated{}, func(ctx context.Context, data interface{}) error {
+		calls = append(calls, fmt.Sprint("created-2 ", data.(orderCreated).ID))
+		return nil
+	})
+	bus.SubscribeType((*fmt.Stringer)(nil), func(ctx context.Context, data interface{}) error {
+		calls = append(calls, data.(fmt.Stringer).String())
+		return nil
+	})
+	unsubscribe := bus.Subscribe(func(ctx context.Context, data interface{}) error {
+		calls = append(calls, "all")
+		return nil
+	})
+
+	bus.Emit(orderCreated{1})
+	bus.Emit(orderPaid{1})
+	unsubscribe()
+	bus.Emit(orderCreated{2})
+
+	expected := []string{"created-1 1", "created-2 1", "all", "order 1 paid", "all", "created-1 2", "created-2 2"}
+	if fmt.Sprint(calls) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, calls)
+	}
+}
+
+func Test_SyncBus_Errors(t *testing.T) {
+	bus := event.NewSyncBus()
+	failure := errors.New("failed")
+	called := 0
+	bus.Subscribe(func(ctx context.Context, data interface{}) error {
+		return failure
+	})
+	bus.Subscribe(func(ctx context.Context, data interface{}) error {
+		panic("panicking")
+	})
+	bus.Subscribe(func(ctx context.Context, data interface{}) error {
+		called++
+		return nil
+	})
+
+	err := bus.Emit(1)
+	var errs event.HandlerErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expected 2 aggregated errors, got %v", err)
+	}
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected aggregated error to match handler error, got %v", err)
+	}
+	if called != 1 {
+		t.Fatal("expected failing handlers not to stop delivery")
+	}
+}
+
+func Test_AsyncBus(t *testing.T) {
+	n := 100
+	mu := sync.Mutex{}
+	failed := []interface{}{}
+	bus := event.NewAsyncBus(10, func(data interface{}, err error) {
+		mu.Lock()
+		failed = append(failed, data)
+		mu.Unlock()
+	})
+
+	received := []int{}
+	ctx, cancel := context.WithCancel(context.TODO())
+	listening := make(chan struct{})
+	go func() {
+		bus.SubscribeType(orderCreated{}, func(ctx context.Context, data interface{}) error {
+			received = append(received, data.(orderCreated).ID)
+			if data.(orderCreated).ID%10 == 0 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+		close(listening)
+		bus.Listen(ctx, func(ctx context.Context, data interface{}) error {
+			return nil
+		})
+	}()
+	<-listening
+
+	for i := 0; i < n; i++ {
+		if err := bus.Emit(orderCreated{i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bus.Close()
+	cancel()
+
+	if len(received) != n {
+		t.Fatalf("expected %v received, got %v", n, len(received))
+	}
+	for i, id := range received {
+		if id != i {
+			t.Fatalf("expected ordered delivery, got %v at %v", id, i)
+		}
+	}
+	if len(failed) != n/10 {
+		t.Fatalf("expected %v failed, got %v", n/10, len(failed))
+	}
+	if err := bus.Emit(orderCreated{}); err != event.ErrBusClosed {
+		t.Fatalf("expected ErrBusClosed, got %v", err)
+	}
+}
+
+func Test_AsyncBus_EmitFromHandler(t *testing.T) {
+	bus := event.NewAsyncBus(1, nil)
+	received := []int{}
+	delivered := make(chan struct{})
+	bus.SubscribeType(orderCreated{}, func(ctx context.Context, data interface{}) error {
+		id := data.(orderCreated).ID
+		received = append(received, id)
+		if id == 0 {
+			// buffer is full after first emit, handler must not block
+			for i := 1; i <= 5; i++ {
+				if err := bus.EmitContext(ctx, orderCreated{i}); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+		if id == 5 {
+			close(delivered)
+		}
+		return nil
+	})
+	bus.Emit(orderCreated{0})
+	select {
+	case <-delivered:
+	case <-time.After(time.Second):
+		t.Fatal("expected data emitted by handler to be delivered")
+	}
+	bus.Close()
+	for i, id := range received {
+		if id != i {
+			t.Fatalf("expected ordered delivery, got %v at %v", id, i)
+		}
+	}
+}
+
+func Test_AsyncBus_CloseFromHandler(t *testing.T) {
+	bus := event.NewAsyncBus(10, nil)
+	received := make(chan int, 10)
+	release := make(chan struct{})
+	bus.SubscribeType(orderCreated{}, func(ctx context.Context, data interface{}) error {
+		id := data.(orderCreated).ID
+		if id == 0 {
+			<-release
+			bus.Close()
+			if err := bus.Emit(orderCreated{99}); err != event.ErrBusClosed {
+				t.Errorf("expected ErrBusClosed, got %v", err)
+			}
+		}
+		received <- id
+		return nil
+	})
+	for i := 0; i < 3; i++ {
+		bus.Emit(orderCreated{i})
+	}
+	close(release)
+
+	closed := make(chan struct{})
+	go func() {
+		bus.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("expected Close called by handler not to deadlock")
+	}
+	close(received)
+	ids := []int{}
+	for id := range received {
+		ids = append(ids, id)
+	}
+	if len(ids) != 3 {
+		t.Fatalf("expected data emitted before Close to be delivered, got %v", ids)
+	}
+}
+
+func Test_Bus_Listen(t *testing.T) {
+	bus := event.NewSyncBus()
+	ctx, cancel := context.WithCancel(context.TODO())
+	handled := make(chan interface{}, 1)
+	done := make(chan struct{})
+	go func() {
+		bus.Listen(ctx, func(ctx context.Context, data interface{}) error {
+			handled <- data
+			return nil
+		})
+		close(done)
+	}()
+	for {
+		bus.Emit(1)
+		select {
+		case <-handled:
+		case <-time.After(10 * time.Millisecond):
+			continue
+		}
+		break
+	}
+	cancel()
+	<-done
+	bus.Emit(2)
+	select {
+	case data := <-handled:
+		t.Fatalf("expected handler unsubscribed after ctx done, got %v", data)
+	default:
+	}
+}
@@ -0,0 +1,91 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Expected versions with special meaning for Append
+const (
+	// AnyVersion disables optimistic concurrency check
+	AnyVersion = -1
+	// NoStream expects the stream to have no events yet
+	NoStream = 0
+)
+
+var (
+	// ErrWrongExpectedVersion is returned by Append when stream version differs from expected version
+	ErrWrongExpectedVersion = errors.New("eventstore: wrong expected version")
+	// ErrSnapshotNotFound is returned by LoadSnapshot when stream has no snapshot
+	ErrSnapshotNotFound = errors.New("eventstore: snapshot not found")
+)
+
+// Event is event stored in a stream
+type Event struct {
+	// Position is global position of event across all streams, set by store
+	Position int64  `json:"position"`
+	StreamID string `json:"streamId"`
+	// Version is position of event in its stream starting from 1, set by store
+	Version   int               `json:"version"`
+	Type      string            `json:"type"`
+	Data      json.RawMessage   `json:"data"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// NewEvent returns new Event with json encoded data
+func NewEvent(eventType string, data interface{}) (Event, error) {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		Type: eventType,
+		Data: bs,
+	}, nil
+}
+
+// Decode decodes event data into v
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Snapshot is state of stream aggregate at stream version
+type Snapshot struct {
+	StreamID  string          `json:"streamId"`
+	Version   int             `json:"version"`
+	State     json.RawMessage `json:"state"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// EventStore stores events in streams
+type EventStore interface {
+	// Append appends events to stream when current stream version equals expectedVersion,
+	// otherwise it returns ErrWrongExpectedVersion. Stored events are returned with Position, Version and Timestamp set
+	Append(ctx context.Context, streamID string, expectedVersion int, events ...Event) ([]Event, error)
+	// Read returns events of stream starting from version fromVersion
+	Read(ctx context.Context, streamID string, fromVersion int) ([]Event, error)
+	// ReadAll returns at most limit events of all streams ordered by Position starting after position,
+	// limit less than 1 returns all events
+	ReadAll(ctx context.Context, after int64, limit int) ([]Event, error)
+	// SaveSnapshot saves snapshot replacing previous snapshot of the stream
+	SaveSnapshot(ctx context.Context, snapshot Snapshot) error
+	// LoadSnapshot returns latest snapshot of stream or ErrSnapshotNotFound
+	LoadSnapshot(ctx context.Context, streamID string) (Snapshot, error)
+}
+
+// wrongVersion returns ErrWrongExpectedVersion wrapped with stream details
+func wrongVersion(streamID string, expected, actual int) error {
+	return fmt.Errorf("%w: stream '%s' expected version %d, actual %d", ErrWrongExpectedVersion, streamID, expected, actual)
+}
+
+// checkVersion returns error when expected version does not match current stream version
+func checkVersion(streamID string, expected, current int) error {
+	if expected == AnyVersion || expected == current {
+		return nil
+	}
+	return wrongVersion(streamID, expected, current)
+}
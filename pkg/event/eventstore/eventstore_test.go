@@ -0,0 +1,198 @@
+package eventstore_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/pinkgorilla/go-sample/pkg/event/eventstore"
+)
+
+func newSQLiteStore(t *testing.T) *eventstore.SQLStore {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	store, err := eventstore.NewSQLStore(db, eventstore.DefaultSQLStoreConfig(eventstore.DialectSQLite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateTables(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func mustEvent(t *testing.T, eventType string, data interface{}) eventstore.Event {
+	e, err := eventstore.NewEvent(eventType, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func testEventStore(t *testing.T, store eventstore.EventStore) {
+	ctx := context.TODO()
+	created := mustEvent(t, "order.created", map[string]interface{}{"amount": 10})
+	created.Metadata = map[string]string{"user": "u-1"}
+	paid := mustEvent(t, "order.paid", map[string]interface{}{"amount": 10})
+
+	events, err := store.Append(ctx, "order-1", eventstore.NoStream, created, paid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events[0].Version != 1 || events[1].Version != 2 || events[1].Position <= events[0].Position {
+		t.Fatalf("unexpected stored events %+v", events)
+	}
+	_, err = store.Append(ctx, "order-1", 1, paid)
+	if !errors.Is(err, eventstore.ErrWrongExpectedVersion) {
+		t.Fatalf("expected ErrWrongExpectedVersion, got %v", err)
+	}
+	if _, err := store.Append(ctx, "order-2", eventstore.AnyVersion, created); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Append(ctx, "order-1", 2, mustEvent(t, "order.shipped", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err = store.Read(ctx, "order-1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != "order.paid" || events[1].Version != 3 {
+		t.Fatalf("unexpected stream events %+v", events)
+	}
+	events, err = store.Read(ctx, "order-1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data struct{ Amount int }
+	if err := events[0].Decode(&data); err != nil || data.Amount != 10 {
+		t.Fatalf("unexpected event data %s, %v", events[0].Data, err)
+	}
+	if events[0].Metadata["user"] != "u-1" || events[0].Timestamp.IsZero() {
+		t.Fatalf("unexpected event %+v", events[0])
+	}
+
+	all, err := store.ReadAll(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 || all[2].StreamID != "order-2" {
+		t.Fatalf("unexpected events %+v", all)
+	}
+	page, err := store.ReadAll(ctx, all[1].Position, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].Position != all[2].Position {
+		t.Fatalf("unexpected page %+v", page)
+	}
+
+	if _, err := store.LoadSnapshot(ctx, "order-1"); err != eventstore.ErrSnapshotNotFound {
+		t.Fatalf("expected ErrSnapshotNotFound, got %v", err)
+	}
+	for _, v := range []int{2, 3} {
+		err := store.SaveSnapshot(ctx, eventstore.Snapshot{StreamID: "order-1", Version: v, State: []byte(`{"status":"paid"}`)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot, err := store.LoadSnapshot(ctx, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Version != 3 || string(snapshot.State) != `{"status":"paid"}` {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+}
+
+func testConcurrentAppend(t *testing.T, store eventstore.EventStore) {
+	n := 10
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Append(context.TODO(), "ledger-1", eventstore.NoStream, mustEvent(t, "ledger.opened", nil))
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 {
+		t.Fatalf("expected a single successful append, got %v", succeeded)
+	}
+}
+
+func Test_InMemoryStore(t *testing.T) {
+	testEventStore(t, eventstore.NewInMemoryStore())
+	testConcurrentAppend(t, eventstore.NewInMemoryStore())
+}
+
+func Test_SQLStore(t *testing.T) {
+	testEventStore(t, newSQLiteStore(t))
+	testConcurrentAppend(t, newSQLiteStore(t))
+}
+
+func Test_SQLStore_ReadAll_Gap(t *testing.T) {
+	ctx := context.TODO()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	config := eventstore.DefaultSQLStoreConfig(eventstore.DialectSQLite)
+	config.GapTimeout = 100 * time.Millisecond
+	store, err := eventstore.NewSQLStore(db, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, stream := range []string{"order-1", "order-2", "order-3"} {
+		if _, err := store.Append(ctx, stream, eventstore.NoStream, mustEvent(t, "order.created", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// position 2 is not visible yet, e.g. its transaction did not commit
+	if _, err := db.Exec("DELETE FROM events WHERE position = 2"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := store.ReadAll(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Position != 1 {
+		t.Fatalf("expected reading to stop before gap, got %v", events)
+	}
+	time.Sleep(config.GapTimeout)
+	events, err = store.ReadAll(ctx, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Position != 3 {
+		t.Fatalf("expected reading past gap after timeout, got %v", events)
+	}
+}
+
+func Test_NewSQLStore_UnsupportedDialect(t *testing.T) {
+	_, err := eventstore.NewSQLStore(nil, eventstore.DefaultSQLStoreConfig("oracle"))
+	if err == nil {
+		t.Fatal("expected unsupported dialect error")
+	}
+}
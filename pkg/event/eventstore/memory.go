@@ -0,0 +1,99 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// InMemoryStore is EventStore keeping events in memory
+type InMemoryStore struct {
+	events    []Event
+	streams   map[string][]int
+	snapshots map[string]Snapshot
+	mu        sync.RWMutex
+}
+
+// NewInMemoryStore returns new InMemoryStore
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		streams:   map[string][]int{},
+		snapshots: map[string]Snapshot{},
+	}
+}
+
+// Append appends events to stream
+func (s *InMemoryStore) Append(ctx context.Context, streamID string, expectedVersion int, events ...Event) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := len(s.streams[streamID])
+	if err := checkVersion(streamID, expectedVersion, current); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	result := make([]Event, len(events))
+	for i, e := range events {
+		e.StreamID = streamID
+		e.Version = current + i + 1
+		e.Position = int64(len(s.events) + 1)
+		e.Timestamp = now
+		s.streams[streamID] = append(s.streams[streamID], len(s.events))
+		s.events = append(s.events, e)
+		result[i] = e
+	}
+	return result, nil
+}
+
+// Read returns events of stream starting from version fromVersion
+func (s *InMemoryStore) Read(ctx context.Context, streamID string, fromVersion int) ([]Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if fromVersion < 1 {
+		fromVersion = 1
+	}
+	result := []Event{}
+	indexes := s.streams[streamID]
+	for i := fromVersion - 1; i < len(indexes); i++ {
+		result = append(result, s.events[indexes[i]])
+	}
+	return result, nil
+}
+
+// ReadAll returns events of all streams ordered by Position starting after position
+func (s *InMemoryStore) ReadAll(ctx context.Context, after int64, limit int) ([]Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if after < 0 {
+		after = 0
+	}
+	result := []Event{}
+	for i := after; i < int64(len(s.events)); i++ {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		result = append(result, s.events[i])
+	}
+	return result, nil
+}
+
+// SaveSnapshot saves snapshot of stream
+func (s *InMemoryStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if snapshot.Timestamp.IsZero() {
+		snapshot.Timestamp = time.Now().UTC()
+	}
+	s.snapshots[snapshot.StreamID] = snapshot
+	return nil
+}
+
+// LoadSnapshot returns latest snapshot of stream
+func (s *InMemoryStore) LoadSnapshot(ctx context.Context, streamID string) (Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot, ok := s.snapshots[streamID]
+	if !ok {
+		return Snapshot{}, ErrSnapshotNotFound
+	}
+	return snapshot, nil
+}
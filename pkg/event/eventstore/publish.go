@@ -0,0 +1,52 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+)
+
+// PublishError is returned by PublishingStore when events are appended but not published
+type PublishError struct {
+	Err error
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("eventstore: events appended but not published: %v", e.Err)
+}
+
+// Unwrap returns the emitter error
+func (e *PublishError) Unwrap() error {
+	return e.Err
+}
+
+// PublishingStore is EventStore emitting every appended Event through emitter
+type PublishingStore struct {
+	EventStore
+	emitter event.Emitter
+}
+
+// NewPublishingStore returns new PublishingStore wrapping store
+func NewPublishingStore(store EventStore, emitter event.Emitter) *PublishingStore {
+	return &PublishingStore{
+		EventStore: store,
+		emitter:    emitter,
+	}
+}
+
+// Append appends events to stream and emits them after they are stored.
+// Stored events are returned along with *PublishError when emit fails,
+// use managed.Emitter to have failed emits retried
+func (s *PublishingStore) Append(ctx context.Context, streamID string, expectedVersion int, events ...Event) ([]Event, error) {
+	stored, err := s.EventStore.Append(ctx, streamID, expectedVersion, events...)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range stored {
+		if err := s.emitter.Emit(e); err != nil {
+			return stored, &PublishError{Err: err}
+		}
+	}
+	return stored, nil
+}
@@ -0,0 +1,107 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+)
+
+// replayPageSize is number of events read per round trip when all streams are replayed
+const replayPageSize = 500
+
+// Replay passes events to handler, e.g. to rebuild a projection.
+// Events of streamIDs are replayed stream by stream, all events ordered by Position are replayed
+// when streamIDs is empty. Replay stops at the first handler error
+func Replay(ctx context.Context, store EventStore, handler event.ListenerHandler, streamIDs ...string) error {
+	if len(streamIDs) == 0 {
+		return replayAll(ctx, store, handler)
+	}
+	for _, id := range streamIDs {
+		events, err := store.Read(ctx, id, 1)
+		if err != nil {
+			return err
+		}
+		if err := replay(ctx, events, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replayAll(ctx context.Context, store EventStore, handler event.ListenerHandler) error {
+	var after int64
+	for {
+		events, err := store.ReadAll(ctx, after, replayPageSize)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		if err := replay(ctx, events, handler); err != nil {
+			return err
+		}
+		after = events[len(events)-1].Position
+	}
+}
+
+func replay(ctx context.Context, events []Event, handler event.ListenerHandler) error {
+	for _, e := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := handler(ctx, e); err != nil {
+			return fmt.Errorf("eventstore: replay of stream '%s' version %d: %w", e.StreamID, e.Version, err)
+		}
+	}
+	return nil
+}
+
+// Aggregate is state rebuilt from events of a stream,
+// aggregate is restored from and saved to snapshots by its json representation
+type Aggregate interface {
+	Apply(e Event) error
+}
+
+// Load restores aggregate from latest snapshot of stream and applies events following it,
+// returned version is used as expected version of next Append
+func Load(ctx context.Context, store EventStore, streamID string, aggregate Aggregate) (int, error) {
+	version := 0
+	snapshot, err := store.LoadSnapshot(ctx, streamID)
+	switch err {
+	case nil:
+		if err := json.Unmarshal(snapshot.State, aggregate); err != nil {
+			return 0, err
+		}
+		version = snapshot.Version
+	case ErrSnapshotNotFound:
+	default:
+		return 0, err
+	}
+	events, err := store.Read(ctx, streamID, version+1)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range events {
+		if err := aggregate.Apply(e); err != nil {
+			return 0, err
+		}
+		version = e.Version
+	}
+	return version, nil
+}
+
+// SaveSnapshot saves json representation of aggregate as snapshot of stream at version
+func SaveSnapshot(ctx context.Context, store EventStore, streamID string, version int, aggregate Aggregate) error {
+	state, err := json.Marshal(aggregate)
+	if err != nil {
+		return err
+	}
+	return store.SaveSnapshot(ctx, Snapshot{
+		StreamID: streamID,
+		Version:  version,
+		State:    state,
+	})
+}
@@ -0,0 +1,146 @@
+package eventstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pinkgorilla/go-sample/pkg/event/eventstore"
+)
+
+type account struct {
+	Balance int `json:"balance"`
+	applied int
+}
+
+func (a *account) Apply(e eventstore.Event) error {
+	var data struct{ Amount int }
+	if err := e.Decode(&data); err != nil {
+		return err
+	}
+	switch e.Type {
+	case "deposited":
+		a.Balance += data.Amount
+	case "withdrawn":
+		a.Balance -= data.Amount
+	}
+	a.applied++
+	return nil
+}
+
+type recordingEmitter struct {
+	data []interface{}
+	err  error
+}
+
+func (e *recordingEmitter) Emit(data interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.data = append(e.data, data)
+	return nil
+}
+
+func Test_Load_Snapshot(t *testing.T) {
+	ctx := context.TODO()
+	store := eventstore.NewInMemoryStore()
+	for i := 0; i < 3; i++ {
+		store.Append(ctx, "acc-1", eventstore.AnyVersion, mustEvent(t, "deposited", map[string]int{"amount": 10}))
+	}
+
+	a := &account{}
+	version, err := eventstore.Load(ctx, store, "acc-1", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 3 || a.Balance != 30 {
+		t.Fatalf("unexpected version %v balance %v", version, a.Balance)
+	}
+	if err := eventstore.SaveSnapshot(ctx, store, "acc-1", version, a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Append(ctx, "acc-1", version, mustEvent(t, "withdrawn", map[string]int{"amount": 5})); err != nil {
+		t.Fatal(err)
+	}
+
+	a = &account{}
+	version, err = eventstore.Load(ctx, store, "acc-1", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 4 || a.Balance != 25 || a.applied != 1 {
+		t.Fatalf("expected snapshot and 1 applied event, got version %v balance %v applied %v", version, a.Balance, a.applied)
+	}
+}
+
+func Test_PublishingStore(t *testing.T) {
+	ctx := context.TODO()
+	emitter := &recordingEmitter{}
+	store := eventstore.NewPublishingStore(eventstore.NewInMemoryStore(), emitter)
+
+	events, err := store.Append(ctx, "acc-1", eventstore.NoStream, mustEvent(t, "deposited", nil), mustEvent(t, "withdrawn", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emitter.data) != 2 || emitter.data[1].(eventstore.Event).Version != events[1].Version {
+		t.Fatalf("expected appended events emitted, got %v", emitter.data)
+	}
+	if _, err := store.Append(ctx, "acc-1", eventstore.NoStream, mustEvent(t, "deposited", nil)); err == nil {
+		t.Fatal("expected wrong expected version error")
+	}
+	if len(emitter.data) != 2 {
+		t.Fatalf("expected rejected events not emitted, got %v", emitter.data)
+	}
+
+	emitter.err = errors.New("stream down")
+	events, err = store.Append(ctx, "acc-1", 2, mustEvent(t, "deposited", nil))
+	var perr *eventstore.PublishError
+	if !errors.As(err, &perr) || perr.Err != emitter.err || len(events) != 1 {
+		t.Fatalf("expected PublishError with stored events, got %v %v", events, err)
+	}
+}
+
+func Test_Replay(t *testing.T) {
+	ctx := context.TODO()
+	store := newSQLiteStore(t)
+	for i := 0; i < 3; i++ {
+		for _, id := range []string{"acc-1", "acc-2"} {
+			store.Append(ctx, id, eventstore.AnyVersion, mustEvent(t, "deposited", map[string]int{"amount": i}))
+		}
+	}
+
+	// projection of balance per account
+	balances := map[string]*account{}
+	projection := func(ctx context.Context, data interface{}) error {
+		e := data.(eventstore.Event)
+		if balances[e.StreamID] == nil {
+			balances[e.StreamID] = &account{}
+		}
+		return balances[e.StreamID].Apply(e)
+	}
+	if err := eventstore.Replay(ctx, store, projection); err != nil {
+		t.Fatal(err)
+	}
+	if balances["acc-1"].Balance != 3 || balances["acc-2"].Balance != 3 {
+		t.Fatalf("unexpected balances %+v %+v", balances["acc-1"], balances["acc-2"])
+	}
+
+	balances = map[string]*account{}
+	if err := eventstore.Replay(ctx, store, projection, "acc-2"); err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 1 || balances["acc-2"].applied != 3 {
+		t.Fatalf("expected only acc-2 replayed, got %v", balances)
+	}
+
+	failure := errors.New("projection failed")
+	err := eventstore.Replay(ctx, store, func(ctx context.Context, data interface{}) error {
+		if data.(eventstore.Event).Position == 4 {
+			return failure
+		}
+		return nil
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected replay to stop with handler error, got %v", err)
+	}
+}
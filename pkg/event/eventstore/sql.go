@@ -0,0 +1,280 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event/internal/eventutil"
+)
+
+// Supported SQL dialects
+const (
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+	DialectSQLite   = "sqlite"
+)
+
+// defaultGapTimeout is GapTimeout used when it is not set
+const defaultGapTimeout = time.Second
+
+// SQLStoreConfig is SQLStore configuration
+type SQLStoreConfig struct {
+	Dialect        string `yaml:"dialect" json:"dialect"`
+	EventsTable    string `yaml:"eventsTable" json:"eventsTable"`
+	SnapshotsTable string `yaml:"snapshotsTable" json:"snapshotsTable"`
+	// GapTimeout is how long ReadAll waits for a gap in positions to be filled before reading past it,
+	// zero uses 1 second and negative reads past gaps immediately
+	GapTimeout time.Duration `yaml:"gapTimeout" json:"gapTimeout"`
+}
+
+// DefaultSQLStoreConfig returns default SQLStore config of dialect
+func DefaultSQLStoreConfig(dialect string) *SQLStoreConfig {
+	return &SQLStoreConfig{
+		Dialect:        dialect,
+		EventsTable:    "events",
+		SnapshotsTable: "snapshots",
+		GapTimeout:     defaultGapTimeout,
+	}
+}
+
+// SQLStore is EventStore backed by SQL database, database driver must be registered by caller.
+// Optimistic concurrency relies on unique constraint of stream id and version, see CreateTables
+type SQLStore struct {
+	db     *sql.DB
+	config SQLStoreConfig
+}
+
+// NewSQLStore returns new SQLStore using db
+func NewSQLStore(db *sql.DB, config *SQLStoreConfig) (*SQLStore, error) {
+	if config == nil {
+		config = DefaultSQLStoreConfig(DialectPostgres)
+	}
+	switch config.Dialect {
+	case DialectPostgres, DialectMySQL, DialectSQLite:
+	default:
+		return nil, fmt.Errorf("eventstore: unsupported sql dialect '%s'", config.Dialect)
+	}
+	c := *config
+	if c.GapTimeout == 0 {
+		c.GapTimeout = defaultGapTimeout
+	}
+	return &SQLStore{
+		db:     db,
+		config: c,
+	}, nil
+}
+
+// CreateTables creates events and snapshots tables when they do not exist
+func (s *SQLStore) CreateTables(ctx context.Context) error {
+	position := "BIGSERIAL PRIMARY KEY"
+	text := "TEXT"
+	switch s.config.Dialect {
+	case DialectMySQL:
+		position = "BIGINT AUTO_INCREMENT PRIMARY KEY"
+		text = "LONGTEXT"
+	case DialectSQLite:
+		position = "INTEGER PRIMARY KEY AUTOINCREMENT"
+	}
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			position %s,
+			stream_id VARCHAR(255) NOT NULL,
+			version INTEGER NOT NULL,
+			type VARCHAR(255) NOT NULL,
+			data %s NOT NULL,
+			metadata %s,
+			created_at BIGINT NOT NULL,
+			UNIQUE (stream_id, version)
+		)`, s.config.EventsTable, position, text, text),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			stream_id VARCHAR(255) NOT NULL PRIMARY KEY,
+			version INTEGER NOT NULL,
+			state %s NOT NULL,
+			created_at BIGINT NOT NULL
+		)`, s.config.SnapshotsTable, text),
+	}
+	for _, stmt := range statements {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Append appends events to stream in a single transaction
+func (s *SQLStore) Append(ctx context.Context, streamID string, expectedVersion int, events ...Event) ([]Event, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := s.version(ctx, tx, streamID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(streamID, expectedVersion, current); err != nil {
+		return nil, err
+	}
+
+	insert := s.query(fmt.Sprintf("INSERT INTO %s (stream_id, version, type, data, metadata, created_at) VALUES (?, ?, ?, ?, ?, ?)", s.config.EventsTable))
+	position := s.query(fmt.Sprintf("SELECT position FROM %s WHERE stream_id = ? AND version = ?", s.config.EventsTable))
+	now := time.Now().UTC()
+	result := make([]Event, len(events))
+	for i, e := range events {
+		e.StreamID = streamID
+		e.Version = current + i + 1
+		e.Timestamp = now
+		metadata, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, insert, e.StreamID, e.Version, e.Type, string(e.Data), string(metadata), e.Timestamp.UnixNano())
+		if err != nil {
+			// unique constraint violation caused by concurrent append
+			if actual, er := s.version(ctx, s.db, streamID); er == nil && actual != current {
+				return nil, wrongVersion(streamID, expectedVersion, actual)
+			}
+			return nil, err
+		}
+		err = tx.QueryRowContext(ctx, position, e.StreamID, e.Version).Scan(&e.Position)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = e
+	}
+	return result, tx.Commit()
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *SQLStore) version(ctx context.Context, q queryer, streamID string) (int, error) {
+	var version sql.NullInt64
+	err := q.QueryRowContext(ctx, s.query(fmt.Sprintf("SELECT MAX(version) FROM %s WHERE stream_id = ?", s.config.EventsTable)), streamID).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// Read returns events of stream starting from version fromVersion
+func (s *SQLStore) Read(ctx context.Context, streamID string, fromVersion int) ([]Event, error) {
+	query := s.query(fmt.Sprintf("SELECT position, stream_id, version, type, data, metadata, created_at FROM %s WHERE stream_id = ? AND version >= ? ORDER BY version", s.config.EventsTable))
+	rows, err := s.db.QueryContext(ctx, query, streamID, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	return scanEvents(rows)
+}
+
+// ReadAll returns events of all streams ordered by Position starting after position.
+// Positions are assigned on insert, so a transaction committing after another one may hold lower positions
+// and paging past them would skip its events. ReadAll stops before a gap in positions until the event
+// following the gap is older than GapTimeout, gaps left by rolled back transactions delay reading by GapTimeout
+// and events of a transaction committing later than GapTimeout after it appended them are still skipped
+func (s *SQLStore) ReadAll(ctx context.Context, after int64, limit int) ([]Event, error) {
+	query := fmt.Sprintf("SELECT position, stream_id, version, type, data, metadata, created_at FROM %s WHERE position > ? ORDER BY position", s.config.EventsTable)
+	args := []interface{}{after}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := s.db.QueryContext(ctx, s.query(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	events, err := scanEvents(rows)
+	if err != nil || s.config.GapTimeout < 0 {
+		return events, err
+	}
+	return beforeGap(events, after, time.Now().Add(-s.config.GapTimeout)), nil
+}
+
+// beforeGap returns events preceding first gap in positions when event following the gap is appended after settled
+func beforeGap(events []Event, after int64, settled time.Time) []Event {
+	previous := after
+	for i, e := range events {
+		if e.Position != previous+1 && e.Timestamp.After(settled) {
+			return events[:i]
+		}
+		previous = e.Position
+	}
+	return events
+}
+
+func scanEvents(rows *sql.Rows) ([]Event, error) {
+	defer rows.Close()
+	result := []Event{}
+	for rows.Next() {
+		var e Event
+		var data string
+		var metadata sql.NullString
+		var ts int64
+		err := rows.Scan(&e.Position, &e.StreamID, &e.Version, &e.Type, &data, &metadata, &ts)
+		if err != nil {
+			return nil, err
+		}
+		e.Data = json.RawMessage(data)
+		if metadata.Valid && metadata.String != "" {
+			if err := json.Unmarshal([]byte(metadata.String), &e.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		e.Timestamp = time.Unix(0, ts).UTC()
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+// SaveSnapshot saves snapshot of stream replacing its previous snapshot
+func (s *SQLStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	if snapshot.Timestamp.IsZero() {
+		snapshot.Timestamp = time.Now().UTC()
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, s.query(fmt.Sprintf("DELETE FROM %s WHERE stream_id = ?", s.config.SnapshotsTable)), snapshot.StreamID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.query(fmt.Sprintf("INSERT INTO %s (stream_id, version, state, created_at) VALUES (?, ?, ?, ?)", s.config.SnapshotsTable)),
+		snapshot.StreamID, snapshot.Version, string(snapshot.State), snapshot.Timestamp.UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// LoadSnapshot returns latest snapshot of stream
+func (s *SQLStore) LoadSnapshot(ctx context.Context, streamID string) (Snapshot, error) {
+	snapshot := Snapshot{StreamID: streamID}
+	var state string
+	var ts int64
+	err := s.db.QueryRowContext(ctx, s.query(fmt.Sprintf("SELECT version, state, created_at FROM %s WHERE stream_id = ?", s.config.SnapshotsTable)), streamID).
+		Scan(&snapshot.Version, &state, &ts)
+	if err == sql.ErrNoRows {
+		return Snapshot{}, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.State = json.RawMessage(state)
+	snapshot.Timestamp = time.Unix(0, ts).UTC()
+	return snapshot, nil
+}
+
+// query rewrites ? placeholders into dialect placeholders
+func (s *SQLStore) query(q string) string {
+	if s.config.Dialect != DialectPostgres {
+		return q
+	}
+	return eventutil.NumberedPlaceholders(q)
+}
@@ -0,0 +1,27 @@
+// Package eventutil holds helpers shared by event packages
+package eventutil
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Decode decodes data received from queue into v,
+// data can be the envelope itself or its json representation
+func Decode(data interface{}, v interface{}) error {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && !rv.IsNil() && reflect.TypeOf(data) == rv.Type().Elem() {
+		rv.Elem().Set(reflect.ValueOf(data))
+		return nil
+	}
+	switch d := data.(type) {
+	case []byte:
+		return json.Unmarshal(d, v)
+	case string:
+		return json.Unmarshal([]byte(d), v)
+	}
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v)
+}
@@ -0,0 +1,21 @@
+package eventutil
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NumberedPlaceholders rewrites ? placeholders of q into $1, $2, ... placeholders used by postgres
+func NumberedPlaceholders(q string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
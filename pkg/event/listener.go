@@ -2,6 +2,10 @@ package event
 
 import (
 	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/pinkgorilla/go-sample/pkg/logger"
 )
 
 // Listener wraps emit and listen function
@@ -11,3 +15,44 @@ type Listener interface {
 
 // ListenerHandler hander function for event listener
 type ListenerHandler func(ctx context.Context, data interface{}) error
+
+// Middleware wraps ListenerHandler with additional behavior
+type Middleware func(next ListenerHandler) ListenerHandler
+
+// Chain wraps handler with middlewares, the first middleware is the outermost
+func Chain(handler ListenerHandler, middlewares ...Middleware) ListenerHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// PanicError is returned by CallHandler when handler panics
+type PanicError struct {
+	Value interface{}
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("event handler panic: %+v", e.Value)
+}
+
+// CallHandler calls handler with data, panic of handler is logged and returned as *PanicError
+// so data is treated as failed instead of killing the calling goroutine
+func CallHandler(ctx context.Context, handler ListenerHandler, data interface{}) (err error) {
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			stack := string(debug.Stack())
+			logger.Log(
+				fmt.Sprintf("Panic: %+v\n", rvr),
+				"event handler",
+				map[string]interface{}{
+					"data": data,
+				},
+				stack,
+			)
+			err = &PanicError{Value: rvr, Stack: stack}
+		}
+	}()
+	return handler(ctx, data)
+}
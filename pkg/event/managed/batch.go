@@ -0,0 +1,72 @@
+package managed
+
+import (
+	"sync"
+	"time"
+)
+
+// batcher collects data added concurrently and flushes them together
+// when batch is full or linger duration is elapsed
+type batcher struct {
+	size    int
+	linger  time.Duration
+	flush   func([]interface{}) error
+	mu      sync.Mutex
+	current *pendingBatch
+}
+
+type pendingBatch struct {
+	data  []interface{}
+	timer *time.Timer
+	done  chan struct{}
+	err   error
+}
+
+func newBatcher(size int, linger time.Duration, flush func([]interface{}) error) *batcher {
+	return &batcher{
+		size:   size,
+		linger: linger,
+		flush:  flush,
+	}
+}
+
+// add adds data to current batch and waits until the batch is flushed,
+// returns error returned by flush
+func (b *batcher) add(data interface{}) error {
+	b.mu.Lock()
+	if b.current == nil {
+		p := &pendingBatch{
+			data: make([]interface{}, 0, b.size),
+			done: make(chan struct{}),
+		}
+		p.timer = time.AfterFunc(b.linger, func() {
+			b.flushPending(p)
+		})
+		b.current = p
+	}
+	p := b.current
+	p.data = append(p.data, data)
+	full := len(p.data) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		b.flushPending(p)
+	}
+	<-p.done
+	return p.err
+}
+
+func (b *batcher) flushPending(p *pendingBatch) {
+	b.mu.Lock()
+	if b.current != p {
+		// already flushed
+		b.mu.Unlock()
+		return
+	}
+	b.current = nil
+	p.timer.Stop()
+	b.mu.Unlock()
+
+	p.err = b.flush(p.data)
+	close(p.done)
+}
@@ -2,11 +2,23 @@ package managed
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
 )
 
+// ErrDelayNotSupported is returned on delayed emit when stream does not implement Delayed
+var ErrDelayNotSupported = errors.New("managed event: stream does not support delayed delivery")
+
+// ErrNotStored is returned on emit when data failed to be pushed to stream and could not be kept in store
+var ErrNotStored = errors.New("managed event: data is not stored for retry")
+
 // DefaultEmitterWatchFunc ...
 var DefaultEmitterWatchFunc = func(ctx context.Context, e *Emitter) {
 	go func() {
@@ -35,11 +47,56 @@ var DefaultEmitterWatchFunc = func(ctx context.Context, e *Emitter) {
 //NewEmitter return new managed event listener
 func NewEmitter(stream Queue, store Queue) *Emitter {
 	// return NewEmitterWithWatchFunc(stream, store, DefaultEmitterWatchFunc)
-	return &Emitter{
+	return NewEmitterWithConfig(stream, store, DefaultEmitterConfig())
+}
+
+// EmitterConfig is managed event emitter configuration
+type EmitterConfig struct {
+	// Name identifies emitter in prometheus metrics, metrics are not reported when empty
+	Name string
+	// BacklogThreshold is store size above which Health reports degraded, 0 disables the check
+	BacklogThreshold int
+	// BatchSize is max number of data pushed to stream in a single round trip,
+	// batching is enabled when BatchSize is greater than 1 and stream implements Batch
+	BatchSize int
+	// Linger is max duration Emit waits for other Emit calls to fill the batch before it is pushed
+	Linger time.Duration
+	// PollInterval is wait duration before pulling store again when store is empty
+	PollInterval time.Duration
+	// Schemas validates emitted data, which must be an event.Message, when not nil.
+	// Invalid data is neither pushed to stream nor stored
+	Schemas *event.Registry
+}
+
+// DefaultEmitterConfig returns default emitter config, batching is disabled
+func DefaultEmitterConfig() *EmitterConfig {
+	return &EmitterConfig{
+		BatchSize:    1,
+		Linger:       10 * time.Millisecond,
+		PollInterval: 100 * time.Millisecond,
+	}
+}
+
+// NewEmitterWithConfig return new managed event emitter with specified config
+func NewEmitterWithConfig(stream Queue, store Queue, config *EmitterConfig) *Emitter {
+	if config == nil {
+		config = DefaultEmitterConfig()
+	}
+	c := *config
+	if c.BatchSize < 1 {
+		c.BatchSize = 1
+	}
+	e := &Emitter{
 		stream: stream,
 		store:  store,
+		config: c,
+		stats:  newStats(componentEmitter, c.Name, stream, store),
 		// ch:     make(chan Event, 9999),
 	}
+	if _, ok := stream.(Batch); ok && c.BatchSize > 1 {
+		e.batcher = newBatcher(c.BatchSize, c.Linger, e.emitBatch)
+	}
+	return e
 }
 
 //NewEmitterWithWatchFunc return new managed event listener with watchFunc
@@ -56,39 +113,131 @@ func NewEmitter(stream Queue, store Queue) *Emitter {
 type Emitter struct {
 	stream  Queue
 	store   Queue // used as storage of failed emit operation, Watch method will Pop this store and try to emit again
-	success int
-	failed  int
+	config  EmitterConfig
+	batcher *batcher
+	stats   *stats
 	once    sync.Once
 }
 
-//Emit emits data
+// Emit emits data, when batching is enabled data is pushed along with data of concurrent Emit calls
 func (e *Emitter) Emit(data interface{}) error {
+	if err := e.validate(data); err != nil {
+		return err
+	}
+	if e.batcher != nil {
+		return e.batcher.add(data)
+	}
+	return e.emit(data)
+}
+
+// EmitBatch emits all data, using a single round trip when stream implements Batch
+func (e *Emitter) EmitBatch(data []interface{}) error {
+	for _, d := range data {
+		if err := e.validate(d); err != nil {
+			return err
+		}
+	}
+	if _, ok := e.stream.(Batch); ok {
+		return e.emitBatch(data)
+	}
+	var err error
+	for _, d := range data {
+		if er := e.emit(d); er != nil && err == nil {
+			err = er
+		}
+	}
+	return err
+}
+
+// emitBatch pushes data to stream, data not pushed to stream is kept in store
+func (e *Emitter) emitBatch(data []interface{}) error {
+	n, err := pushBatch(e.stream, data)
+	e.stats.addSuccess(n)
+	if err != nil {
+		e.stats.addFailed(len(data) - n)
+		if _, er := pushBatch(e.store, data[n:]); er != nil {
+			return fmt.Errorf("%w: %v: %v", ErrNotStored, er, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (e *Emitter) emit(data interface{}) error {
 	err := e.stream.Push(data)
 	if err != nil {
-		e.store.Push(data)
-		e.failed++
+		e.stats.addFailed(1)
+		if er := e.store.Push(data); er != nil {
+			return fmt.Errorf("%w: %v: %v", ErrNotStored, er, err)
+		}
+		return err
+	}
+	e.stats.addSuccess(1)
+	return nil
+}
+
+// EmitAt emits data which becomes visible to listeners at t.
+// On failure data is kept in store until t, returned error wraps ErrNotStored when store
+// does not implement Delayed or fails to keep data, in which case data is lost
+func (e *Emitter) EmitAt(data interface{}, t time.Time) error {
+	if err := e.validate(data); err != nil {
+		return err
+	}
+	stream, ok := e.stream.(Delayed)
+	if !ok {
+		return ErrDelayNotSupported
+	}
+	err := stream.PushAt(data, t)
+	if err != nil {
+		e.stats.addFailed(1)
+		store, ok := e.store.(Delayed)
+		if !ok {
+			return fmt.Errorf("%w: store does not support delayed delivery: %v", ErrNotStored, err)
+		}
+		if er := store.PushAt(data, t); er != nil {
+			return fmt.Errorf("%w: %v: %v", ErrNotStored, er, err)
+		}
 		return err
 	}
-	e.success++
+	e.stats.addSuccess(1)
 	return nil
 }
 
-func (e *Emitter) readStore(ctx context.Context) <-chan Event {
-	ch := make(chan Event, 1)
+func (e *Emitter) validate(data interface{}) error {
+	if e.config.Schemas == nil {
+		return nil
+	}
+	return e.config.Schemas.Validate(data)
+}
+
+// EmitAfter emits data which becomes visible to listeners after d
+func (e *Emitter) EmitAfter(data interface{}, d time.Duration) error {
+	return e.EmitAt(data, time.Now().Add(d))
+}
+
+func (e *Emitter) readStore(ctx context.Context) <-chan batchEvent {
+	ch := make(chan batchEvent, 1)
 	go func() {
-		go func() {
-			for {
-				data, err := e.store.Pull()
-				if data == nil && err == nil {
-					<-time.After(100 * time.Millisecond)
-					continue
+		for {
+			data, err := pullBatch(e.store, e.config.BatchSize)
+			if len(data) == 0 && err == nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(e.config.PollInterval):
+				}
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				// put pulled data back so it is not lost
+				if _, err := pushBatch(e.store, data); err != nil {
+					log.Println("managed: failed to put data back to store", data, err)
 				}
-				ch <- Event{data, err}
+				return
+			case ch <- batchEvent{data, err}:
 			}
-		}()
-		<-ctx.Done()
-		close(ch)
-		return
+		}
 	}()
 	return ch
 }
@@ -104,8 +253,9 @@ func (e *Emitter) Watch(ctx context.Context) {
 			if store.err != nil {
 				log.Println(store.err)
 			}
-			if store.data != nil {
-				err := e.Emit(store.data)
+			if len(store.data) > 0 {
+				e.stats.addRetried(len(store.data))
+				err := e.EmitBatch(store.data)
 				if err != nil {
 					log.Println(err)
 				}
@@ -121,16 +271,32 @@ func (e *Emitter) Store() Queue {
 
 //Dispose release resources used by emitter
 func (e *Emitter) Dispose() {
+	e.stats.unregister()
 	e.stream.Dispose()
 	e.store.Dispose()
 }
 
 // Success returns count for success emit
 func (e *Emitter) Success() int {
-	return e.success
+	return int(atomic.LoadInt64(&e.stats.success))
 }
 
 // Failed returns count for failed emit
 func (e *Emitter) Failed() int {
-	return e.failed
+	return int(atomic.LoadInt64(&e.stats.failed))
+}
+
+// Retried returns count of data emitted again from store
+func (e *Emitter) Retried() int {
+	return int(atomic.LoadInt64(&e.stats.retried))
+}
+
+// Health reports degraded when store backlog is greater than BacklogThreshold
+func (e *Emitter) Health() Health {
+	return checkHealth(e.store, e.config.BacklogThreshold)
+}
+
+// HealthHandler is http handler for emitter health
+func (e *Emitter) HealthHandler() http.Handler {
+	return healthHandler(e.Health)
 }
@@ -2,10 +2,14 @@ package managed_test
 
 import (
 	"context"
+	goerrors "errors"
 	"log"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+	"github.com/pinkgorilla/go-sample/pkg/event"
 	"github.com/pinkgorilla/go-sample/pkg/event/managed"
 )
 
@@ -67,6 +71,267 @@ func Test_Emitter_FailingQueueNewFailingQueue(t *testing.T) {
 	emitter.Dispose()
 }
 
+func Test_Emitter_Batch(t *testing.T) {
+	n := 10
+	s := NewSlowQueue(time.Millisecond)
+	emitter := managed.NewEmitterWithConfig(s, managed.NewInMemoryQueue(), &managed.EmitterConfig{
+		BatchSize: n,
+		Linger:    time.Second,
+	})
+
+	// concurrent emits should be pushed in a single round trip once the batch is full
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := emitter.Emit(i); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if s.RoundTrips() != 1 {
+		t.Fatalf("expected 1 round trip, got %v", s.RoundTrips())
+	}
+	if emitter.Success() != n {
+		t.Fatalf("expected success count %v, got %v", n, emitter.Success())
+	}
+
+	// partial batch should be pushed after linger
+	start := time.Now()
+	if err := emitter.Emit(n); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < time.Second {
+		t.Fatal("expected emit to wait for linger")
+	}
+	if size, _ := s.Size(); size != n+1 {
+		t.Fatalf("expected size %v, got %v", n+1, size)
+	}
+}
+
+// limitedQueue is a queue accepting limit pushes, later pushes fail
+type limitedQueue struct {
+	queue *managed.InMemoryQueue
+	limit int
+	mu    sync.Mutex
+}
+
+func newLimitedQueue(limit int) *limitedQueue {
+	return &limitedQueue{queue: managed.NewInMemoryQueue(), limit: limit}
+}
+
+func (q *limitedQueue) Push(data interface{}) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.limit == 0 {
+		return goerrors.New("queue is full")
+	}
+	q.limit--
+	return q.queue.Push(data)
+}
+
+func (q *limitedQueue) Pull() (interface{}, error) { return q.queue.Pull() }
+func (q *limitedQueue) Size() (int, error)         { return q.queue.Size() }
+func (q *limitedQueue) Dispose()                   {}
+
+// limitedBatchQueue is a limitedQueue pushing batches in a single round trip
+type limitedBatchQueue struct {
+	*limitedQueue
+}
+
+func (q limitedBatchQueue) PushBatch(data []interface{}) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.limit < len(data) {
+		return goerrors.New("queue is full")
+	}
+	q.limit -= len(data)
+	return q.queue.PushBatch(data)
+}
+
+func (q limitedBatchQueue) PullBatch(n int) ([]interface{}, error) { return q.queue.PullBatch(n) }
+
+func Test_Emitter_StoreFailure(t *testing.T) {
+	emit := func(emitter *managed.Emitter, n int) []error {
+		errs := make([]error, n)
+		wg := sync.WaitGroup{}
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = emitter.Emit(i)
+			}(i)
+		}
+		wg.Wait()
+		return errs
+	}
+	config := &managed.EmitterConfig{BatchSize: 3, Linger: time.Second}
+
+	store := newLimitedQueue(3)
+	emitter := managed.NewEmitterWithConfig(limitedBatchQueue{newLimitedQueue(0)}, store, config)
+	for _, err := range emit(emitter, 3) {
+		if err == nil || goerrors.Is(err, managed.ErrNotStored) {
+			t.Fatalf("expected push error, got %v", err)
+		}
+	}
+	if size, _ := store.Size(); size != 3 || emitter.Failed() != 3 {
+		t.Fatalf("expected batch kept in store, got %v data and %v failures", size, emitter.Failed())
+	}
+
+	// store accepting part of the batch
+	store = newLimitedQueue(1)
+	emitter = managed.NewEmitterWithConfig(limitedBatchQueue{newLimitedQueue(0)}, store, config)
+	for _, err := range emit(emitter, 3) {
+		if !goerrors.Is(err, managed.ErrNotStored) {
+			t.Fatalf("expected ErrNotStored, got %v", err)
+		}
+	}
+	if size, _ := store.Size(); size != 1 {
+		t.Fatalf("expected 1 data in store, got %v", size)
+	}
+
+	err := managed.NewEmitter(newLimitedQueue(0), newLimitedQueue(0)).Emit(1)
+	if !goerrors.Is(err, managed.ErrNotStored) {
+		t.Fatalf("expected ErrNotStored, got %v", err)
+	}
+}
+
+func Test_Emitter_EmitAfter(t *testing.T) {
+	s := managed.NewInMemoryQueue()
+	emitter := managed.NewEmitter(s, managed.NewInMemoryQueue())
+	listener := managed.NewListenerWithConfig(s, managed.NewInMemoryQueue(), &managed.ListenerConfig{
+		PollInterval: 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	delay := 300 * time.Millisecond
+	start := time.Now()
+	err := emitter.EmitAfter(1, delay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handled := make(chan time.Time, 1)
+	go listener.Listen(ctx, func(ctx context.Context, data interface{}) error {
+		handled <- time.Now()
+		return nil
+	})
+	select {
+	case <-ctx.Done():
+		t.Fatal("delayed data was not handled")
+	case at := <-handled:
+		if at.Sub(start) < delay {
+			t.Fatalf("expected data handled after %v, got %v", delay, at.Sub(start))
+		}
+	}
+
+	err = managed.NewEmitter(managed.NewChannelQueue(), managed.NewInMemoryQueue()).EmitAfter(1, delay)
+	if err != managed.ErrDelayNotSupported {
+		t.Fatalf("expected ErrDelayNotSupported, got %v", err)
+	}
+}
+
+// failingDelayedQueue is a delayed stream failing on every delayed push
+type failingDelayedQueue struct {
+	*FailingQueueNewFailingQueue
+}
+
+func (s failingDelayedQueue) PushAt(data interface{}, t time.Time) error {
+	return goerrors.New("push failed")
+}
+
+func Test_Emitter_EmitAt_Failing(t *testing.T) {
+	stream := failingDelayedQueue{NewFailingQueue()}
+	at := time.Now().Add(50 * time.Millisecond)
+
+	store := managed.NewInMemoryQueue()
+	err := managed.NewEmitter(stream, store).EmitAt(1, at)
+	if err == nil || goerrors.Is(err, managed.ErrNotStored) {
+		t.Fatalf("expected push error, got %v", err)
+	}
+	if data, _ := store.Pull(); data != nil {
+		t.Fatalf("expected data held in store until %v, got %v", at, data)
+	}
+	time.Sleep(time.Until(at))
+	if data, _ := store.Pull(); data != 1 {
+		t.Fatalf("expected data kept in store, got %v", data)
+	}
+
+	err = managed.NewEmitter(stream, NewFailingQueue()).EmitAt(1, at)
+	if !goerrors.Is(err, managed.ErrNotStored) {
+		t.Fatalf("expected ErrNotStored for store without delay support, got %v", err)
+	}
+	err = managed.NewEmitter(stream, failingDelayedQueue{NewFailingQueue()}).EmitAt(1, at)
+	if !goerrors.Is(err, managed.ErrNotStored) {
+		t.Fatalf("expected ErrNotStored for failing store, got %v", err)
+	}
+}
+
+func Test_Emitter_Schemas(t *testing.T) {
+	schemas := event.NewRegistry(event.CompatibilityBackward)
+	err := schemas.Register("user.created", 1, []byte(`{"type":"object","required":["id"],"properties":{"id":{"type":"integer"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := managed.NewChannelQueue()
+	store := managed.NewChannelQueue()
+	config := managed.DefaultEmitterConfig()
+	config.Schemas = schemas
+	emitter := managed.NewEmitterWithConfig(s, store, config)
+
+	err = emitter.Emit(event.NewMessage("user.created", 1, map[string]interface{}{"id": "1"}))
+	e, ok := err.(errors.ValidationError)
+	if !ok || !e.HasFieldError("id") {
+		t.Fatalf("expected ValidationError on id, got %v", err)
+	}
+	err = emitter.EmitBatch([]interface{}{event.NewMessage("user.created", 1, map[string]interface{}{"id": 1}), 1})
+	if _, ok := err.(errors.ValidationError); !ok {
+		t.Fatalf("expected ValidationError on missing envelope, got %v", err)
+	}
+	if size, _ := s.Size(); size != 0 {
+		t.Fatalf("expected invalid data not to be pushed, got stream size %v", size)
+	}
+	if size, _ := store.Size(); size != 0 {
+		t.Fatalf("expected invalid data not to be stored, got store size %v", size)
+	}
+	if err := emitter.Emit(event.NewMessage("user.created", 1, map[string]interface{}{"id": 1})); err != nil {
+		t.Fatal(err)
+	}
+	if size, _ := s.Size(); size != 1 {
+		t.Fatalf("expected stream size 1, got %v", size)
+	}
+}
+
+func benchmarkEmitter(b *testing.B, config *managed.EmitterConfig) {
+	s := NewSlowQueue(time.Millisecond)
+	emitter := managed.NewEmitterWithConfig(s, managed.NewInMemoryQueue(), config)
+	b.SetParallelism(100)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := emitter.Emit(1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.ReportMetric(float64(s.RoundTrips())/float64(b.N), "roundtrips/op")
+}
+
+func Benchmark_Emitter_Emit(b *testing.B) {
+	benchmarkEmitter(b, managed.DefaultEmitterConfig())
+}
+
+func Benchmark_Emitter_EmitBatch(b *testing.B) {
+	benchmarkEmitter(b, &managed.EmitterConfig{
+		BatchSize: 100,
+		Linger:    5 * time.Millisecond,
+	})
+}
+
 func Test_Ch(t *testing.T) {
 	ch := make(chan int, 1)
 	ctr1 := 0
@@ -0,0 +1,59 @@
+package managed
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Health status of managed emitter and listener
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+)
+
+// Health is health report of managed emitter or listener
+type Health struct {
+	Status string `json:"status"`
+	// StoreBacklog is number of data waiting in store, -1 when store does not implement Size
+	StoreBacklog int    `json:"storeBacklog"`
+	Threshold    int    `json:"threshold"`
+	Error        string `json:"error,omitempty"`
+}
+
+// checkHealth reports degraded when store backlog is greater than threshold,
+// threshold less than 1 disables the check
+func checkHealth(store Queue, threshold int) Health {
+	h := Health{
+		Status:       HealthOK,
+		StoreBacklog: -1,
+		Threshold:    threshold,
+	}
+	s, ok := store.(Size)
+	if !ok {
+		return h
+	}
+	size, err := s.Size()
+	if err != nil {
+		h.Status = HealthDegraded
+		h.Error = err.Error()
+		return h
+	}
+	h.StoreBacklog = size
+	if threshold > 0 && size > threshold {
+		h.Status = HealthDegraded
+	}
+	return h
+}
+
+// healthHandler is http handler writing health report,
+// responds with 503 status code when health is degraded
+func healthHandler(fn func() Health) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := fn()
+		w.Header().Set("Content-Type", "application/json")
+		if h.Status != HealthOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+}
@@ -3,7 +3,9 @@ package managed
 import (
 	"context"
 	"log"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pinkgorilla/go-sample/pkg/event"
@@ -15,11 +17,55 @@ type Event struct {
 	err  error
 }
 
+// batchEvent is a type used for interprocess communication of batched data
+type batchEvent struct {
+	data []interface{}
+	err  error
+}
+
 //NewListener return new managed event listener
 func NewListener(stream Queue, store Queue) *Listener {
+	return NewListenerWithConfig(stream, store, DefaultListenerConfig())
+}
+
+// ListenerConfig is managed event listener configuration
+type ListenerConfig struct {
+	// Name identifies listener in prometheus metrics, metrics are not reported when empty
+	Name string
+	// BacklogThreshold is store size above which Health reports degraded, 0 disables the check
+	BacklogThreshold int
+	// BatchSize is max number of data pulled from stream and store in a single round trip,
+	// batching is used when BatchSize is greater than 1 and the queue implements Batch
+	BatchSize int
+	// PollInterval is wait duration before pulling again when stream or store is empty
+	PollInterval time.Duration
+	// Schemas validates received data, which must be an event.Message, when not nil.
+	// Invalid data is counted as failed and dropped without calling handler
+	Schemas *event.Registry
+}
+
+// DefaultListenerConfig returns default listener config, batching is disabled
+func DefaultListenerConfig() *ListenerConfig {
+	return &ListenerConfig{
+		BatchSize:    1,
+		PollInterval: 100 * time.Millisecond,
+	}
+}
+
+// NewListenerWithConfig return new managed event listener with specified config
+func NewListenerWithConfig(stream Queue, store Queue, config *ListenerConfig) *Listener {
+	if config == nil {
+		config = DefaultListenerConfig()
+	}
+	c := *config
+	if c.BatchSize < 1 {
+		c.BatchSize = 1
+	}
 	return &Listener{
 		stream: stream,
 		store:  store,
+		config: c,
+		stats:  newStats(componentListener, c.Name, stream, store),
 	}
 }
 
@@ -27,45 +73,38 @@ func NewListener(stream Queue, store Queue) *Listener {
 type Listener struct {
 	stream       Queue
 	store        Queue
-	success      int
-	failed       int
-	mutex        sync.Mutex
+	config       ListenerConfig
+	stats        *stats
 	storeCounter sync.Map
 	once         sync.Once
 }
 
-//f is a routine listening for events
-func (e *Listener) count(i *int) {
-	e.mutex.Lock()
-	defer e.mutex.Unlock()
-	*i++
-}
-
-func (e *Listener) readStream(ctx context.Context) <-chan interface{} {
-	ch := make(chan interface{}, 1)
+func (e *Listener) readStream(ctx context.Context) <-chan []interface{} {
+	ch := make(chan []interface{}, 1)
 	go func() {
-		go func() {
-			for {
-				data, err := e.stream.Pull()
-				if data == nil && err == nil {
-					<-time.After(100 * time.Millisecond)
-					continue
-				}
-				if err != nil {
-					e.count(&e.failed)
-					<-time.After(100 * time.Millisecond)
-					continue
+		for {
+			data, err := pullBatch(e.stream, e.config.BatchSize)
+			if err != nil {
+				e.stats.addFailed(1)
+			}
+			if len(data) == 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(e.config.PollInterval):
 				}
-				if data == nil {
-					<-time.After(100 * time.Millisecond)
-					continue
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				// keep pulled data in store so it is handled on next Listen
+				if _, err := pushBatch(e.store, data); err != nil {
+					log.Println("managed: failed to keep data in store", data, err)
 				}
-				ch <- data
+				return
+			case ch <- data:
 			}
-		}()
-		<-ctx.Done()
-		close(ch)
-		return
+		}
 	}()
 	return ch
 }
@@ -73,20 +112,38 @@ func (e *Listener) readStream(ctx context.Context) <-chan interface{} {
 func (e *Listener) readStore(ctx context.Context) <-chan Event {
 	ch := make(chan Event, 1)
 	go func() {
-		go func() {
-			for {
-				// log.Println(e.store)
-				data, err := e.store.Pull()
-				if data == nil && err == nil {
-					<-time.After(100 * time.Millisecond)
-					continue
+		for {
+			data, err := pullBatch(e.store, e.config.BatchSize)
+			if len(data) == 0 && err == nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(e.config.PollInterval):
 				}
-				ch <- Event{data, err}
+				continue
 			}
-		}()
-		<-ctx.Done()
-		close(ch)
-		return
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					if _, err := pushBatch(e.store, data); err != nil {
+						log.Println("managed: failed to put data back to store", data, err)
+					}
+					return
+				case ch <- Event{nil, err}:
+				}
+			}
+			for i, d := range data {
+				select {
+				case <-ctx.Done():
+					// put pulled data back so it is not lost
+					if _, err := pushBatch(e.store, data[i:]); err != nil {
+						log.Println("managed: failed to put data back to store", data[i:], err)
+					}
+					return
+				case ch <- Event{d, nil}:
+				}
+			}
+		}
 	}()
 	return ch
 }
@@ -107,21 +164,34 @@ func (e *Listener) Listen(ctx context.Context, handler event.ListenerHandler) {
 					wg.Done()
 					return
 				case data := <-stream:
-					err := e.store.Push(data)
+					n, err := pushBatch(e.store, data)
 					if err != nil {
-						log.Println("failed to push data", data, err)
-						e.count(&e.failed)
+						log.Println("failed to push data", data[n:], err)
+						e.stats.addFailed(len(data) - n)
 					}
 				case data := <-store:
 					if data.err != nil {
-						e.count(&e.failed)
+						e.stats.addFailed(1)
+					}
+					if data.data == nil {
+						continue
+					}
+					if e.config.Schemas != nil {
+						if err := e.config.Schemas.Validate(data.data); err != nil {
+							log.Println("dropping invalid data", data.data, err)
+							e.stats.addFailed(1)
+							continue
+						}
 					}
-					err := handler(ctx, data.data)
+					start := time.Now()
+					err := event.CallHandler(ctx, handler, data.data)
+					e.stats.observeHandler(time.Since(start))
 					if err != nil {
-						e.count(&e.failed)
+						e.stats.addFailed(1)
+						e.stats.addRetried(1)
 						e.store.Push(data.data)
 					} else {
-						e.count(&e.success)
+						e.stats.addSuccess(1)
 					}
 				}
 			}
@@ -137,16 +207,32 @@ func (e *Listener) Store() Queue {
 
 //Dispose release resources used by listener
 func (e *Listener) Dispose() {
+	e.stats.unregister()
 	e.stream.Dispose()
 	e.store.Dispose()
 }
 
 // Success returns count for success emit
 func (e *Listener) Success() int {
-	return e.success
+	return int(atomic.LoadInt64(&e.stats.success))
 }
 
 // Failed returns count for failed emit
 func (e *Listener) Failed() int {
-	return e.failed
+	return int(atomic.LoadInt64(&e.stats.failed))
+}
+
+// Retried returns count of data pushed back to store after handler failure
+func (e *Listener) Retried() int {
+	return int(atomic.LoadInt64(&e.stats.retried))
+}
+
+// Health reports degraded when store backlog is greater than BacklogThreshold
+func (e *Listener) Health() Health {
+	return checkHealth(e.store, e.config.BacklogThreshold)
+}
+
+// HealthHandler is http handler for listener health
+func (e *Listener) HealthHandler() http.Handler {
+	return healthHandler(e.Health)
 }
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pinkgorilla/go-sample/pkg/event"
 	"github.com/pinkgorilla/go-sample/pkg/event/managed"
 )
 
@@ -76,3 +77,119 @@ func Test_Listener_FailingHandler(t *testing.T) {
 	emitter.Dispose()
 	listener.Dispose()
 }
+
+func Test_Listener_Batch(t *testing.T) {
+	n := 100
+	s := NewSlowQueue(time.Millisecond)
+	emitter := managed.NewEmitterWithConfig(s, managed.NewInMemoryQueue(), &managed.EmitterConfig{
+		BatchSize: 10,
+		Linger:    10 * time.Millisecond,
+	})
+	listener := managed.NewListenerWithConfig(s, managed.NewInMemoryQueue(), &managed.ListenerConfig{
+		BatchSize:    10,
+		PollInterval: 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+
+	data := []interface{}{}
+	for i := 0; i < n; i++ {
+		data = append(data, i)
+	}
+	err := emitter.EmitBatch(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handled := make(chan interface{}, n)
+	go listener.Listen(ctx, func(ctx context.Context, data interface{}) error {
+		handled <- data
+		return nil
+	})
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected %v handled data, got %v", n, i)
+		case <-handled:
+		}
+	}
+	cancel()
+	// 1 push round trip, 10 pull round trips and a few empty polls
+	if s.RoundTrips() > 20 {
+		t.Fatalf("expected batched round trips, got %v", s.RoundTrips())
+	}
+}
+
+func Test_Listener_Schemas(t *testing.T) {
+	schemas := event.NewRegistry(event.CompatibilityBackward)
+	err := schemas.Register("user.created", 1, []byte(`{"type":"object","required":["id"],"properties":{"id":{"type":"integer"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := managed.NewChannelQueue()
+	emitter := managed.NewEmitter(s, managed.NewChannelQueue())
+	listener := managed.NewListenerWithConfig(s, managed.NewChannelQueue(), &managed.ListenerConfig{
+		PollInterval: 10 * time.Millisecond,
+		Schemas:      schemas,
+	})
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	emitter.Emit(event.NewMessage("user.created", 1, map[string]interface{}{"name": "no id"}))
+	emitter.Emit(event.NewMessage("user.created", 1, map[string]interface{}{"id": 1}))
+
+	handled := make(chan interface{}, 2)
+	go listener.Listen(ctx, func(ctx context.Context, data interface{}) error {
+		handled <- data
+		return nil
+	})
+	select {
+	case <-ctx.Done():
+		t.Fatal("expected valid data to be handled")
+	case data := <-handled:
+		if data.(event.Message).Data.(map[string]interface{})["id"] != 1 {
+			t.Fatalf("unexpected handled data %v", data)
+		}
+	}
+	select {
+	case data := <-handled:
+		t.Fatalf("expected invalid data to be dropped, got %v", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if listener.Failed() != 1 {
+		t.Fatalf("expected failed count 1, got %v", listener.Failed())
+	}
+}
+
+func Test_Listener_HandlerPanic(t *testing.T) {
+	s := managed.NewChannelQueue()
+	emitter := managed.NewEmitter(s, managed.NewChannelQueue())
+	listener := managed.NewListenerWithConfig(s, managed.NewChannelQueue(), &managed.ListenerConfig{
+		PollInterval: 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	emitter.Emit(1)
+	handled := make(chan interface{}, 1)
+	calls := 0
+	go listener.Listen(ctx, func(ctx context.Context, data interface{}) error {
+		calls++
+		if calls == 1 {
+			panic("handler panic")
+		}
+		handled <- data
+		return nil
+	})
+	select {
+	case <-ctx.Done():
+		t.Fatal("expected panicking data to be retried")
+	case <-handled:
+	}
+	if listener.Failed() != 1 || listener.Retried() != 1 {
+		t.Fatalf("expected failed and retried count 1, got %v %v", listener.Failed(), listener.Retried())
+	}
+}
@@ -0,0 +1,154 @@
+package managed
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	componentEmitter  = "emitter"
+	componentListener = "listener"
+)
+
+var (
+	metricsOnce sync.Once
+
+	publishedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "managed_event_emitter_published_total",
+			Help: "A counter for data pushed to stream by managed event emitter.",
+		},
+		[]string{"name"},
+	)
+	handledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "managed_event_listener_handled_total",
+			Help: "A counter for data successfully handled by managed event listener.",
+		},
+		[]string{"name"},
+	)
+	failedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "managed_event_failed_total",
+			Help: "A counter for failed push, pull and handler calls of managed event emitter and listener.",
+		},
+		[]string{"component", "name"},
+	)
+	retriedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "managed_event_retried_total",
+			Help: "A counter for data retried from store by managed event emitter and listener.",
+		},
+		[]string{"component", "name"},
+	)
+	handlerDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "managed_event_listener_handler_duration_seconds",
+			Help:    "A histogram of managed event listener handler latencies.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"name"},
+	)
+)
+
+func registerMetrics() {
+	metricsOnce.Do(func() {
+		prometheus.MustRegister(publishedTotal, handledTotal, failedTotal, retriedTotal, handlerDuration)
+	})
+}
+
+// stats counts outcomes of managed emitter or listener,
+// counts are reported to prometheus when name is not empty
+type stats struct {
+	component string
+	name      string
+	success   int64
+	failed    int64
+	retried   int64
+	depth     []prometheus.Collector
+}
+
+// newStats returns new stats, queue depth of stream and store is reported
+// as managed_event_queue_depth gauge when the queue implements Size
+func newStats(component, name string, stream, store Queue) *stats {
+	s := &stats{
+		component: component,
+		name:      name,
+	}
+	if name == "" {
+		return s
+	}
+	registerMetrics()
+	queues := map[string]Queue{"stream": stream, "store": store}
+	for label, q := range queues {
+		if _, ok := q.(Size); !ok {
+			continue
+		}
+		gauge := depthGauge(component, name, label, q)
+		if err := prometheus.Register(gauge); err == nil {
+			s.depth = append(s.depth, gauge)
+		}
+	}
+	return s
+}
+
+func depthGauge(component, name, queue string, q Queue) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "managed_event_queue_depth",
+			Help:        "Number of data in stream and store queues of managed event emitter and listener.",
+			ConstLabels: prometheus.Labels{"component": component, "name": name, "queue": queue},
+		},
+		func() float64 {
+			size, err := q.(Size).Size()
+			if err != nil {
+				return -1
+			}
+			return float64(size)
+		},
+	)
+}
+
+func (s *stats) addSuccess(n int) {
+	atomic.AddInt64(&s.success, int64(n))
+	if s.name == "" {
+		return
+	}
+	switch s.component {
+	case componentEmitter:
+		publishedTotal.WithLabelValues(s.name).Add(float64(n))
+	case componentListener:
+		handledTotal.WithLabelValues(s.name).Add(float64(n))
+	}
+}
+
+func (s *stats) addFailed(n int) {
+	atomic.AddInt64(&s.failed, int64(n))
+	if s.name != "" {
+		failedTotal.WithLabelValues(s.component, s.name).Add(float64(n))
+	}
+}
+
+func (s *stats) addRetried(n int) {
+	atomic.AddInt64(&s.retried, int64(n))
+	if s.name != "" {
+		retriedTotal.WithLabelValues(s.component, s.name).Add(float64(n))
+	}
+}
+
+func (s *stats) observeHandler(d time.Duration) {
+	if s.name != "" {
+		handlerDuration.WithLabelValues(s.name).Observe(d.Seconds())
+	}
+}
+
+// unregister unregisters queue depth gauges
+func (s *stats) unregister() {
+	for _, c := range s.depth {
+		prometheus.Unregister(c)
+	}
+	s.depth = nil
+}
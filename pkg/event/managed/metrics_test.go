@@ -0,0 +1,161 @@
+package managed_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pinkgorilla/go-sample/pkg/event/managed"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gatherMetric returns value of registered metric with matching labels
+func gatherMetric(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+	next:
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if v, ok := labels[l.GetName()]; ok && v != l.GetValue() {
+					continue next
+				}
+			}
+			switch f.GetType() {
+			case dto.MetricType_COUNTER:
+				return m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				return m.GetGauge().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				return float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	t.Fatalf("metric %s %v not found", name, labels)
+	return 0
+}
+
+func Test_Emitter_Metrics_Health(t *testing.T) {
+	s := NewFailingQueue()
+	store := managed.NewInMemoryQueue()
+	emitter := managed.NewEmitterWithConfig(s, store, &managed.EmitterConfig{
+		Name:             "metrics-emitter",
+		BacklogThreshold: 1,
+	})
+	defer emitter.Dispose()
+
+	// push fails for the first time, data is kept in store
+	emitter.Emit(1)
+	emitter.Emit(2)
+	if emitter.Failed() != 2 {
+		t.Fatalf("expected failed count 2, got %v", emitter.Failed())
+	}
+	labels := map[string]string{"component": "emitter", "name": "metrics-emitter"}
+	if v := gatherMetric(t, "managed_event_failed_total", labels); v != 2 {
+		t.Fatalf("expected failed metric 2, got %v", v)
+	}
+	if v := gatherMetric(t, "managed_event_queue_depth", map[string]string{"name": "metrics-emitter", "queue": "store"}); v != 2 {
+		t.Fatalf("expected store depth 2, got %v", v)
+	}
+
+	h := emitter.Health()
+	if h.Status != managed.HealthDegraded || h.StoreBacklog != 2 {
+		t.Fatalf("expected degraded health with backlog 2, got %+v", h)
+	}
+	res := httptest.NewRecorder()
+	emitter.HealthHandler().ServeHTTP(res, httptest.NewRequest("GET", "/health", nil))
+	if res.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %v, got %v", http.StatusServiceUnavailable, res.Code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go emitter.Watch(ctx)
+	for emitter.Success() != 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected success count 2, got %v", emitter.Success())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if emitter.Retried() != 2 {
+		t.Fatalf("expected retried count 2, got %v", emitter.Retried())
+	}
+	if v := gatherMetric(t, "managed_event_emitter_published_total", map[string]string{"name": "metrics-emitter"}); v != 2 {
+		t.Fatalf("expected published metric 2, got %v", v)
+	}
+	if h := emitter.Health(); h.Status != managed.HealthOK {
+		t.Fatalf("expected ok health, got %+v", h)
+	}
+}
+
+func Test_Listener_Metrics(t *testing.T) {
+	s := managed.NewChannelQueue()
+	listener := managed.NewListenerWithConfig(s, managed.NewInMemoryQueue(), &managed.ListenerConfig{
+		Name:         "metrics-listener",
+		PollInterval: 10 * time.Millisecond,
+	})
+	defer listener.Dispose()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.Push(1)
+	failed := false
+	go listener.Listen(ctx, func(ctx context.Context, data interface{}) error {
+		if !failed {
+			failed = true
+			return errors.New("fail once")
+		}
+		return nil
+	})
+	for listener.Success() != 1 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("expected data to be handled")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if listener.Retried() != 1 {
+		t.Fatalf("expected retried count 1, got %v", listener.Retried())
+	}
+	labels := map[string]string{"name": "metrics-listener"}
+	if v := gatherMetric(t, "managed_event_listener_handled_total", labels); v != 1 {
+		t.Fatalf("expected handled metric 1, got %v", v)
+	}
+	if v := gatherMetric(t, "managed_event_listener_handler_duration_seconds", labels); v != 2 {
+		t.Fatalf("expected 2 handler observations, got %v", v)
+	}
+}
+
+func Test_Listener_Metrics_EmptyRedisQueue(t *testing.T) {
+	r := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	s := managed.NewRedisQueue(r)
+	defer s.Dispose()
+	listener := managed.NewListenerWithConfig(s, managed.NewInMemoryQueue(), &managed.ListenerConfig{
+		Name:         "empty-redis-listener",
+		PollInterval: 10 * time.Millisecond,
+	})
+	defer listener.Dispose()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	listener.Listen(ctx, func(ctx context.Context, data interface{}) error {
+		return nil
+	})
+	if listener.Failed() != 0 {
+		t.Fatalf("expected empty queue not counted as failure, got %v", listener.Failed())
+	}
+}
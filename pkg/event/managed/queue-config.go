@@ -0,0 +1,72 @@
+package managed
+
+import (
+	"fmt"
+
+	"github.com/go-redis/redis"
+)
+
+// Queue drivers supported by NewQueueFromConfig
+const (
+	DriverMemory  = "memory"
+	DriverChannel = "channel"
+	DriverRedis   = "redis"
+	DriverGoogle  = "google"
+	DriverKafka   = "kafka"
+	DriverNATS    = "nats"
+)
+
+// QueueConfig is configuration used to construct a Queue,
+// only configuration of the selected Driver is used
+type QueueConfig struct {
+	Driver string           `yaml:"driver" json:"driver"`
+	Redis  RedisQueueConfig `yaml:"redis" json:"redis"`
+	Google GQueueConfig     `yaml:"google" json:"google"`
+	Kafka  KafkaQueueConfig `yaml:"kafka" json:"kafka"`
+	NATS   NATSQueueConfig  `yaml:"nats" json:"nats"`
+}
+
+// RedisQueueConfig is configuration of RedisQueue
+type RedisQueueConfig struct {
+	Addr string `yaml:"addr" json:"addr"`
+	// Key is redis list key, random key is used when empty
+	Key string `yaml:"key" json:"key"`
+}
+
+// NewQueueFromConfig returns Queue implementation of configured driver
+func NewQueueFromConfig(config QueueConfig) (Queue, error) {
+	switch config.Driver {
+	case DriverMemory:
+		return NewInMemoryQueue(), nil
+	case DriverChannel:
+		return NewChannelQueue(), nil
+	case DriverRedis:
+		q := NewRedisQueue(redis.NewClient(&redis.Options{
+			Addr: config.Redis.Addr,
+		}))
+		if config.Redis.Key != "" {
+			q.key = config.Redis.Key
+		}
+		return q, nil
+	case DriverGoogle:
+		q, err := NewGQueueWithConfig(config.Google)
+		if err != nil {
+			return nil, err
+		}
+		return q, nil
+	case DriverKafka:
+		q, err := NewKafkaQueue(config.Kafka)
+		if err != nil {
+			return nil, err
+		}
+		return q, nil
+	case DriverNATS:
+		q, err := NewNATSQueue(config.NATS)
+		if err != nil {
+			return nil, err
+		}
+		return q, nil
+	default:
+		return nil, fmt.Errorf("unknown queue driver: '%s'", config.Driver)
+	}
+}
@@ -11,71 +11,163 @@ import (
 	"cloud.google.com/go/pubsub"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
-// NewGQueue ...
+// GQueueConfig is configuration of GQueue
+type GQueueConfig struct {
+	TopicID        string `yaml:"topicId" json:"topicId"`
+	SubscriptionID string `yaml:"subscriptionId" json:"subscriptionId"`
+	ProjectID      string `yaml:"projectId" json:"projectId"`
+	// Credential is service account json, ignored when EmulatorHost is set
+	Credential string `yaml:"credential" json:"credential"`
+	// AutoCreate creates topic and subscription when they do not exist
+	AutoCreate bool `yaml:"autoCreate" json:"autoCreate"`
+	// AckDeadline is ack deadline of created subscription, pub/sub default is used when zero
+	AckDeadline time.Duration `yaml:"ackDeadline" json:"ackDeadline"`
+	// MaxOutstandingMessages is max number of unacknowledged messages received by subscription,
+	// pub/sub default is used when zero
+	MaxOutstandingMessages int `yaml:"maxOutstandingMessages" json:"maxOutstandingMessages"`
+	// OrderingKey returns ordering key of pushed data, message ordering is enabled when it is set.
+	// Existing subscription must have message ordering enabled the same
+	OrderingKey func(data interface{}) string `yaml:"-" json:"-"`
+	// EmulatorHost is address of pub/sub emulator, e.g. localhost:8085
+	EmulatorHost string `yaml:"emulatorHost" json:"emulatorHost"`
+}
+
+// DefaultGQueueConfig returns default GQueue config
+func DefaultGQueueConfig(topicID, subscriptionID, projectID, credential string) GQueueConfig {
+	return GQueueConfig{
+		TopicID:        topicID,
+		SubscriptionID: subscriptionID,
+		ProjectID:      projectID,
+		Credential:     credential,
+		AutoCreate:     true,
+	}
+}
+
+// NewGQueue returns new GQueue, creates topic and subscription when they do not exist.
+// It panics on error, use NewGQueueWithConfig to handle the error
 func NewGQueue(topicID, subscriptionID, projectID, credential string) *GQueue {
-	ctx, cancel := context.WithCancel(context.Background())
-	credentials, err := google.CredentialsFromJSON(ctx, []byte(credential), pubsub.ScopePubSub)
+	q, err := NewGQueueWithConfig(DefaultGQueueConfig(topicID, subscriptionID, projectID, credential))
 	if err != nil {
 		panic(err)
 	}
+	return q
+}
 
-	client, err := pubsub.NewClient(
-		ctx,
-		projectID,
-		option.WithCredentials(credentials),
-	)
+// NewGQueueWithConfig returns new GQueue with specified config
+func NewGQueueWithConfig(config GQueueConfig) (*GQueue, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	q, err := newGQueue(ctx, config)
 	if err != nil {
-		panic(err)
+		cancel()
+		return nil, err
+	}
+	q.cancel = cancel
+	return q, nil
+}
+
+func newGQueue(ctx context.Context, config GQueueConfig) (*GQueue, error) {
+	var opts []option.ClientOption
+	if config.EmulatorHost != "" {
+		opts = append(opts,
+			option.WithEndpoint(config.EmulatorHost),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		)
+	} else {
+		credentials, err := google.CredentialsFromJSON(ctx, []byte(config.Credential), pubsub.ScopePubSub)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, option.WithCredentials(credentials))
+	}
+
+	client, err := pubsub.NewClient(ctx, config.ProjectID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	topic, err := ensureTopic(ctx, client, config)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	subscription, err := ensureSubscription(ctx, client, topic, config)
+	if err != nil {
+		topic.Stop()
+		client.Close()
+		return nil, err
 	}
-	// ensure topic exists
-	topic := client.Topic(topicID)
+
+	return &GQueue{
+		client:       client,
+		ctx:          ctx,
+		topic:        topic,
+		subscription: subscription,
+		orderingKey:  config.OrderingKey,
+		ch:           make(chan interface{}, 10),
+	}, nil
+}
+
+func ensureTopic(ctx context.Context, client *pubsub.Client, config GQueueConfig) (*pubsub.Topic, error) {
+	topic := client.Topic(config.TopicID)
 	exists, err := topic.Exists(ctx)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	if !exists {
-		topic, err = client.CreateTopic(ctx, topicID)
+		if !config.AutoCreate {
+			return nil, fmt.Errorf("topic:'%s' does not exist", config.TopicID)
+		}
+		topic, err = client.CreateTopic(ctx, config.TopicID)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 	}
+	topic.EnableMessageOrdering = config.OrderingKey != nil
+	return topic, nil
+}
 
-	// ensure subscription
-	subscription := client.Subscription(subscriptionID)
-	exists, err = subscription.Exists(ctx)
-	config := pubsub.SubscriptionConfig{
-		Topic: topic,
-	}
+func ensureSubscription(ctx context.Context, client *pubsub.Client, topic *pubsub.Topic, config GQueueConfig) (*pubsub.Subscription, error) {
+	subscription := client.Subscription(config.SubscriptionID)
+	exists, err := subscription.Exists(ctx)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	if !exists {
-		subscription, err = client.CreateSubscription(ctx, subscriptionID, config)
+		if !config.AutoCreate {
+			return nil, fmt.Errorf("subscription:'%s' does not exist", config.SubscriptionID)
+		}
+		subscription, err = client.CreateSubscription(ctx, config.SubscriptionID, pubsub.SubscriptionConfig{
+			Topic:                 topic,
+			AckDeadline:           config.AckDeadline,
+			EnableMessageOrdering: config.OrderingKey != nil,
+		})
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 	} else {
 		con, err := subscription.Config(ctx)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		boundTopicID := con.Topic.ID()
-		if boundTopicID != config.Topic.ID() {
-			panic(fmt.Errorf("failed to bind subscription:'%s' to topic:'%s', already bound to topic:'%s'", subscription.ID(), config.Topic.ID(), boundTopicID))
+		if boundTopicID != topic.ID() {
+			return nil, fmt.Errorf("failed to bind subscription:'%s' to topic:'%s', already bound to topic:'%s'", subscription.ID(), topic.ID(), boundTopicID)
+		}
+		if ordering := config.OrderingKey != nil; con.EnableMessageOrdering != ordering {
+			return nil, fmt.Errorf("subscription:'%s' message ordering is %v, expected %v", subscription.ID(), con.EnableMessageOrdering, ordering)
+		}
+		if config.AckDeadline != 0 && con.AckDeadline != config.AckDeadline {
+			log.Printf("managed: subscription:'%s' ack deadline is %v, configured %v is ignored", subscription.ID(), con.AckDeadline, config.AckDeadline)
 		}
 	}
-
-	return &GQueue{
-		client:       client,
-		ctx:          ctx,
-		cancel:       cancel,
-		topic:        topic,
-		subscription: subscription,
-		ch:           make(chan interface{}, 10),
-		once:         sync.Once{},
+	if config.MaxOutstandingMessages != 0 {
+		subscription.ReceiveSettings.MaxOutstandingMessages = config.MaxOutstandingMessages
 	}
+	return subscription, nil
 }
 
 // GQueue implementations of Stream using google pub/sub
@@ -85,8 +177,11 @@ type GQueue struct {
 	ctx          context.Context
 	cancel       context.CancelFunc
 	client       *pubsub.Client
+	orderingKey  func(data interface{}) string
 	ch           chan interface{}
-	once         sync.Once
+	receiving    bool
+	receiveErr   error
+	mu           sync.Mutex
 }
 
 // Push pushes data to stream
@@ -98,26 +193,96 @@ func (s *GQueue) Push(data interface{}) error {
 	if err != nil {
 		return err
 	}
-	result := s.topic.Publish(ctx, &pubsub.Message{
+	msg := &pubsub.Message{
 		Data: bs,
-	})
+	}
+	if s.orderingKey != nil {
+		msg.OrderingKey = s.orderingKey(data)
+	}
+	result := s.topic.Publish(ctx, msg)
 	_, err = result.Get(ctx)
+	if err != nil {
+		s.resume(msg.OrderingKey)
+	}
+	return err
+}
+
+// resume resumes publishing of ordering key paused by failed publish so later data can be pushed
+func (s *GQueue) resume(orderingKey string) {
+	if orderingKey != "" {
+		s.topic.ResumePublish(orderingKey)
+	}
+}
+
+// PushBatch publishes all data then waits for all publish results
+func (s *GQueue) PushBatch(data []interface{}) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	results := make([]*pubsub.PublishResult, 0, len(data))
+	keys := make([]string, 0, len(data))
+	for _, d := range data {
+		bs, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		msg := &pubsub.Message{
+			Data: bs,
+		}
+		if s.orderingKey != nil {
+			msg.OrderingKey = s.orderingKey(d)
+		}
+		results = append(results, s.topic.Publish(ctx, msg))
+		keys = append(keys, msg.OrderingKey)
+	}
+	var err error
+	for i, result := range results {
+		if _, e := result.Get(ctx); e != nil {
+			s.resume(keys[i])
+			if err == nil {
+				err = e
+			}
+		}
+	}
 	return err
 }
 
-func (s *GQueue) receive() error {
-	return s.subscription.Receive(s.ctx, func(ctx context.Context, msg *pubsub.Message) {
+func (s *GQueue) receive() {
+	err := s.subscription.Receive(s.ctx, func(ctx context.Context, msg *pubsub.Message) {
 		s.ch <- msg.Data
 		msg.Ack()
 		log.Println("pull")
 	})
+	s.mu.Lock()
+	s.receiving = false
+	s.receiveErr = err
+	s.mu.Unlock()
+}
+
+// startReceive starts receiving messages when they are not being received,
+// it returns error which stopped previous receive so a broken subscription is not taken as empty
+func (s *GQueue) startReceive() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.receiveErr
+	s.receiveErr = nil
+	if !s.receiving {
+		s.receiving = true
+		go s.receive()
+	}
+	return err
 }
 
 // Pull pulls data from stream, returns []byte,error
 func (s *GQueue) Pull() (interface{}, error) {
-	s.once.Do(func() {
-		go s.receive()
-	})
+	select {
+	case data := <-s.ch:
+		return data, nil
+	default:
+	}
+	if err := s.startReceive(); err != nil {
+		return nil, err
+	}
 
 	select {
 	case data := <-s.ch:
@@ -144,8 +309,34 @@ func (s *GQueue) Pull() (interface{}, error) {
 	// return bs, err
 }
 
+// PullBatch pulls at most n received data, returns [][]byte,error
+func (s *GQueue) PullBatch(n int) ([]interface{}, error) {
+	result := s.pullReceived(make([]interface{}, 0, n), n)
+	if len(result) > 0 {
+		return result, nil
+	}
+	if err := s.startReceive(); err != nil {
+		return result, err
+	}
+	return s.pullReceived(result, n), nil
+}
+
+// pullReceived appends received data to result until it has n data or no more data is received
+func (s *GQueue) pullReceived(result []interface{}, n int) []interface{} {
+	for len(result) < n {
+		select {
+		case data := <-s.ch:
+			result = append(result, data)
+		default:
+			return result
+		}
+	}
+	return result
+}
+
 // Dispose disposes instance
 func (s *GQueue) Dispose() {
 	s.topic.Stop()
 	s.cancel()
+	s.client.Close()
 }
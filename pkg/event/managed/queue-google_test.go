@@ -0,0 +1,213 @@
+package managed_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/pinkgorilla/go-sample/pkg/event/managed"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func Test_GQueue_WithoutAutoCreate_ShouldError(t *testing.T) {
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	config := managed.GQueueConfig{
+		TopicID:        "topic",
+		SubscriptionID: "subscription",
+		ProjectID:      "project",
+		EmulatorHost:   srv.Addr,
+		AutoCreate:     false,
+	}
+	_, err := managed.NewGQueueWithConfig(config)
+	if err == nil {
+		t.Fatal("expected error when topic does not exist")
+	}
+}
+
+func Test_GQueue_SubscriptionBoundToOtherTopic_ShouldError(t *testing.T) {
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	config := managed.GQueueConfig{
+		TopicID:        "topic-a",
+		SubscriptionID: "subscription",
+		ProjectID:      "project",
+		EmulatorHost:   srv.Addr,
+		AutoCreate:     true,
+	}
+	q, err := managed.NewGQueueWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Dispose()
+
+	config.TopicID = "topic-b"
+	_, err = managed.NewGQueueWithConfig(config)
+	if err == nil {
+		t.Fatal("expected error when subscription is bound to other topic")
+	}
+}
+
+func Test_GQueue_SubscriptionOrderingMismatch_ShouldError(t *testing.T) {
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	config := managed.GQueueConfig{
+		TopicID:        "topic",
+		SubscriptionID: "subscription",
+		ProjectID:      "project",
+		EmulatorHost:   srv.Addr,
+		AutoCreate:     true,
+	}
+	q, err := managed.NewGQueueWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Dispose()
+
+	config.OrderingKey = func(data interface{}) string { return "key" }
+	_, err = managed.NewGQueueWithConfig(config)
+	if err == nil {
+		t.Fatal("expected error when subscription does not have message ordering enabled")
+	}
+}
+
+func Test_GQueue_Push_ResumesAfterFailure(t *testing.T) {
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	q, err := managed.NewGQueueWithConfig(managed.GQueueConfig{
+		TopicID:        "topic",
+		SubscriptionID: "subscription",
+		ProjectID:      "project",
+		EmulatorHost:   srv.Addr,
+		AutoCreate:     true,
+		OrderingKey: func(data interface{}) string {
+			return "key"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Dispose()
+
+	srv.SetAutoPublishResponse(false)
+	srv.AddPublishResponse(nil, status.Error(codes.InvalidArgument, "rejected"))
+	if err := q.Push(1); err == nil {
+		t.Fatal("expected publish error")
+	}
+	srv.SetAutoPublishResponse(true)
+	if err := q.Push(2); err != nil {
+		t.Fatalf("expected ordering key resumed after failed publish, got %v", err)
+	}
+	if err := q.PushBatch([]interface{}{3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.Messages()) != 3 {
+		t.Fatalf("expected 3 published messages, got %v", len(srv.Messages()))
+	}
+}
+
+func Test_GQueue_Push_Pull(t *testing.T) {
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	q, err := managed.NewGQueueWithConfig(managed.GQueueConfig{
+		TopicID:                "topic",
+		SubscriptionID:         "subscription",
+		ProjectID:              "project",
+		EmulatorHost:           srv.Addr,
+		AutoCreate:             true,
+		MaxOutstandingMessages: 10,
+		OrderingKey: func(data interface{}) string {
+			return "key"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Dispose()
+
+	err = q.Push(map[string]interface{}{"name": "john"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 published message, got %v", len(msgs))
+	}
+	if msgs[0].OrderingKey != "key" {
+		t.Fatalf("expected ordering key 'key', got '%s'", msgs[0].OrderingKey)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var data interface{}
+	for data == nil {
+		select {
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for message")
+		case <-time.After(10 * time.Millisecond):
+		}
+		data, err = q.Pull()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if string(data.([]byte)) != `{"name":"john"}` {
+		t.Fatalf("unexpected data %s", data)
+	}
+}
+
+func Test_GQueue_Pull_SubscriptionDeleted_ShouldError(t *testing.T) {
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	q, err := managed.NewGQueueWithConfig(managed.GQueueConfig{
+		TopicID:        "topic",
+		SubscriptionID: "subscription",
+		ProjectID:      "project",
+		EmulatorHost:   srv.Addr,
+		AutoCreate:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Dispose()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := pubsub.NewClient(ctx, "project",
+		option.WithEndpoint(srv.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Subscription("subscription").Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	waitError := func(pull func() error) {
+		for pull() == nil {
+			select {
+			case <-ctx.Done():
+				t.Fatal("expected error pulling from deleted subscription")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	waitError(func() error { _, err := q.PullBatch(10); return err })
+	// receive is started again and fails again
+	waitError(func() error { _, err := q.Pull(); return err })
+}
@@ -0,0 +1,285 @@
+package managed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaQueueConfig is configuration of KafkaQueue
+type KafkaQueueConfig struct {
+	Brokers []string `yaml:"brokers" json:"brokers"`
+	Topic   string   `yaml:"topic" json:"topic"`
+	// Partition is the partition written to and read from when GroupID is empty,
+	// consumer group members get their partitions assigned by the broker
+	Partition int `yaml:"partition" json:"partition"`
+	// GroupID is consumer group id, offsets of pulled messages are committed to the group
+	GroupID string `yaml:"groupId" json:"groupId"`
+	// StartOffset is the offset used when there is no committed offset, kafka.FirstOffset or kafka.LastOffset
+	StartOffset int64 `yaml:"startOffset" json:"startOffset"`
+	// PullTimeout is max duration Pull waits for a message before returning nil
+	PullTimeout time.Duration `yaml:"pullTimeout" json:"pullTimeout"`
+	// DeadLetterTopic receives raw messages failing to decode. When empty, reader without GroupID
+	// seeks back to the failing message, consumer group reader can not seek so the message is logged and skipped
+	DeadLetterTopic string `yaml:"deadLetterTopic" json:"deadLetterTopic"`
+}
+
+// KafkaQueue is queue implementation on kafka protocol
+type KafkaQueue struct {
+	config     KafkaQueueConfig
+	writer     *kafka.Writer
+	deadLetter *kafka.Writer
+	reader     *kafka.Reader
+	client     *kafka.Client
+	push       func(interface{}) (interface{}, error)
+	pop        func(interface{}) (interface{}, error)
+}
+
+// NewKafkaQueue returns new KafkaQueue instance
+func NewKafkaQueue(config KafkaQueueConfig) (*KafkaQueue, error) {
+	return NewKafkaQueueWithFunc(config, DataToJSON, JSONToData)
+}
+
+// NewKafkaQueueWithFunc returns new KafkaQueue with specified push and pop func
+func NewKafkaQueueWithFunc(
+	config KafkaQueueConfig,
+	push func(interface{}) (interface{}, error),
+	pop func(interface{}) (interface{}, error)) (*KafkaQueue, error) {
+	if len(config.Brokers) == 0 {
+		return nil, errors.New("kafka queue: at least one broker is required")
+	}
+	if config.Topic == "" {
+		return nil, errors.New("kafka queue: topic is required")
+	}
+	if config.StartOffset == 0 {
+		config.StartOffset = kafka.FirstOffset
+	}
+	if config.PullTimeout <= 0 {
+		config.PullTimeout = 100 * time.Millisecond
+	}
+
+	var balancer kafka.Balancer = &kafka.LeastBytes{}
+	readerConfig := kafka.ReaderConfig{
+		Brokers:     config.Brokers,
+		Topic:       config.Topic,
+		GroupID:     config.GroupID,
+		StartOffset: config.StartOffset,
+		MaxWait:     config.PullTimeout,
+	}
+	if config.GroupID == "" {
+		balancer = partitionBalancer(config.Partition)
+		readerConfig.Partition = config.Partition
+	}
+
+	var deadLetter *kafka.Writer
+	if config.DeadLetterTopic != "" {
+		deadLetter = &kafka.Writer{
+			Addr:                   kafka.TCP(config.Brokers...),
+			Topic:                  config.DeadLetterTopic,
+			AllowAutoTopicCreation: true,
+		}
+	}
+
+	return &KafkaQueue{
+		config:     config,
+		deadLetter: deadLetter,
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(config.Brokers...),
+			Topic:                  config.Topic,
+			Balancer:               balancer,
+			AllowAutoTopicCreation: true,
+		},
+		reader: kafka.NewReader(readerConfig),
+		client: &kafka.Client{Addr: kafka.TCP(config.Brokers...)},
+		push:   push,
+		pop:    pop,
+	}, nil
+}
+
+// Push pushes data to kafka topic
+func (s *KafkaQueue) Push(data interface{}) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	v, err := s.push(data)
+	if err != nil {
+		return err
+	}
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Value: bytesOf(v),
+	})
+}
+
+// Pull pulls data from kafka topic, returns nil when no message arrives within PullTimeout.
+// When GroupID is set, offset of pulled message is committed to the consumer group
+func (s *KafkaQueue) Pull() (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), s.config.PullTimeout)
+	defer cancel()
+
+	msg, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// reader has already moved past msg, message failing to decode is rejected
+	// so it is not skipped silently by the next commit
+	data, err := s.pop(string(msg.Value))
+	if err != nil {
+		return nil, s.reject(msg, err)
+	}
+	if err := s.commit(msg); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// reject writes msg failing to decode to DeadLetterTopic and commits its offset,
+// without dead letter topic reader seeks back to msg so it is pulled again
+func (s *KafkaQueue) reject(msg kafka.Message, decodeErr error) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	if s.deadLetter != nil {
+		err := s.deadLetter.WriteMessages(ctx, kafka.Message{
+			Key:   msg.Key,
+			Value: msg.Value,
+			Headers: append(msg.Headers,
+				kafka.Header{Key: "topic", Value: []byte(msg.Topic)},
+				kafka.Header{Key: "partition", Value: []byte(strconv.Itoa(msg.Partition))},
+				kafka.Header{Key: "offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+				kafka.Header{Key: "error", Value: []byte(decodeErr.Error())},
+			),
+		})
+		if err == nil {
+			if err := s.commit(msg); err != nil {
+				return err
+			}
+			return fmt.Errorf("kafka queue: message at offset %d moved to %s: %w", msg.Offset, s.config.DeadLetterTopic, decodeErr)
+		}
+		log.Println("kafka queue: failed to write dead letter", err)
+	}
+	if s.config.GroupID == "" {
+		if err := s.reader.SetOffset(msg.Offset); err != nil {
+			return fmt.Errorf("kafka queue: failed to seek back to offset %d: %v: %w", msg.Offset, err, decodeErr)
+		}
+		return decodeErr
+	}
+	log.Printf("kafka queue: skipping message at partition %d offset %d failing to decode: %q\n", msg.Partition, msg.Offset, msg.Value)
+	return decodeErr
+}
+
+// commit commits offset of msg when GroupID is set
+func (s *KafkaQueue) commit(msg kafka.Message) error {
+	if s.config.GroupID == "" {
+		return nil
+	}
+	if err := s.reader.CommitMessages(context.TODO(), msg); err != nil {
+		return fmt.Errorf("kafka queue: failed to commit offset %d: %v", msg.Offset, err)
+	}
+	return nil
+}
+
+// Size returns number of messages not yet pulled by reader.
+// When GroupID is set, it is sum of lag between committed offsets of the group and last offsets of topic partitions
+func (s *KafkaQueue) Size() (int, error) {
+	if s.config.GroupID == "" {
+		return int(s.reader.Stats().Lag), nil
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	return s.groupLag(ctx)
+}
+
+func (s *KafkaQueue) groupLag(ctx context.Context) (int, error) {
+	metadata, err := s.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{s.config.Topic}})
+	if err != nil {
+		return 0, err
+	}
+	partitions := []int{}
+	for _, topic := range metadata.Topics {
+		if topic.Name != s.config.Topic {
+			continue
+		}
+		if topic.Error != nil {
+			return 0, topic.Error
+		}
+		for _, p := range topic.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+	}
+
+	requests := []kafka.OffsetRequest{}
+	for _, p := range partitions {
+		requests = append(requests, kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+	}
+	offsets, err := s.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{s.config.Topic: requests},
+	})
+	if err != nil {
+		return 0, err
+	}
+	committed, err := s.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: s.config.GroupID,
+		Topics:  map[string][]int{s.config.Topic: partitions},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if committed.Error != nil {
+		return 0, committed.Error
+	}
+	committedOffsets := map[int]int64{}
+	for _, p := range committed.Topics[s.config.Topic] {
+		if p.Error != nil {
+			return 0, p.Error
+		}
+		committedOffsets[p.Partition] = p.CommittedOffset
+	}
+
+	lag := int64(0)
+	for _, p := range offsets.Topics[s.config.Topic] {
+		if p.Error != nil {
+			return 0, p.Error
+		}
+		from, ok := committedOffsets[p.Partition]
+		if !ok || from < 0 {
+			// group has no committed offset, reading starts at StartOffset
+			from = p.FirstOffset
+			if s.config.StartOffset == kafka.LastOffset {
+				from = p.LastOffset
+			}
+		}
+		if p.LastOffset > from {
+			lag += p.LastOffset - from
+		}
+	}
+	return int(lag), nil
+}
+
+// Dispose closes kafka reader and writers
+func (s *KafkaQueue) Dispose() {
+	s.writer.Close()
+	if s.deadLetter != nil {
+		s.deadLetter.Close()
+	}
+	s.reader.Close()
+}
+
+// partitionBalancer is kafka.Balancer which always picks the same partition
+type partitionBalancer int
+
+func (p partitionBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	for _, partition := range partitions {
+		if partition == int(p) {
+			return partition
+		}
+	}
+	return partitions[0]
+}
@@ -0,0 +1,218 @@
+package managed_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event/managed"
+)
+
+const kafkaBroker = "localhost:9092"
+
+func kafkaQueueConfig(t *testing.T) managed.KafkaQueueConfig {
+	conn, err := net.DialTimeout("tcp", kafkaBroker, time.Second)
+	if err != nil {
+		t.Skipf("kafka broker is not available at %s: %v", kafkaBroker, err)
+	}
+	conn.Close()
+	return managed.KafkaQueueConfig{
+		Brokers:     []string{kafkaBroker},
+		Topic:       fmt.Sprintf("test-%v", time.Now().UnixNano()),
+		GroupID:     "test",
+		PullTimeout: time.Second,
+	}
+}
+
+func Test_KafkaQueue_Push_Pull(t *testing.T) {
+	config := kafkaQueueConfig(t)
+	q, err := managed.NewKafkaQueue(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Dispose()
+
+	n := 3
+	for i := 0; i < n; i++ {
+		err := q.Push(map[string]interface{}{"i": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		var data interface{}
+		for data == nil {
+			data, err = q.Pull()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if fmt.Sprint(data.(map[string]interface{})["i"]) != fmt.Sprint(i) {
+			t.Fatalf("expected i:%v, got %v", i, data)
+		}
+	}
+}
+
+func Test_KafkaQueue_Size_ConsumerGroup(t *testing.T) {
+	config := kafkaQueueConfig(t)
+	q, err := managed.NewKafkaQueue(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Dispose()
+
+	for i := 0; i < 3; i++ {
+		if err := q.Push(map[string]interface{}{"i": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if size, err := q.Size(); err != nil || size != 3 {
+		t.Fatalf("expected 3 messages before pull, got %v %v", size, err)
+	}
+	var data interface{}
+	for data == nil {
+		if data, err = q.Pull(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if size, err := q.Size(); err != nil || size != 2 {
+		t.Fatalf("expected 2 messages after pull, got %v %v", size, err)
+	}
+}
+
+func Test_KafkaQueue_DecodeError_SeeksBack(t *testing.T) {
+	config := kafkaQueueConfig(t)
+	config.GroupID = ""
+	failed := false
+	q, err := managed.NewKafkaQueueWithFunc(config, managed.DataToJSON, func(v interface{}) (interface{}, error) {
+		if !failed {
+			failed = true
+			return nil, errors.New("decode failed")
+		}
+		return managed.JSONToData(v)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Dispose()
+
+	if err := q.Push(map[string]interface{}{"i": 1}); err != nil {
+		t.Fatal(err)
+	}
+	for !failed {
+		if _, err := q.Pull(); err == nil && failed {
+			t.Fatal("expected decode error")
+		}
+	}
+	var data interface{}
+	for data == nil {
+		if data, err = q.Pull(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fmt.Sprint(data.(map[string]interface{})["i"]) != "1" {
+		t.Fatalf("expected message failing to decode to be pulled again, got %v", data)
+	}
+}
+
+func Test_KafkaQueue_DecodeError_DeadLetter(t *testing.T) {
+	config := kafkaQueueConfig(t)
+	config.DeadLetterTopic = config.Topic + "-dead"
+	q, err := managed.NewKafkaQueueWithFunc(config, managed.DataToJSON, func(v interface{}) (interface{}, error) {
+		return nil, errors.New("decode failed")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Dispose()
+
+	if err := q.Push(map[string]interface{}{"i": 1}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, err := q.Pull()
+		if err != nil {
+			break
+		}
+	}
+
+	deadConfig := config
+	deadConfig.Topic = config.DeadLetterTopic
+	deadConfig.DeadLetterTopic = ""
+	dead, err := managed.NewKafkaQueue(deadConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dead.Dispose()
+	var data interface{}
+	for data == nil {
+		if data, err = dead.Pull(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fmt.Sprint(data.(map[string]interface{})["i"]) != "1" {
+		t.Fatalf("expected raw message in dead letter topic, got %v", data)
+	}
+}
+
+func Test_KafkaQueue_Listener(t *testing.T) {
+	config := kafkaQueueConfig(t)
+	q, err := managed.NewQueueFromConfig(managed.QueueConfig{
+		Driver: managed.DriverKafka,
+		Kafka:  config,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testQueueWithEmitterAndListener(t, q)
+}
+
+func Test_NewQueueFromConfig_UnknownDriver_ShouldError(t *testing.T) {
+	_, err := managed.NewQueueFromConfig(managed.QueueConfig{Driver: "unknown"})
+	if err == nil {
+		t.Fatal("expected error for unknown driver")
+	}
+}
+
+// testQueueWithEmitterAndListener emits events through q and expects listener to handle all of them
+func testQueueWithEmitterAndListener(t *testing.T, q managed.Queue) {
+	n := 10
+	emitter := managed.NewEmitter(q, managed.NewChannelQueue())
+	listener := managed.NewListener(q, managed.NewChannelQueue())
+	defer emitter.Dispose()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for i := 0; i < n; i++ {
+		err := emitter.Emit(map[string]interface{}{"i": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handled := sync.Map{}
+	counter := make(chan struct{}, n)
+	go listener.Listen(ctx, func(ctx context.Context, data interface{}) error {
+		m := data.(map[string]interface{})
+		handled.Store(fmt.Sprint(m["i"]), true)
+		counter <- struct{}{}
+		return nil
+	})
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected %v events handled, got %v", n, i)
+		case <-counter:
+		}
+	}
+	for i := 0; i < n; i++ {
+		if _, ok := handled.Load(fmt.Sprint(i)); !ok {
+			t.Fatalf("event %v was not handled", i)
+		}
+	}
+}
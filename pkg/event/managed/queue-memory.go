@@ -2,9 +2,11 @@ package managed
 
 import (
 	"bytes"
+	"container/heap"
 	"encoding/gob"
 	"io"
 	"sync"
+	"time"
 )
 
 // InMemoryQueue in memory implementation of store
@@ -13,10 +15,11 @@ type InMemoryQueue struct {
 	// Counter *sync.Map
 	sync sync.Mutex
 
-	off   int // offset for reading bytes
-	push  func(interface{}) (interface{}, error)
-	pull  func(interface{}) (interface{}, error)
-	keyFn func(interface{}) interface{}
+	off     int // offset for reading bytes
+	delayed delayedHeap
+	push    func(interface{}) (interface{}, error)
+	pull    func(interface{}) (interface{}, error)
+	keyFn   func(interface{}) interface{}
 }
 
 // NewInMemoryQueue returns new InMemoryQueue instance
@@ -67,10 +70,28 @@ func (s *InMemoryQueue) Push(data interface{}) error {
 	return nil
 }
 
+// PushAt holds data in timer heap, data is moved to store on pull once t is reached
+func (s *InMemoryQueue) PushAt(data interface{}, t time.Time) error {
+	s.sync.Lock()
+	defer s.sync.Unlock()
+	heap.Push(&s.delayed, delayedData{data: data, at: t})
+	return nil
+}
+
+// promote moves due data from timer heap to store, caller must hold the lock
+func (s *InMemoryQueue) promote() {
+	now := time.Now()
+	for len(s.delayed) > 0 && !s.delayed[0].at.After(now) {
+		d := heap.Pop(&s.delayed).(delayedData)
+		s.m.Store(s.keyFn(d.data), d.data)
+	}
+}
+
 // Pull pulls data from store
 func (s *InMemoryQueue) Pull() (interface{}, error) {
 	s.sync.Lock()
 	defer s.sync.Unlock()
+	s.promote()
 	var key, val interface{}
 	s.m.Range(func(k, v interface{}) bool {
 		key = k
@@ -83,8 +104,39 @@ func (s *InMemoryQueue) Pull() (interface{}, error) {
 	return val, nil
 }
 
-// Size return storage size
+// PushBatch pushes all data to store
+func (s *InMemoryQueue) PushBatch(data []interface{}) error {
+	s.sync.Lock()
+	defer s.sync.Unlock()
+	for _, d := range data {
+		s.m.Store(s.keyFn(d), d)
+	}
+	return nil
+}
+
+// PullBatch pulls at most n data from store
+func (s *InMemoryQueue) PullBatch(n int) ([]interface{}, error) {
+	s.sync.Lock()
+	defer s.sync.Unlock()
+	s.promote()
+	keys := make([]interface{}, 0, n)
+	result := make([]interface{}, 0, n)
+	s.m.Range(func(k, v interface{}) bool {
+		keys = append(keys, k)
+		result = append(result, v)
+		return len(keys) < n
+	})
+	for _, k := range keys {
+		s.m.Delete(k)
+	}
+	return result, nil
+}
+
+// Size return storage size, data held in timer heap is not counted until it is due
 func (s *InMemoryQueue) Size() (int, error) {
+	s.sync.Lock()
+	s.promote()
+	s.sync.Unlock()
 	counter := 0
 	f := func(k, v interface{}) bool {
 		counter++
@@ -132,3 +184,24 @@ func (s *InMemoryQueue) IsEmpty() bool {
 	}
 	return size < 1
 }
+
+// delayedData is data held until its delivery time
+type delayedData struct {
+	data interface{}
+	at   time.Time
+}
+
+// delayedHeap is min heap of delayedData ordered by delivery time
+type delayedHeap []delayedData
+
+func (h delayedHeap) Len() int            { return len(h) }
+func (h delayedHeap) Less(i, j int) bool  { return h[i].at.Before(h[j].at) }
+func (h delayedHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *delayedHeap) Push(x interface{}) { *h = append(*h, x.(delayedData)) }
+func (h *delayedHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
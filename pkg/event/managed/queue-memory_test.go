@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/pinkgorilla/go-sample/pkg/event/managed"
 )
@@ -73,3 +74,60 @@ func Test_InMemoryQueue(t *testing.T) {
 		t.Fatal("size")
 	}
 }
+
+func Test_InMemoryQueue_Batch(t *testing.T) {
+	q := managed.NewInMemoryQueue()
+	err := q.PushBatch([]interface{}{1, 2, 3, 4, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, _ := q.Size(); size != 5 {
+		t.Fatalf("expected size 5, got %v", size)
+	}
+	data, err := q.PullBatch(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 3 {
+		t.Fatalf("expected 3 data, got %v", len(data))
+	}
+	data, err = q.PullBatch(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 {
+		t.Fatalf("expected 2 data, got %v", len(data))
+	}
+	if !q.IsEmpty() {
+		t.Fatal("isEmpty")
+	}
+}
+
+func Test_InMemoryQueue_PushAt(t *testing.T) {
+	q := managed.NewInMemoryQueue()
+	now := time.Now()
+	q.PushAt(2, now.Add(200*time.Millisecond))
+	q.PushAt(1, now.Add(100*time.Millisecond))
+
+	v, err := q.Pull()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		t.Fatalf("expected nil before delivery time, got %v", v)
+	}
+	<-time.After(100 * time.Millisecond)
+	v, _ = q.Pull()
+	if v != 1 {
+		t.Fatalf("expected 1, got %v", v)
+	}
+	v, _ = q.Pull()
+	if v != nil {
+		t.Fatalf("expected nil before delivery time, got %v", v)
+	}
+	<-time.After(100 * time.Millisecond)
+	v, _ = q.Pull()
+	if v != 2 {
+		t.Fatalf("expected 2, got %v", v)
+	}
+}
@@ -0,0 +1,132 @@
+package managed
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSQueueConfig is configuration of NATSQueue
+type NATSQueueConfig struct {
+	URL string `yaml:"url" json:"url"`
+	// Stream is jetstream stream name, created bound to Subject when it does not exist
+	Stream  string `yaml:"stream" json:"stream"`
+	Subject string `yaml:"subject" json:"subject"`
+	// Durable is durable consumer name, jetstream keeps track of acknowledged messages for it
+	Durable string `yaml:"durable" json:"durable"`
+	// PullTimeout is max duration Pull waits for a message before returning nil
+	PullTimeout time.Duration `yaml:"pullTimeout" json:"pullTimeout"`
+}
+
+// NATSQueue is queue implementation on NATS jetstream
+type NATSQueue struct {
+	config       NATSQueueConfig
+	conn         *nats.Conn
+	js           nats.JetStreamContext
+	subscription *nats.Subscription
+	push         func(interface{}) (interface{}, error)
+	pop          func(interface{}) (interface{}, error)
+}
+
+// NewNATSQueue returns new NATSQueue instance
+func NewNATSQueue(config NATSQueueConfig) (*NATSQueue, error) {
+	return NewNATSQueueWithFunc(config, DataToJSON, JSONToData)
+}
+
+// NewNATSQueueWithFunc returns new NATSQueue with specified push and pop func
+func NewNATSQueueWithFunc(
+	config NATSQueueConfig,
+	push func(interface{}) (interface{}, error),
+	pop func(interface{}) (interface{}, error)) (*NATSQueue, error) {
+	if config.Stream == "" || config.Subject == "" || config.Durable == "" {
+		return nil, errors.New("nats queue: stream, subject and durable are required")
+	}
+	if config.URL == "" {
+		config.URL = nats.DefaultURL
+	}
+	if config.PullTimeout <= 0 {
+		config.PullTimeout = 100 * time.Millisecond
+	}
+
+	conn, err := nats.Connect(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// ensure stream exists
+	_, err = js.StreamInfo(config.Stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:     config.Stream,
+			Subjects: []string{config.Subject},
+		})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	subscription, err := js.PullSubscribe(config.Subject, config.Durable, nats.BindStream(config.Stream))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &NATSQueue{
+		config:       config,
+		conn:         conn,
+		js:           js,
+		subscription: subscription,
+		push:         push,
+		pop:          pop,
+	}, nil
+}
+
+// Push publishes data to jetstream subject
+func (s *NATSQueue) Push(data interface{}) error {
+	v, err := s.push(data)
+	if err != nil {
+		return err
+	}
+	_, err = s.js.Publish(s.config.Subject, bytesOf(v))
+	return err
+}
+
+// Pull pulls data from durable consumer, returns nil when no message arrives within PullTimeout.
+// Pulled message is acknowledged so it will not be redelivered to the same durable consumer
+func (s *NATSQueue) Pull() (interface{}, error) {
+	msgs, err := s.subscription.Fetch(1, nats.MaxWait(s.config.PullTimeout))
+	if err != nil {
+		if errors.Is(err, nats.ErrTimeout) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	msg := msgs[0]
+	err = msg.Ack()
+	if err != nil {
+		return nil, err
+	}
+	return s.pop(string(msg.Data))
+}
+
+// Size returns number of messages pending for durable consumer
+func (s *NATSQueue) Size() (int, error) {
+	info, err := s.subscription.ConsumerInfo()
+	if err != nil {
+		return 0, err
+	}
+	return int(info.NumPending) + info.NumAckPending, nil
+}
+
+// Dispose closes connection, durable consumer is kept on the server
+func (s *NATSQueue) Dispose() {
+	s.conn.Close()
+}
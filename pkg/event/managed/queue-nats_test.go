@@ -0,0 +1,114 @@
+package managed_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/pinkgorilla/go-sample/pkg/event/managed"
+)
+
+func runJetStreamServer(t *testing.T) *server.Server {
+	dir, err := ioutil.TempDir("", "jetstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = dir
+	s := natstest.RunServer(&opts)
+	t.Cleanup(func() {
+		s.Shutdown()
+		os.RemoveAll(dir)
+	})
+	return s
+}
+
+func Test_NATSQueue_Push_Pull(t *testing.T) {
+	s := runJetStreamServer(t)
+	config := managed.NATSQueueConfig{
+		URL:     s.ClientURL(),
+		Stream:  "events",
+		Subject: "events.test",
+		Durable: "test",
+	}
+	q, err := managed.NewNATSQueue(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pulling empty queue should return nil without error
+	data, err := q.Pull()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != nil {
+		t.Fatalf("expected nil, got %v", data)
+	}
+
+	n := 3
+	for i := 0; i < n; i++ {
+		err := q.Push(map[string]interface{}{"i": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	size, err := q.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != n {
+		t.Fatalf("expected size %v, got %v", n, size)
+	}
+
+	// pull one message then reconnect, durable consumer should continue from its offset
+	data, err = q.Pull()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(data.(map[string]interface{})["i"]) != "0" {
+		t.Fatalf("expected i:0, got %v", data)
+	}
+	q.Dispose()
+
+	q, err = managed.NewNATSQueue(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Dispose()
+	for i := 1; i < n; i++ {
+		data, err := q.Pull()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data == nil {
+			t.Fatalf("expected i:%v, got nil", i)
+		}
+		if fmt.Sprint(data.(map[string]interface{})["i"]) != fmt.Sprint(i) {
+			t.Fatalf("expected i:%v, got %v", i, data)
+		}
+	}
+}
+
+func Test_NATSQueue_Listener(t *testing.T) {
+	s := runJetStreamServer(t)
+	q, err := managed.NewQueueFromConfig(managed.QueueConfig{
+		Driver: managed.DriverNATS,
+		NATS: managed.NATSQueueConfig{
+			URL:         s.ClientURL(),
+			Stream:      "orders",
+			Subject:     "orders.created",
+			Durable:     "listener",
+			PullTimeout: 50 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testQueueWithEmitterAndListener(t, q)
+}
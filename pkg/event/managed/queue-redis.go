@@ -7,8 +7,27 @@ import (
 	"time"
 
 	"github.com/go-redis/redis"
+	"github.com/pinkgorilla/go-sample/pkg/generator"
 )
 
+// redisPromoteScript moves due data from delayed sorted set to the list,
+// members of delayed set are prefixed with unique id to allow duplicate data
+const redisPromoteScript = `
+local items = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, item in ipairs(items) do
+	redis.call('ZREM', KEYS[2], item)
+	redis.call('LPUSH', KEYS[1], string.sub(item, string.find(item, ':', 1, true) + 1))
+end
+`
+
+var (
+	redisPromote     = redis.NewScript(redisPromoteScript + `return #items`)
+	redisPromotePull = redis.NewScript(redisPromoteScript + `return redis.call('RPOP', KEYS[1])`)
+)
+
+// redisPromoteLimit is max number of due data moved on each pull
+const redisPromoteLimit = 1000
+
 // DataToJSON Default push function, parse i to json string.
 var DataToJSON = func(i interface{}) (interface{}, error) {
 	bs, err := json.Marshal(i)
@@ -65,15 +84,80 @@ func (s *RedisQueue) Push(data interface{}) error {
 	return err
 }
 
+// PushAt pushes data to delayed sorted set, data is moved to redis stream on pull once t is reached
+func (s *RedisQueue) PushAt(data interface{}, t time.Time) error {
+	str, err := s.push(data)
+	if err != nil {
+		return err
+	}
+	return s.r.ZAdd(s.delayedKey(), redis.Z{
+		Score:  float64(redisScore(t)),
+		Member: fmt.Sprintf("%s:%v", generator.UUID(), str),
+	}).Err()
+}
+
 // Pull pulls data from redis stream
 func (s *RedisQueue) Pull() (interface{}, error) {
-	r, err := s.r.RPop(s.Key()).Result()
+	r, err := redisPromotePull.Run(s.r, s.keys(), redisScore(time.Now()), redisPromoteLimit).Result()
 	if err != nil {
 		return nil, err
 	}
 	return s.pop(r)
 }
 
+// PushBatch pushes all data to redis stream in a single command
+func (s *RedisQueue) PushBatch(data []interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	values := make([]interface{}, 0, len(data))
+	for _, d := range data {
+		str, err := s.push(d)
+		if err != nil {
+			return err
+		}
+		values = append(values, str)
+	}
+	return s.r.LPush(s.Key(), values...).Err()
+}
+
+// PullBatch pulls at most n data from redis stream using pipeline,
+// data failing to decode is dropped and reported by returned error
+func (s *RedisQueue) PullBatch(n int) ([]interface{}, error) {
+	pipe := s.r.TxPipeline()
+	redisPromote.Eval(pipe, s.keys(), redisScore(time.Now()), redisPromoteLimit)
+	cmds := make([]*redis.StringCmd, n)
+	for i := range cmds {
+		cmds[i] = pipe.RPop(s.Key())
+	}
+	_, err := pipe.Exec()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	// popped data is already removed from stream, remaining data is decoded
+	// after a failure so it is not lost, first error is returned along with decoded data
+	result := make([]interface{}, 0, n)
+	var first error
+	for _, cmd := range cmds {
+		r, err := cmd.Result()
+		if err == redis.Nil {
+			break
+		}
+		if err == nil {
+			var v interface{}
+			v, err = s.pop(r)
+			if err == nil {
+				result = append(result, v)
+				continue
+			}
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return result, first
+}
+
 // Dispose clean resources
 func (s *RedisQueue) Dispose() {
 	log.Println("dispose")
@@ -85,9 +169,27 @@ func (s *RedisQueue) Key() string {
 	return s.key
 }
 
+func (s *RedisQueue) delayedKey() string {
+	return s.key + ":delayed"
+}
+
+func (s *RedisQueue) keys() []string {
+	return []string{s.Key(), s.delayedKey()}
+}
+
+// isEmpty returns true when err is returned by Pull of empty RedisQueue
+func isEmpty(err error) bool {
+	return err == redis.Nil
+}
+
+// redisScore returns t as delayed sorted set score
+func redisScore(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
 // Clear clears the stream
 func (s *RedisQueue) Clear() error {
-	return s.r.Del(s.Key()).Err()
+	return s.r.Del(s.Key(), s.delayedKey()).Err()
 }
 
 //Size returns stream size
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/go-redis/redis"
 	"github.com/pinkgorilla/go-sample/pkg/event/managed"
@@ -205,3 +206,161 @@ func Test_RedisQueue_Int_Push_Pop(t *testing.T) {
 		t.Fatal("mismatch!")
 	}
 }
+
+func Test_RedisQueue_Batch(t *testing.T) {
+	r := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	s := managed.NewRedisQueue(r)
+	defer s.Dispose()
+
+	data := []interface{}{}
+	for i := 0; i < 5; i++ {
+		data = append(data, map[string]interface{}{"i": i})
+	}
+	err := s.PushBatch(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := s.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 5 {
+		t.Fatalf("expected size 5, got %v", size)
+	}
+
+	result, err := s.PullBatch(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 3 {
+		t.Fatalf("expected 3 data, got %v", len(result))
+	}
+	for i, v := range result {
+		if fmt.Sprint(v.(map[string]interface{})["i"]) != fmt.Sprint(i) {
+			t.Fatalf("expected i:%v, got %v", i, v)
+		}
+	}
+	result, err = s.PullBatch(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("expected 2 data, got %v", len(result))
+	}
+}
+
+func Test_RedisQueue_PullBatch_DecodeError(t *testing.T) {
+	r := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	s := managed.NewRedisQueueWithFunc(r,
+		func(i interface{}) (interface{}, error) {
+			return i, nil
+		},
+		func(i interface{}) (interface{}, error) {
+			if i == "bad" {
+				return nil, fmt.Errorf("cannot decode %v", i)
+			}
+			return i, nil
+		})
+	defer s.Dispose()
+	s.Clear()
+
+	err := s.PushBatch([]interface{}{"a", "bad", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.PullBatch(3)
+	if err == nil {
+		t.Fatal("expected decode error")
+	}
+	if fmt.Sprint(result) != "[a c]" {
+		t.Fatalf("expected data after decode error returned, got %v", result)
+	}
+}
+
+func Benchmark_RedisQueue_Push(b *testing.B) {
+	r := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	s := managed.NewRedisQueue(r)
+	defer s.Dispose()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.Push(i); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_RedisQueue_PushBatch(b *testing.B) {
+	r := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	s := managed.NewRedisQueue(r)
+	defer s.Dispose()
+
+	batchSize := 100
+	batch := make([]interface{}, 0, batchSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch = append(batch, i)
+		if len(batch) == batchSize || i == b.N-1 {
+			if err := s.PushBatch(batch); err != nil {
+				b.Fatal(err)
+			}
+			batch = batch[:0]
+		}
+	}
+}
+
+func Test_RedisQueue_PushAt(t *testing.T) {
+	r := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	s := managed.NewRedisQueue(r)
+	defer s.Dispose()
+
+	now := time.Now()
+	reminder := map[string]interface{}{"name": "reminder"}
+	// same data delayed twice should be delivered twice
+	for i := 0; i < 2; i++ {
+		err := s.PushAt(reminder, now.Add(200*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.Push(map[string]interface{}{"name": "now"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := s.Pull()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.(map[string]interface{})["name"] != "now" {
+		t.Fatalf("expected immediate data, got %v", v)
+	}
+	_, err = s.Pull()
+	if err != redis.Nil {
+		t.Fatalf("expected empty queue before delivery time, got %v", err)
+	}
+
+	<-time.After(200 * time.Millisecond)
+	result, err := s.PullBatch(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("expected 2 delayed data, got %v", len(result))
+	}
+	for _, v := range result {
+		if v.(map[string]interface{})["name"] != "reminder" {
+			t.Fatalf("expected delayed data, got %v", v)
+		}
+	}
+}
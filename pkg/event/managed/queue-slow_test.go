@@ -0,0 +1,76 @@
+package managed_test
+
+import (
+	"sync"
+	"time"
+)
+
+// NewSlowQueue creates new SlowQueue instance
+func NewSlowQueue(latency time.Duration) *SlowQueue {
+	return &SlowQueue{
+		latency:  latency,
+		sequence: []interface{}{},
+	}
+}
+
+// SlowQueue is a queue simulating network latency on every round trip
+type SlowQueue struct {
+	latency    time.Duration
+	sequence   []interface{}
+	roundTrips int
+	mu         sync.Mutex
+}
+
+func (s *SlowQueue) roundTrip() {
+	<-time.After(s.latency)
+	s.mu.Lock()
+	s.roundTrips++
+	s.mu.Unlock()
+}
+
+func (s *SlowQueue) Push(data interface{}) error {
+	return s.PushBatch([]interface{}{data})
+}
+
+func (s *SlowQueue) Pull() (interface{}, error) {
+	data, err := s.PullBatch(1)
+	if len(data) == 0 {
+		return nil, err
+	}
+	return data[0], err
+}
+
+func (s *SlowQueue) PushBatch(data []interface{}) error {
+	s.roundTrip()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sequence = append(s.sequence, data...)
+	return nil
+}
+
+func (s *SlowQueue) PullBatch(n int) ([]interface{}, error) {
+	s.roundTrip()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > len(s.sequence) {
+		n = len(s.sequence)
+	}
+	data := append([]interface{}{}, s.sequence[:n]...)
+	s.sequence = s.sequence[n:]
+	return data, nil
+}
+
+func (s *SlowQueue) Dispose() {}
+
+func (s *SlowQueue) Size() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sequence), nil
+}
+
+// RoundTrips returns number of round trips made to the queue
+func (s *SlowQueue) RoundTrips() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.roundTrips
+}
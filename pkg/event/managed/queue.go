@@ -1,5 +1,10 @@
 package managed
 
+import (
+	"fmt"
+	"time"
+)
+
 // Queue is an interface providing methods for pushing and pulling data
 type Queue interface {
 	Push(data interface{}) error
@@ -12,3 +17,64 @@ type Size interface {
 	// Size returns the size of implementing type
 	Size() (int, error)
 }
+
+// Delayed interface for queues able to hold data until its delivery time
+type Delayed interface {
+	// PushAt pushes data which is not pulled until t
+	PushAt(data interface{}, t time.Time) error
+}
+
+// bytesOf converts value produced by push func to bytes to be sent over the wire
+func bytesOf(i interface{}) []byte {
+	switch v := i.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// Batch interface for queues able to push and pull multiple data in a single round trip
+type Batch interface {
+	// PushBatch pushes all data
+	PushBatch(data []interface{}) error
+	// PullBatch pulls at most n data, returns empty slice when there is no data
+	PullBatch(n int) ([]interface{}, error)
+}
+
+// pushBatch pushes data using q.PushBatch when q implements Batch,
+// otherwise data is pushed one by one until a push fails.
+// It returns number of data pushed, none of data is taken as pushed when PushBatch fails
+func pushBatch(q Queue, data []interface{}) (int, error) {
+	if b, ok := q.(Batch); ok {
+		if err := b.PushBatch(data); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	for i, d := range data {
+		err := q.Push(d)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(data), nil
+}
+
+// pullBatch pulls at most n data using q.PullBatch when q implements Batch,
+// otherwise a single data is pulled, pulling empty queue returns no data and no error
+func pullBatch(q Queue, n int) ([]interface{}, error) {
+	if b, ok := q.(Batch); ok && n > 1 {
+		return b.PullBatch(n)
+	}
+	data, err := q.Pull()
+	if isEmpty(err) {
+		return []interface{}{}, nil
+	}
+	if data == nil {
+		return []interface{}{}, err
+	}
+	return []interface{}{data}, err
+}
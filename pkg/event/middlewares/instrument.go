@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce sync.Once
+
+	handledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "event_handler_total",
+			Help: "A counter for data handled by event handlers, partitioned by result.",
+		},
+		[]string{"handler", "result"},
+	)
+	handlerDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "event_handler_duration_seconds",
+			Help:    "A histogram of event handler latencies.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"handler"},
+	)
+)
+
+// Instrument is a middleware to instrument prometheus metrics of handler named name
+func Instrument(name string) event.Middleware {
+	metricsOnce.Do(func() {
+		prometheus.MustRegister(handledTotal, handlerDuration)
+	})
+	return func(next event.ListenerHandler) event.ListenerHandler {
+		return func(ctx context.Context, data interface{}) error {
+			start := time.Now()
+			err := next(ctx, data)
+			handlerDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+			result := "success"
+			if err != nil {
+				result = "error"
+			}
+			handledTotal.WithLabelValues(name, result).Inc()
+			return err
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+	"github.com/pinkgorilla/go-sample/pkg/logger"
+)
+
+// Logger is a middleware that logs every handled data along with handler name,
+// duration and error using l
+func Logger(l logger.Logger, name string) event.Middleware {
+	return func(next event.ListenerHandler) event.ListenerHandler {
+		return func(ctx context.Context, data interface{}) error {
+			start := time.Now()
+			err := next(ctx, data)
+			params := map[string]interface{}{
+				"handler":  name,
+				"data":     data,
+				"duration": time.Since(start).String(),
+			}
+			message := "event handled"
+			if err != nil {
+				message = "event handler failed"
+				params["error"] = err.Error()
+			}
+			l.Log(message, name, params, "")
+			return err
+		}
+	}
+}
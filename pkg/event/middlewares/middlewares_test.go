@@ -0,0 +1,193 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+	"github.com/pinkgorilla/go-sample/pkg/event/middlewares"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Test_Chain(t *testing.T) {
+	calls := []string{}
+	trace := func(name string) event.Middleware {
+		return func(next event.ListenerHandler) event.ListenerHandler {
+			return func(ctx context.Context, data interface{}) error {
+				calls = append(calls, name)
+				return next(ctx, data)
+			}
+		}
+	}
+	h := event.Chain(func(ctx context.Context, data interface{}) error {
+		calls = append(calls, "handler")
+		return nil
+	}, trace("first"), trace("second"))
+	h(context.TODO(), 1)
+	if strings.Join(calls, ",") != "first,second,handler" {
+		t.Fatalf("unexpected call order %v", calls)
+	}
+}
+
+func Test_Recoverer(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatal(r)
+		}
+	}()
+	h := middlewares.Recoverer(func(ctx context.Context, data interface{}) error {
+		panic("OMG!! i'm panicking!!")
+	})
+	err := h(context.TODO(), map[string]interface{}{"name": "john"})
+	var perr *middlewares.PanicError
+	if !errors.As(err, &perr) || perr.Value != "OMG!! i'm panicking!!" || perr.Stack == "" {
+		t.Fatalf("expected PanicError, got %v", err)
+	}
+}
+
+func Test_Timeout(t *testing.T) {
+	h := middlewares.Timeout(50 * time.Millisecond)(func(ctx context.Context, data interface{}) error {
+		<-time.After(time.Second)
+		return nil
+	})
+	start := time.Now()
+	err := h(context.TODO(), 1)
+	if err != context.DeadlineExceeded || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("expected deadline exceeded after timeout, got %v in %v", err, time.Since(start))
+	}
+
+	h = middlewares.Timeout(time.Second)(func(ctx context.Context, data interface{}) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("expected deadline on context")
+		}
+		return nil
+	})
+	if err := h(context.TODO(), 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Timeout_Panic(t *testing.T) {
+	h := middlewares.Recoverer(middlewares.Timeout(time.Second)(func(ctx context.Context, data interface{}) error {
+		panic("panicking in handler goroutine")
+	}))
+	err := h(context.TODO(), 1)
+	var perr *middlewares.PanicError
+	if !errors.As(err, &perr) || perr.Value != "panicking in handler goroutine" || !strings.Contains(perr.Stack, "Test_Timeout_Panic") {
+		t.Fatalf("expected PanicError with stack of handler, got %v", err)
+	}
+}
+
+func Test_Retry(t *testing.T) {
+	calls := 0
+	failure := errors.New("failed")
+	h := middlewares.Retry(3, 10*time.Millisecond, 15*time.Millisecond)(func(ctx context.Context, data interface{}) error {
+		calls++
+		return failure
+	})
+	start := time.Now()
+	if err := h(context.TODO(), 1); err != failure {
+		t.Fatalf("expected last error, got %v", err)
+	}
+	if calls != 3 || time.Since(start) < 25*time.Millisecond {
+		t.Fatalf("expected 3 calls with backoff, got %v calls in %v", calls, time.Since(start))
+	}
+
+	calls = 0
+	h = middlewares.Retry(5, time.Millisecond, 0)(func(ctx context.Context, data interface{}) error {
+		calls++
+		if calls < 2 {
+			return failure
+		}
+		return nil
+	})
+	if err := h(context.TODO(), 1); err != nil || calls != 2 {
+		t.Fatalf("expected success on second call, got %v after %v calls", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	calls = 0
+	h = middlewares.Retry(5, time.Second, 0)(func(ctx context.Context, data interface{}) error {
+		calls++
+		return failure
+	})
+	if err := h(ctx, 1); err != failure || calls != 1 {
+		t.Fatalf("expected retry to stop on done context, got %v after %v calls", err, calls)
+	}
+
+	calls = 0
+	h = middlewares.Retry(0, time.Millisecond, 0)(func(ctx context.Context, data interface{}) error {
+		calls++
+		return failure
+	})
+	if err := h(context.TODO(), 1); err != failure || calls != 1 {
+		t.Fatalf("expected handler called once without attempts, got %v after %v calls", err, calls)
+	}
+}
+
+type recordingLogger struct {
+	messages []string
+	params   []map[string]interface{}
+}
+
+func (l *recordingLogger) Log(message string, location string, params map[string]interface{}, trace string) {
+	l.messages = append(l.messages, message)
+	l.params = append(l.params, params)
+}
+
+func Test_Logger(t *testing.T) {
+	l := &recordingLogger{}
+	h := middlewares.Logger(l, "orders")(func(ctx context.Context, data interface{}) error {
+		if data == 2 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	h(context.TODO(), 1)
+	h(context.TODO(), 2)
+	if len(l.messages) != 2 || l.messages[1] != "event handler failed" || l.params[1]["error"] != "failed" {
+		t.Fatalf("unexpected logs %v %v", l.messages, l.params)
+	}
+	if l.params[0]["handler"] != "orders" || l.params[0]["data"] != 1 {
+		t.Fatalf("unexpected log params %v", l.params[0])
+	}
+}
+
+func Test_Instrument(t *testing.T) {
+	h := middlewares.Instrument("test-instrument")(func(ctx context.Context, data interface{}) error {
+		if data == 2 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	h(context.TODO(), 1)
+	h(context.TODO(), 1)
+	h(context.TODO(), 2)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]float64{}
+	for _, f := range families {
+		if f.GetName() != "event_handler_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["handler"] == "test-instrument" {
+				counts[labels["result"]] = m.GetCounter().GetValue()
+			}
+		}
+	}
+	if counts["success"] != 2 || counts["error"] != 1 {
+		t.Fatalf("unexpected counts %v", counts)
+	}
+}
@@ -0,0 +1,18 @@
+package middlewares
+
+import (
+	"context"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+)
+
+// PanicError is returned by handler wrapped with Recoverer when it panics
+type PanicError = event.PanicError
+
+// Recoverer is a middleware that recovers from panics, logs the panic and
+// returns *PanicError so listener treats the data as failed
+func Recoverer(next event.ListenerHandler) event.ListenerHandler {
+	return func(ctx context.Context, data interface{}) error {
+		return event.CallHandler(ctx, next, data)
+	}
+}
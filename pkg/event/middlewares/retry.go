@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+)
+
+// Retry is a middleware that calls handler up to attempts times while it returns error,
+// waiting initial backoff which doubles after each attempt up to max. Handler is called at least once.
+// The last error is returned when all attempts fail or ctx is done
+func Retry(attempts int, initial, max time.Duration) event.Middleware {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return func(next event.ListenerHandler) event.ListenerHandler {
+		return func(ctx context.Context, data interface{}) error {
+			backoff := initial
+			var err error
+			for i := 0; i < attempts; i++ {
+				if i > 0 {
+					select {
+					case <-ctx.Done():
+						return err
+					case <-time.After(backoff):
+					}
+					backoff *= 2
+					if max > 0 && backoff > max {
+						backoff = max
+					}
+				}
+				err = next(ctx, data)
+				if err == nil {
+					return nil
+				}
+			}
+			return err
+		}
+	}
+}
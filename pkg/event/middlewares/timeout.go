@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+)
+
+// Timeout is a middleware that cancels handler context after d and returns
+// context.DeadlineExceeded without waiting for handlers ignoring the cancellation,
+// handler panic is recovered and returned as *PanicError
+func Timeout(d time.Duration) event.Middleware {
+	return func(next event.ListenerHandler) event.ListenerHandler {
+		return func(ctx context.Context, data interface{}) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			ch := make(chan error, 1)
+			go func() {
+				// panic of handler goroutine is out of reach of Recoverer wrapping Timeout
+				ch <- event.CallHandler(ctx, next, data)
+			}()
+			select {
+			case err := <-ch:
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
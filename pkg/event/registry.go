@@ -0,0 +1,286 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+)
+
+// Message is event data envelope carrying event type and schema version of its data
+type Message struct {
+	Type    string      `json:"type"`
+	Version int         `json:"version"`
+	Data    interface{} `json:"data"`
+}
+
+// NewMessage returns new Message
+func NewMessage(eventType string, version int, data interface{}) Message {
+	return Message{
+		Type:    eventType,
+		Version: version,
+		Data:    data,
+	}
+}
+
+// Compatibility is rule applied when a new schema version is registered
+type Compatibility string
+
+const (
+	// CompatibilityNone does not check compatibility
+	CompatibilityNone Compatibility = "none"
+	// CompatibilityBackward requires consumers using new schema to be able to read data of previous schema
+	CompatibilityBackward Compatibility = "backward"
+	// CompatibilityForward requires consumers using previous schema to be able to read data of new schema
+	CompatibilityForward Compatibility = "forward"
+	// CompatibilityFull requires both backward and forward compatibility
+	CompatibilityFull Compatibility = "full"
+)
+
+// Registry maps event type and version to Schema
+type Registry struct {
+	compatibility Compatibility
+	schemas       map[string]map[int]*Schema
+	mu            sync.RWMutex
+}
+
+// NewRegistry returns new Registry which checks compatibility of registered schema
+// against nearest lower and nearest higher registered versions of the same event type
+func NewRegistry(compatibility Compatibility) *Registry {
+	return &Registry{
+		compatibility: compatibility,
+		schemas:       map[string]map[int]*Schema{},
+	}
+}
+
+// Register parses and registers JSON Schema of event type version
+func (r *Registry) Register(eventType string, version int, schema []byte) error {
+	s, err := ParseSchema(schema)
+	if err != nil {
+		return err
+	}
+	return r.RegisterSchema(eventType, version, s)
+}
+
+// RegisterSchema registers schema of event type version
+func (r *Registry) RegisterSchema(eventType string, version int, schema *Schema) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions, ok := r.schemas[eventType]
+	if !ok {
+		versions = map[int]*Schema{}
+		r.schemas[eventType] = versions
+	}
+	if _, exists := versions[version]; exists {
+		return fmt.Errorf("schema of event '%s' version %d is already registered", eventType, version)
+	}
+	previous, next := 0, 0
+	for v := range versions {
+		if v < version && v > previous {
+			previous = v
+		}
+		if v > version && (next == 0 || v < next) {
+			next = v
+		}
+	}
+	if previous > 0 {
+		err := CheckCompatibility(versions[previous], schema, r.compatibility)
+		if err != nil {
+			return err
+		}
+	}
+	// version registered out of order must also be compatible with the version following it
+	if next > 0 {
+		err := CheckCompatibility(schema, versions[next], r.compatibility)
+		if err != nil {
+			return err
+		}
+	}
+	versions[version] = schema
+	return nil
+}
+
+// Get returns schema of event type version
+func (r *Registry) Get(eventType string, version int) (*Schema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.schemas[eventType][version]
+	return s, ok
+}
+
+// Versions returns registered versions of event type in ascending order
+func (r *Registry) Versions(eventType string) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := []int{}
+	for v := range r.schemas[eventType] {
+		result = append(result, v)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// Validate validates data, which must be a Message or its json representation,
+// against schema registered for the message type and version
+func (r *Registry) Validate(data interface{}) error {
+	msg, err := toMessage(data)
+	if err != nil {
+		e := errors.NewValidationError("invalid event message")
+		e.FieldError("$", err.Error())
+		return e
+	}
+	if msg.Type == "" {
+		e := errors.NewValidationError("invalid event message")
+		e.FieldRequired("type")
+		return e
+	}
+	s, ok := r.Get(msg.Type, msg.Version)
+	if !ok {
+		e := errors.NewValidationError(fmt.Sprintf("schema of event '%s' version %d is not registered", msg.Type, msg.Version))
+		e.FieldInvalid("version")
+		return e
+	}
+	return s.Validate(msg.Data)
+}
+
+func toMessage(data interface{}) (Message, error) {
+	switch d := data.(type) {
+	case Message:
+		return d, nil
+	case *Message:
+		return *d, nil
+	}
+	var bs []byte
+	switch d := data.(type) {
+	case []byte:
+		bs = d
+	case string:
+		bs = []byte(d)
+	default:
+		b, err := json.Marshal(data)
+		if err != nil {
+			return Message{}, err
+		}
+		bs = b
+	}
+	var msg Message
+	err := json.Unmarshal(bs, &msg)
+	return msg, err
+}
+
+// CheckCompatibility checks next schema against previous schema using compatibility rule,
+// returned error is errors.ValidationError with incompatible field paths
+func CheckCompatibility(previous, next *Schema, compatibility Compatibility) error {
+	e := errors.NewValidationError("incompatible schema")
+	switch compatibility {
+	case CompatibilityBackward:
+		checkReadable(next, previous, "", &e)
+	case CompatibilityForward:
+		checkReadable(previous, next, "", &e)
+	case CompatibilityFull:
+		checkReadable(next, previous, "", &e)
+		checkReadable(previous, next, "", &e)
+	}
+	if e.HasFieldErrors() {
+		return e
+	}
+	return nil
+}
+
+// checkReadable checks that all data valid against writer schema is also valid against reader schema
+func checkReadable(reader, writer *Schema, path string, e *errors.ValidationError) {
+	if len(reader.Type) > 0 {
+		if len(writer.Type) == 0 {
+			e.FieldError(pathName(path), "type constraint added")
+			return
+		}
+		for _, t := range writer.Type {
+			if !typeIncluded(t, reader.Type) {
+				e.FieldError(pathName(path), fmt.Sprintf("type '%s' is no longer allowed", t))
+				return
+			}
+		}
+	}
+	if len(reader.Enum) > 0 {
+		if len(writer.Enum) == 0 {
+			e.FieldError(pathName(path), "enum constraint added")
+		}
+		for _, v := range writer.Enum {
+			if !containsValue(reader.Enum, v) {
+				e.FieldError(pathName(path), fmt.Sprintf("enum value %v is no longer allowed", v))
+			}
+		}
+	}
+	if reader.Pattern != "" && reader.Pattern != writer.Pattern {
+		e.FieldError(pathName(path), "pattern changed")
+	}
+	if !boundCovered(reader.Minimum, writer.Minimum, func(r, w float64) bool { return r <= w }) ||
+		!boundCovered(reader.Maximum, writer.Maximum, func(r, w float64) bool { return r >= w }) {
+		e.FieldError(pathName(path), "range narrowed")
+	}
+	if !boundCovered(intBound(reader.MinLength), intBound(writer.MinLength), func(r, w float64) bool { return r <= w }) ||
+		!boundCovered(intBound(reader.MaxLength), intBound(writer.MaxLength), func(r, w float64) bool { return r >= w }) {
+		e.FieldError(pathName(path), "length range narrowed")
+	}
+
+	for _, name := range reader.Required {
+		if !containsString(writer.Required, name) {
+			e.FieldError(fieldPath(path, name), "field became required")
+		}
+	}
+	for name, rp := range reader.Properties {
+		if wp, ok := writer.Properties[name]; ok {
+			checkReadable(rp, wp, fieldPath(path, name), e)
+		}
+	}
+	if reader.AdditionalProperties != nil && !*reader.AdditionalProperties {
+		for name := range writer.Properties {
+			if _, ok := reader.Properties[name]; !ok {
+				e.FieldError(fieldPath(path, name), "field is no longer allowed")
+			}
+		}
+	}
+	if reader.Items != nil && writer.Items != nil {
+		checkReadable(reader.Items, writer.Items, fieldPath(path, "[]"), e)
+	}
+}
+
+// typeIncluded returns true when values of type t are allowed by types
+func typeIncluded(t string, types SchemaTypes) bool {
+	for _, name := range types {
+		if name == t || (name == "number" && t == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// boundCovered returns true when reader bound accepts every value accepted by writer bound
+func boundCovered(reader, writer *float64, covers func(r, w float64) bool) bool {
+	if reader == nil {
+		return true
+	}
+	if writer == nil {
+		return false
+	}
+	return covers(*reader, *writer)
+}
+
+func intBound(i *int) *float64 {
+	if i == nil {
+		return nil
+	}
+	f := float64(*i)
+	return &f
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package event_test
+
+import (
+	"testing"
+
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+	"github.com/pinkgorilla/go-sample/pkg/event"
+)
+
+const orderCreatedV1 = `{
+	"type": "object",
+	"required": ["id", "amount"],
+	"properties": {
+		"id": {"type": "string", "minLength": 1},
+		"amount": {"type": "number", "minimum": 0},
+		"status": {"type": "string", "enum": ["new", "paid"]},
+		"items": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["name"],
+				"properties": {"name": {"type": "string"}, "qty": {"type": "integer"}}
+			}
+		}
+	}
+}`
+
+func Test_Schema_Validate(t *testing.T) {
+	s, err := event.ParseSchema([]byte(orderCreatedV1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := map[string]interface{}{
+		"id":     "o-1",
+		"amount": 10.5,
+		"status": "paid",
+		"items":  []interface{}{map[string]interface{}{"name": "book", "qty": 2}},
+	}
+	if err := s.Validate(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := map[string]interface{}{
+		"amount": -1,
+		"status": "unknown",
+		"items":  []interface{}{map[string]interface{}{"qty": 1.5}},
+	}
+	err = s.Validate(invalid)
+	e, ok := err.(errors.ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	for _, field := range []string{"id", "amount", "status", "items.0.name", "items.0.qty"} {
+		if !e.HasFieldError(field) {
+			t.Fatalf("expected field error on %s, got %v", field, e.Fields)
+		}
+	}
+}
+
+func Test_ParseSchema_Invalid(t *testing.T) {
+	cases := []string{
+		`{"type": "unknown"}`,
+		`{"type": "string", "pattern": "("}`,
+		`{"type": 1}`,
+	}
+	for _, c := range cases {
+		if _, err := event.ParseSchema([]byte(c)); err == nil {
+			t.Fatalf("expected error parsing %s", c)
+		}
+	}
+}
+
+func Test_Registry_Validate(t *testing.T) {
+	r := event.NewRegistry(event.CompatibilityBackward)
+	if err := r.Register("order.created", 1, []byte(orderCreatedV1)); err != nil {
+		t.Fatal(err)
+	}
+
+	err := r.Validate(event.NewMessage("order.created", 1, map[string]interface{}{"id": "o-1", "amount": 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Validate(`{"type":"order.created","version":1,"data":{"id":"o-1"}}`)
+	if err == nil {
+		t.Fatal("expected missing amount error")
+	}
+	err = r.Validate(event.NewMessage("order.created", 2, map[string]interface{}{}))
+	if _, ok := err.(errors.ValidationError); !ok {
+		t.Fatalf("expected ValidationError on unknown version, got %v", err)
+	}
+	err = r.Validate(map[string]interface{}{"id": "o-1"})
+	if _, ok := err.(errors.ValidationError); !ok {
+		t.Fatalf("expected ValidationError on missing envelope, got %v", err)
+	}
+}
+
+func Test_Registry_Compatibility(t *testing.T) {
+	// v2 adds optional field and widens amount type
+	v2 := `{
+		"type": "object",
+		"required": ["id", "amount"],
+		"properties": {
+			"id": {"type": "string", "minLength": 1},
+			"amount": {"type": ["number", "string"]},
+			"currency": {"type": "string"}
+		}
+	}`
+	// v3 requires a field which is absent in v2 data
+	v3 := `{
+		"type": "object",
+		"required": ["id", "amount", "customer"],
+		"properties": {
+			"id": {"type": "string"},
+			"amount": {"type": "number"},
+			"customer": {"type": "string"}
+		}
+	}`
+
+	r := event.NewRegistry(event.CompatibilityBackward)
+	if err := r.Register("order.created", 1, []byte(orderCreatedV1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("order.created", 2, []byte(v2)); err != nil {
+		t.Fatal(err)
+	}
+	err := r.Register("order.created", 3, []byte(v3))
+	e, ok := err.(errors.ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	if !e.HasFieldError("customer") || !e.HasFieldError("amount") {
+		t.Fatalf("expected customer and amount incompatibility, got %v", e.Fields)
+	}
+	if err := r.Register("order.created", 2, []byte(v2)); err == nil {
+		t.Fatal("expected error registering existing version")
+	}
+
+	f := event.NewRegistry(event.CompatibilityForward)
+	f.Register("order.created", 1, []byte(orderCreatedV1))
+	if err := f.Register("order.created", 2, []byte(v2)); err == nil {
+		t.Fatal("expected forward incompatibility of widened amount type")
+	}
+
+	n := event.NewRegistry(event.CompatibilityNone)
+	n.Register("order.created", 2, []byte(v2))
+	if err := n.Register("order.created", 3, []byte(v3)); err != nil {
+		t.Fatal(err)
+	}
+	if vs := n.Versions("order.created"); len(vs) != 2 || vs[0] != 2 || vs[1] != 3 {
+		t.Fatalf("unexpected versions %v", vs)
+	}
+
+	// v2 registered after v3 is compatible with v1 but v3 can not read its widened amount
+	o := event.NewRegistry(event.CompatibilityBackward)
+	o.Register("order.created", 1, []byte(orderCreatedV1))
+	if err := o.Register("order.created", 3, []byte(orderCreatedV1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Register("order.created", 2, []byte(v2)); err == nil {
+		t.Fatal("expected incompatibility with following version")
+	}
+}
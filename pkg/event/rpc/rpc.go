@@ -0,0 +1,140 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+	"github.com/pinkgorilla/go-sample/pkg/event/internal/eventutil"
+	"github.com/pinkgorilla/go-sample/pkg/generator"
+)
+
+// Request is envelope of request payload sent over queue
+type Request struct {
+	CorrelationID string      `json:"correlationId"`
+	ReplyTo       string      `json:"replyTo"`
+	Payload       interface{} `json:"payload"`
+}
+
+// Reply is envelope of reply payload sent over queue
+type Reply struct {
+	CorrelationID string      `json:"correlationId"`
+	Payload       interface{} `json:"payload"`
+	Error         string      `json:"error,omitempty"`
+}
+
+// ReplyError is error returned by request handler on the replying side
+type ReplyError struct {
+	Message string
+}
+
+func (e ReplyError) Error() string {
+	return e.Message
+}
+
+// Handler is request handler returning reply payload
+type Handler func(ctx context.Context, payload interface{}) (interface{}, error)
+
+// ReplyEmitterFn returns emitter of reply queue named replyTo
+type ReplyEmitterFn func(replyTo string) (event.Emitter, error)
+
+// NewListenerHandler returns event.ListenerHandler which decodes Request, calls h
+// and emits Reply to the emitter returned by replies for Request.ReplyTo.
+//
+// Error returned by h is sent to requester as Reply.Error, error is returned by the
+// listener handler only when reply cannot be emitted, so the request is retried by listener
+func NewListenerHandler(h Handler, replies ReplyEmitterFn) event.ListenerHandler {
+	return func(ctx context.Context, data interface{}) error {
+		var req Request
+		err := eventutil.Decode(data, &req)
+		if err != nil {
+			log.Println("rpc: discarding invalid request", data, err)
+			return nil
+		}
+		payload, err := h(ctx, req.Payload)
+		reply := Reply{
+			CorrelationID: req.CorrelationID,
+			Payload:       payload,
+		}
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		emitter, err := replies(req.ReplyTo)
+		if err != nil {
+			return err
+		}
+		return emitter.Emit(reply)
+	}
+}
+
+// Client sends requests and awaits their replies
+type Client struct {
+	requests event.Emitter
+	replies  event.Listener
+	replyTo  string
+	pending  sync.Map
+}
+
+// NewClient returns new Client, requests are emitted through requests emitter,
+// replies are received from replies listener which listens on queue named replyTo
+func NewClient(requests event.Emitter, replies event.Listener, replyTo string) *Client {
+	return &Client{
+		requests: requests,
+		replies:  replies,
+		replyTo:  replyTo,
+	}
+}
+
+// Listen is a routine dispatching replies to awaiting requests,
+// replies without awaiting request, e.g. arrived after request timed out, are discarded
+func (c *Client) Listen(ctx context.Context) {
+	c.replies.Listen(ctx, c.handleReply)
+}
+
+func (c *Client) handleReply(ctx context.Context, data interface{}) error {
+	var reply Reply
+	err := eventutil.Decode(data, &reply)
+	if err != nil {
+		log.Println("rpc: discarding invalid reply", data, err)
+		return nil
+	}
+	ch, ok := c.pending.Load(reply.CorrelationID)
+	if !ok {
+		log.Println("rpc: discarding late reply", reply.CorrelationID)
+		return nil
+	}
+	select {
+	case ch.(chan Reply) <- reply:
+	default:
+		// duplicate reply
+	}
+	return nil
+}
+
+// Request emits payload and waits for its reply until ctx is done
+func (c *Client) Request(ctx context.Context, payload interface{}) (interface{}, error) {
+	req := Request{
+		CorrelationID: generator.UUID(),
+		ReplyTo:       c.replyTo,
+		Payload:       payload,
+	}
+	ch := make(chan Reply, 1)
+	c.pending.Store(req.CorrelationID, ch)
+	defer c.pending.Delete(req.CorrelationID)
+
+	err := c.requests.Emit(req)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("rpc: request %s: %w", req.CorrelationID, ctx.Err())
+	case reply := <-ch:
+		if reply.Error != "" {
+			return reply.Payload, ReplyError{reply.Error}
+		}
+		return reply.Payload, nil
+	}
+}
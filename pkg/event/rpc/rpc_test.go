@@ -0,0 +1,139 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+	"github.com/pinkgorilla/go-sample/pkg/event/managed"
+	"github.com/pinkgorilla/go-sample/pkg/event/rpc"
+)
+
+// jsonQueue is channel queue which round trips data through json like remote queues do
+type jsonQueue struct {
+	*managed.ChannelQueue
+}
+
+func newQueue() managed.Queue {
+	return &jsonQueue{managed.NewChannelQueue()}
+}
+
+func (q *jsonQueue) Push(data interface{}) error {
+	s, err := managed.DataToJSON(data)
+	if err != nil {
+		return err
+	}
+	return q.ChannelQueue.Push(s)
+}
+
+func (q *jsonQueue) Pull() (interface{}, error) {
+	data, err := q.ChannelQueue.Pull()
+	if data == nil || err != nil {
+		return data, err
+	}
+	return managed.JSONToData(data)
+}
+
+func setup(t *testing.T, ctx context.Context, h rpc.Handler) *rpc.Client {
+	requests := newQueue()
+	replies := newQueue()
+
+	// replying side
+	replyEmitters := map[string]event.Emitter{
+		"client-a": managed.NewEmitter(replies, managed.NewChannelQueue()),
+	}
+	server := managed.NewListener(requests, managed.NewChannelQueue())
+	go server.Listen(ctx, rpc.NewListenerHandler(h, func(replyTo string) (event.Emitter, error) {
+		e, ok := replyEmitters[replyTo]
+		if !ok {
+			return nil, fmt.Errorf("unknown reply queue %s", replyTo)
+		}
+		return e, nil
+	}))
+
+	// requesting side
+	client := rpc.NewClient(
+		managed.NewEmitter(requests, managed.NewChannelQueue()),
+		managed.NewListenerWithConfig(replies, managed.NewChannelQueue(), &managed.ListenerConfig{
+			PollInterval: 10 * time.Millisecond,
+		}),
+		"client-a",
+	)
+	go client.Listen(ctx)
+	return client
+}
+
+func Test_Client_Request(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := setup(t, ctx, func(ctx context.Context, payload interface{}) (interface{}, error) {
+		return fmt.Sprintf("hello %v", payload), nil
+	})
+
+	rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer rcancel()
+	reply, err := client.Request(rctx, "john")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "hello john" {
+		t.Fatalf("expected 'hello john', got %v", reply)
+	}
+}
+
+func Test_Client_Request_HandlerError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := setup(t, ctx, func(ctx context.Context, payload interface{}) (interface{}, error) {
+		return nil, errors.New("insufficient balance")
+	})
+
+	rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer rcancel()
+	_, err := client.Request(rctx, "john")
+	var replyErr rpc.ReplyError
+	if !errors.As(err, &replyErr) {
+		t.Fatalf("expected ReplyError, got %v", err)
+	}
+	if replyErr.Message != "insufficient balance" {
+		t.Fatalf("expected 'insufficient balance', got %s", replyErr.Message)
+	}
+}
+
+func Test_Client_Request_Timeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	replied := make(chan struct{})
+	client := setup(t, ctx, func(ctx context.Context, payload interface{}) (interface{}, error) {
+		if payload == "slow" {
+			<-time.After(500 * time.Millisecond)
+			defer close(replied)
+		}
+		return payload, nil
+	})
+
+	rctx, rcancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer rcancel()
+	_, err := client.Request(rctx, "slow")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// late reply should be discarded without affecting next request
+	<-replied
+	rctx2, rcancel2 := context.WithTimeout(ctx, 5*time.Second)
+	defer rcancel2()
+	reply, err := client.Request(rctx2, "fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "fast" {
+		t.Fatalf("expected 'fast', got %v", reply)
+	}
+}
@@ -0,0 +1,449 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event"
+	"github.com/pinkgorilla/go-sample/pkg/event/internal/eventutil"
+	"github.com/pinkgorilla/go-sample/pkg/generator"
+)
+
+// ErrTimeout is recorded as saga error when a step does not complete before its timeout
+var ErrTimeout = errors.New("saga: step timed out")
+
+// Step is a saga step and its compensating action
+type Step struct {
+	Name string
+	// Command returns payload of command executing the step, saga data is used when nil
+	Command func(state State) interface{}
+	// Compensation returns payload of command undoing the step, step is not compensated when nil
+	Compensation func(state State) interface{}
+	// Timeout is max duration to wait for step result, 0 waits forever
+	Timeout time.Duration
+}
+
+// Definition is saga definition, steps are executed in order and
+// compensated in reverse order when a step fails
+type Definition struct {
+	Name  string
+	Steps []Step
+}
+
+// Command is envelope of command emitted by Engine to saga participants
+type Command struct {
+	SagaID     string      `json:"sagaId"`
+	Saga       string      `json:"saga"`
+	Step       string      `json:"step"`
+	Compensate bool        `json:"compensate"`
+	Payload    interface{} `json:"payload"`
+}
+
+// Result is envelope of step result sent by saga participants to Engine
+type Result struct {
+	SagaID     string                 `json:"sagaId"`
+	Step       string                 `json:"step"`
+	Compensate bool                   `json:"compensate"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// NewResult returns Result of command, data is merged into saga data on success
+func NewResult(cmd Command, data map[string]interface{}, err error) Result {
+	r := Result{
+		SagaID:     cmd.SagaID,
+		Step:       cmd.Step,
+		Compensate: cmd.Compensate,
+		Data:       data,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// Engine orchestrates sagas, it emits commands through commands emitter, e.g. managed.Emitter,
+// and advances sagas on results received by Handle, e.g. from managed.Listener
+type Engine struct {
+	store       Store
+	commands    event.Emitter
+	definitions map[string]Definition
+	mu          sync.Mutex
+}
+
+// NewEngine returns new Engine
+func NewEngine(store Store, commands event.Emitter) *Engine {
+	return &Engine{
+		store:       store,
+		commands:    commands,
+		definitions: map[string]Definition{},
+	}
+}
+
+// Register registers saga definition
+func (e *Engine) Register(def Definition) error {
+	if def.Name == "" || len(def.Steps) == 0 {
+		return fmt.Errorf("saga: definition must have name and steps")
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.definitions[def.Name]; ok {
+		return fmt.Errorf("saga: '%s' is already registered", def.Name)
+	}
+	e.definitions[def.Name] = def
+	return nil
+}
+
+// Start starts new instance of saga with data and emits command of its first step
+func (e *Engine) Start(ctx context.Context, saga string, data map[string]interface{}) (State, error) {
+	e.mu.Lock()
+	def, ok := e.definitions[saga]
+	e.mu.Unlock()
+	if !ok {
+		return State{}, fmt.Errorf("saga: '%s' is not registered", saga)
+	}
+	now := time.Now().UTC()
+	state := State{
+		ID:        generator.UUID(),
+		Saga:      saga,
+		Status:    StatusRunning,
+		Data:      data,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if state.Data == nil {
+		state.Data = map[string]interface{}{}
+	}
+	cmd, err := e.execute(ctx, def, &state)
+	if err != nil {
+		return state, err
+	}
+	return state, e.emit(cmd)
+}
+
+// Listen is a routine handling step results received by listener
+func (e *Engine) Listen(ctx context.Context, listener event.Listener) {
+	listener.Listen(ctx, e.Handle)
+}
+
+// Handle is event.ListenerHandler advancing saga on step Result.
+// Results of unknown or finished sagas are discarded, redelivered result of step preceding current step
+// emits command of current step again, other stale results are discarded. Success of a step arriving
+// after the step was compensated, e.g. after it timed out, emits its compensation again so its late
+// side effect is undone. Result handled concurrently by another engine sharing the store is discarded
+func (e *Engine) Handle(ctx context.Context, data interface{}) error {
+	var result Result
+	if err := eventutil.Decode(data, &result); err != nil {
+		log.Println("saga: discarding invalid result", data, err)
+		return nil
+	}
+	cmd, err := e.handle(ctx, result)
+	if err != nil {
+		return err
+	}
+	return e.emit(cmd)
+}
+
+// handle advances saga state and returns command to emit, if any
+func (e *Engine) handle(ctx context.Context, result Result) (*Command, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	state, err := e.store.Load(ctx, result.SagaID)
+	if err == ErrNotFound {
+		log.Println("saga: discarding result of unknown saga", result.SagaID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	def, ok := e.definitions[state.Saga]
+	if !ok {
+		log.Println("saga: discarding result of unregistered saga", result.SagaID, state.Saga)
+		return nil, nil
+	}
+	if cmd := late(def, state, result); cmd != nil {
+		log.Println("saga: compensating step succeeded after its compensation started", result.SagaID, result.Step)
+		return cmd, nil
+	}
+	if state.Done() {
+		log.Println("saga: discarding unexpected result", result.SagaID, result.Step)
+		return nil, nil
+	}
+	if def.Steps[state.Step].Name != result.Step || (state.Status == StatusCompensating) != result.Compensate {
+		if !redelivered(def, state, result) {
+			log.Println("saga: discarding stale result", result.SagaID, result.Step)
+			return nil, nil
+		}
+		// result redelivered as emitting the command it advanced the saga to failed,
+		// command of current step is emitted again so the saga does not stall
+		log.Println("saga: emitting current command again on redelivered result", result.SagaID, result.Step)
+		return pending(def, state), nil
+	}
+	cmd, err := e.advance(ctx, def, &state, result)
+	if err == ErrConflict {
+		log.Println("saga: discarding result handled concurrently", result.SagaID, result.Step)
+		return nil, nil
+	}
+	return cmd, err
+}
+
+// advance advances saga by result of its current step
+func (e *Engine) advance(ctx context.Context, def Definition, state *State, result Result) (*Command, error) {
+	if state.Status == StatusCompensating {
+		if result.Error != "" {
+			state.Status = StatusFailed
+			state.Error = result.Error
+			state.Deadline = time.Time{}
+			return nil, e.save(ctx, state)
+		}
+		state.Step--
+		return e.compensate(ctx, def, state)
+	}
+	if result.Error != "" {
+		state.Error = result.Error
+		state.Step--
+		return e.compensate(ctx, def, state)
+	}
+	for k, v := range result.Data {
+		state.Data[k] = v
+	}
+	state.Step++
+	return e.execute(ctx, def, state)
+}
+
+// redelivered returns true when result is the result which advanced saga to its current step,
+// i.e. result of previous step of running saga, or result of failed or compensated step
+// preceding current step of compensating saga
+func redelivered(def Definition, state State, result Result) bool {
+	i := index(def, result.Step)
+	if state.Status == StatusRunning {
+		return !result.Compensate && result.Error == "" && i >= 0 && i == state.Step-1
+	}
+	if i <= state.Step {
+		return false
+	}
+	for j := state.Step + 1; j < i; j++ {
+		if def.Steps[j].Compensation != nil {
+			return false
+		}
+	}
+	if result.Compensate {
+		return result.Error == ""
+	}
+	return result.Error != ""
+}
+
+// execute returns command of current step or completes the saga after its last step
+func (e *Engine) execute(ctx context.Context, def Definition, state *State) (*Command, error) {
+	if state.Step >= len(def.Steps) {
+		state.Step = len(def.Steps) - 1
+		state.Status = StatusCompleted
+		state.Deadline = time.Time{}
+		return nil, e.save(ctx, state)
+	}
+	step := def.Steps[state.Step]
+	return e.command(ctx, state, step, false, payload(step, *state))
+}
+
+// payload returns payload of command executing step
+func payload(step Step, state State) interface{} {
+	if step.Command != nil {
+		return step.Command(state)
+	}
+	return state.Data
+}
+
+// pending returns command of current step of running or compensating saga without changing its state,
+// participants must handle commands idempotently as the command may have been emitted before
+func pending(def Definition, state State) *Command {
+	step := def.Steps[state.Step]
+	if state.Status == StatusCompensating {
+		return newCommand(state, step, true, step.Compensation(state))
+	}
+	return newCommand(state, step, false, payload(step, state))
+}
+
+// late returns compensation command of step whose success arrived when its compensation was already started,
+// i.e. the step is current or already compensated step of compensating saga or any step of compensated saga
+func late(def Definition, state State, result Result) *Command {
+	if result.Compensate || result.Error != "" {
+		return nil
+	}
+	i := index(def, result.Step)
+	if i < 0 || def.Steps[i].Compensation == nil {
+		return nil
+	}
+	switch state.Status {
+	case StatusCompensating:
+		if i < state.Step {
+			return nil
+		}
+	case StatusCompensated, StatusFailed:
+	default:
+		return nil
+	}
+	step := def.Steps[i]
+	return newCommand(state, step, true, step.Compensation(state))
+}
+
+// index returns index of step with name, -1 when saga has no such step
+func index(def Definition, name string) int {
+	for i, step := range def.Steps {
+		if step.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// compensate returns compensation command of current step, steps without compensation are skipped
+func (e *Engine) compensate(ctx context.Context, def Definition, state *State) (*Command, error) {
+	state.Status = StatusCompensating
+	for ; state.Step >= 0; state.Step-- {
+		step := def.Steps[state.Step]
+		if step.Compensation != nil {
+			return e.command(ctx, state, step, true, step.Compensation(*state))
+		}
+	}
+	state.Step = 0
+	state.Status = StatusCompensated
+	state.Deadline = time.Time{}
+	return nil, e.save(ctx, state)
+}
+
+// command saves state waiting for step result and returns command of the step,
+// state is saved before command is emitted so a result never arrives before its state
+func (e *Engine) command(ctx context.Context, state *State, step Step, compensate bool, payload interface{}) (*Command, error) {
+	state.Deadline = time.Time{}
+	if step.Timeout > 0 {
+		state.Deadline = time.Now().UTC().Add(step.Timeout)
+	}
+	if err := e.save(ctx, state); err != nil {
+		return nil, err
+	}
+	return newCommand(*state, step, compensate, payload), nil
+}
+
+func newCommand(state State, step Step, compensate bool, payload interface{}) *Command {
+	return &Command{
+		SagaID:     state.ID,
+		Saga:       state.Saga,
+		Step:       step.Name,
+		Compensate: compensate,
+		Payload:    payload,
+	}
+}
+
+// emit emits cmd, it is called without holding engine lock
+// so results delivered synchronously by emitter can be handled
+func (e *Engine) emit(cmd *Command) error {
+	if cmd == nil {
+		return nil
+	}
+	return e.commands.Emit(*cmd)
+}
+
+// save saves state and increments its version, ErrConflict is returned when state was saved concurrently
+func (e *Engine) save(ctx context.Context, state *State) error {
+	state.UpdatedAt = time.Now().UTC()
+	if err := e.store.Save(ctx, *state); err != nil {
+		return err
+	}
+	state.Version++
+	return nil
+}
+
+// Watch is a routine checking step timeouts every interval.
+// Timed out step is compensated starting with the step itself as its command may still be executed,
+// timed out compensation is emitted again
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.checkTimeouts(ctx); err != nil {
+				log.Println("saga: failed to check timeouts", err)
+			}
+		}
+	}
+}
+
+func (e *Engine) checkTimeouts(ctx context.Context) error {
+	commands, err := e.timeouts(ctx)
+	for _, cmd := range commands {
+		if er := e.emit(cmd); er != nil && err == nil {
+			err = er
+		}
+	}
+	return err
+}
+
+// timeouts advances sagas whose step timed out and returns commands to emit
+func (e *Engine) timeouts(ctx context.Context) ([]*Command, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	states, err := e.store.Find(ctx, Query{Statuses: []string{StatusRunning, StatusCompensating}})
+	if err != nil {
+		return nil, err
+	}
+	commands := []*Command{}
+	now := time.Now()
+	for _, state := range states {
+		def, ok := e.definitions[state.Saga]
+		if !ok || state.Deadline.IsZero() || now.Before(state.Deadline) {
+			continue
+		}
+		state := state
+		var cmd *Command
+		if state.Status == StatusCompensating {
+			step := def.Steps[state.Step]
+			cmd, err = e.command(ctx, &state, step, true, step.Compensation(state))
+		} else {
+			state.Error = fmt.Sprintf("%v: %s", ErrTimeout, def.Steps[state.Step].Name)
+			cmd, err = e.compensate(ctx, def, &state)
+		}
+		if err == ErrConflict {
+			// saga advanced or timed out concurrently, e.g. by Watch of another engine
+			continue
+		}
+		if err != nil {
+			return commands, err
+		}
+		if cmd != nil {
+			commands = append(commands, cmd)
+		}
+	}
+	return commands, nil
+}
+
+// Get returns saga state
+func (e *Engine) Get(ctx context.Context, id string) (State, error) {
+	return e.store.Load(ctx, id)
+}
+
+// Find returns sagas matching query
+func (e *Engine) Find(ctx context.Context, query Query) ([]State, error) {
+	return e.store.Find(ctx, query)
+}
+
+// Stuck returns sagas which did not progress for olderThan, including failed sagas
+func (e *Engine) Stuck(ctx context.Context, olderThan time.Duration) ([]State, error) {
+	return e.store.Find(ctx, Query{
+		Statuses:      []string{StatusRunning, StatusCompensating, StatusFailed},
+		UpdatedBefore: time.Now().UTC().Add(-olderThan),
+	})
+}
+
+// DecodeCommand decodes Command received by saga participant
+func DecodeCommand(data interface{}) (Command, error) {
+	var cmd Command
+	err := eventutil.Decode(data, &cmd)
+	return cmd, err
+}
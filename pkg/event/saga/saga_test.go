@@ -0,0 +1,428 @@
+package saga_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event/managed"
+	"github.com/pinkgorilla/go-sample/pkg/event/saga"
+)
+
+// participant handles saga commands and replies with results
+type participant struct {
+	results  *managed.Emitter
+	handled  []string
+	failStep string
+	skipStep string
+	mu       sync.Mutex
+}
+
+func (p *participant) handle(ctx context.Context, data interface{}) error {
+	cmd, err := saga.DecodeCommand(data)
+	if err != nil {
+		return err
+	}
+	name := cmd.Step
+	if cmd.Compensate {
+		name = "undo-" + name
+	}
+	p.mu.Lock()
+	p.handled = append(p.handled, name)
+	p.mu.Unlock()
+	switch {
+	case cmd.Step == p.skipStep && !cmd.Compensate:
+		return nil
+	case cmd.Step == p.failStep && !cmd.Compensate:
+		return p.results.Emit(saga.NewResult(cmd, nil, errors.New("card declined")))
+	}
+	return p.results.Emit(saga.NewResult(cmd, map[string]interface{}{name: true}, nil))
+}
+
+func (p *participant) steps() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return strings.Join(p.handled, ",")
+}
+
+func order(timeout time.Duration) saga.Definition {
+	undo := func(state saga.State) interface{} { return state.Data["order"] }
+	return saga.Definition{
+		Name: "order",
+		Steps: []saga.Step{
+			{Name: "reserve", Compensation: undo, Timeout: timeout},
+			{Name: "notify", Timeout: timeout},
+			{Name: "charge", Compensation: undo, Timeout: timeout},
+			{Name: "ship", Timeout: timeout},
+		},
+	}
+}
+
+func setup(t *testing.T, ctx context.Context, p *participant, timeout time.Duration) *saga.Engine {
+	commands := managed.NewChannelQueue()
+	results := managed.NewChannelQueue()
+	config := &managed.ListenerConfig{PollInterval: 10 * time.Millisecond}
+
+	engine := saga.NewEngine(saga.NewInMemoryStore(), managed.NewEmitter(commands, managed.NewChannelQueue()))
+	if err := engine.Register(order(timeout)); err != nil {
+		t.Fatal(err)
+	}
+	p.results = managed.NewEmitter(results, managed.NewChannelQueue())
+	go managed.NewListenerWithConfig(commands, managed.NewChannelQueue(), config).Listen(ctx, p.handle)
+	go engine.Listen(ctx, managed.NewListenerWithConfig(results, managed.NewChannelQueue(), config))
+	return engine
+}
+
+func wait(t *testing.T, ctx context.Context, engine *saga.Engine, id string) saga.State {
+	for {
+		state, err := engine.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Done() {
+			return state
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("saga did not finish, state %+v", state)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func Test_Engine_Completed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	p := &participant{}
+	engine := setup(t, ctx, p, 0)
+
+	state, err := engine.Start(ctx, "order", map[string]interface{}{"order": "o-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state = wait(t, ctx, engine, state.ID)
+	if state.Status != saga.StatusCompleted {
+		t.Fatalf("expected completed saga, got %+v", state)
+	}
+	if p.steps() != "reserve,notify,charge,ship" {
+		t.Fatalf("unexpected steps %v", p.steps())
+	}
+	if state.Data["charge"] != true || state.Data["order"] != "o-1" {
+		t.Fatalf("expected step results merged into saga data, got %v", state.Data)
+	}
+	if _, err := engine.Start(ctx, "unknown", nil); err == nil {
+		t.Fatal("expected unknown saga error")
+	}
+}
+
+func Test_Engine_Compensated(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	p := &participant{failStep: "charge"}
+	engine := setup(t, ctx, p, 0)
+
+	state, err := engine.Start(ctx, "order", map[string]interface{}{"order": "o-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state = wait(t, ctx, engine, state.ID)
+	if state.Status != saga.StatusCompensated || state.Error != "card declined" {
+		t.Fatalf("expected compensated saga, got %+v", state)
+	}
+	// failed step and steps without compensation are not compensated
+	if p.steps() != "reserve,notify,charge,undo-reserve" {
+		t.Fatalf("unexpected steps %v", p.steps())
+	}
+}
+
+func Test_Engine_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	p := &participant{skipStep: "ship"}
+	engine := setup(t, ctx, p, 100*time.Millisecond)
+	go engine.Watch(ctx, 20*time.Millisecond)
+
+	state, err := engine.Start(ctx, "order", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		state, _ = engine.Get(ctx, state.ID)
+		if state.Step == 3 {
+			break
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+	stuck, err := engine.Stuck(ctx, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stuck) != 0 {
+		t.Fatalf("expected no stuck saga yet, got %v", stuck)
+	}
+	state = wait(t, ctx, engine, state.ID)
+	if state.Status != saga.StatusCompensated || !strings.HasPrefix(state.Error, saga.ErrTimeout.Error()) {
+		t.Fatalf("expected compensated saga after timeout, got %+v", state)
+	}
+	if p.steps() != "reserve,notify,charge,ship,undo-charge,undo-reserve" {
+		t.Fatalf("unexpected steps %v", p.steps())
+	}
+}
+
+func Test_Engine_Stuck(t *testing.T) {
+	testStuck(t, saga.NewInMemoryStore())
+}
+
+func testStuck(t *testing.T, store saga.Store) {
+	ctx := context.TODO()
+	old := time.Now().Add(-time.Hour)
+	store.Save(ctx, saga.State{ID: "1", Saga: "order", Status: saga.StatusRunning, UpdatedAt: old})
+	store.Save(ctx, saga.State{ID: "2", Saga: "order", Status: saga.StatusFailed, UpdatedAt: old})
+	store.Save(ctx, saga.State{ID: "3", Saga: "order", Status: saga.StatusCompleted, UpdatedAt: old})
+	store.Save(ctx, saga.State{ID: "4", Saga: "order", Status: saga.StatusRunning, UpdatedAt: time.Now()})
+
+	engine := saga.NewEngine(store, managed.NewEmitter(managed.NewChannelQueue(), managed.NewChannelQueue()))
+	stuck, err := engine.Stuck(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stuck) != 2 {
+		t.Fatalf("expected 2 stuck sagas, got %+v", stuck)
+	}
+	failed, err := engine.Find(ctx, saga.Query{Saga: "order", Statuses: []string{saga.StatusFailed}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].ID != "2" {
+		t.Fatalf("expected failed saga, got %+v", failed)
+	}
+}
+
+func Test_InMemoryStore_Conflict(t *testing.T) {
+	testConflict(t, saga.NewInMemoryStore())
+}
+
+func testConflict(t *testing.T, store saga.Store) {
+	ctx := context.TODO()
+	state := saga.State{ID: "1", Saga: "order", Status: saga.StatusRunning}
+	if err := store.Save(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(ctx, state); err != saga.ErrConflict {
+		t.Fatalf("expected ErrConflict saving new saga again, got %v", err)
+	}
+	state, _ = store.Load(ctx, "1")
+	if state.Version != 1 {
+		t.Fatalf("expected version 1, got %+v", state)
+	}
+	state.Step = 1
+	if err := store.Save(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+	state.Step = 2
+	if err := store.Save(ctx, state); err != saga.ErrConflict {
+		t.Fatalf("expected ErrConflict saving stale version, got %v", err)
+	}
+	state, _ = store.Load(ctx, "1")
+	if state.Version != 2 || state.Step != 1 {
+		t.Fatalf("expected stale save rejected, got %+v", state)
+	}
+}
+
+// racingStore saves state loaded for the first time again, as another engine handling the same result would
+type racingStore struct {
+	saga.Store
+	raced bool
+}
+
+func (s *racingStore) Load(ctx context.Context, id string) (saga.State, error) {
+	state, err := s.Store.Load(ctx, id)
+	if err == nil && !s.raced {
+		s.raced = true
+		err = s.Store.Save(ctx, state)
+	}
+	return state, err
+}
+
+// flakyEmitter records emitted commands and fails emitting while fail is set
+type flakyEmitter struct {
+	commands []saga.Command
+	fail     bool
+}
+
+func (e *flakyEmitter) Emit(data interface{}) error {
+	if e.fail {
+		return errors.New("queue unavailable")
+	}
+	e.commands = append(e.commands, data.(saga.Command))
+	return nil
+}
+
+func Test_Engine_RedeliveredResult(t *testing.T) {
+	ctx := context.TODO()
+	emitter := &flakyEmitter{}
+	engine := saga.NewEngine(saga.NewInMemoryStore(), emitter)
+	engine.Register(order(0))
+	state, err := engine.Start(ctx, "order", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := saga.NewResult(emitter.commands[0], nil, nil)
+	emitter.fail = true
+	if err := engine.Handle(ctx, result); err == nil {
+		t.Fatal("expected emit error returned for redelivery")
+	}
+	emitter.fail = false
+	if err := engine.Handle(ctx, result); err != nil {
+		t.Fatal(err)
+	}
+	if len(emitter.commands) != 2 || emitter.commands[1].Step != "notify" {
+		t.Fatalf("expected command of current step emitted again, got %+v", emitter.commands)
+	}
+	state, _ = engine.Get(ctx, state.ID)
+	if state.Step != 1 || state.Status != saga.StatusRunning {
+		t.Fatalf("expected saga not advanced by redelivered result, got %+v", state)
+	}
+}
+
+func steps(commands []saga.Command) string {
+	names := []string{}
+	for _, cmd := range commands {
+		name := cmd.Step
+		if cmd.Compensate {
+			name = "undo-" + name
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
+
+func Test_Engine_LateResult(t *testing.T) {
+	ctx := context.TODO()
+	emitter := &flakyEmitter{}
+	engine := saga.NewEngine(saga.NewInMemoryStore(), emitter)
+	engine.Register(order(50 * time.Millisecond))
+	state, err := engine.Start(ctx, "order", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := engine.Handle(ctx, saga.NewResult(emitter.commands[i], nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	charge := emitter.commands[2]
+
+	// charge times out before its result arrives
+	watchCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		engine.Watch(watchCtx, 10*time.Millisecond)
+		close(done)
+	}()
+	for state.Status != saga.StatusCompensating {
+		<-time.After(10 * time.Millisecond)
+		state, _ = engine.Get(ctx, state.ID)
+	}
+	cancel()
+	<-done
+	if steps(emitter.commands) != "reserve,notify,charge,undo-charge" {
+		t.Fatalf("expected timed out step compensated, got %v", steps(emitter.commands))
+	}
+
+	// charge succeeded late, e.g. after participant received its compensation
+	if err := engine.Handle(ctx, saga.NewResult(charge, nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if steps(emitter.commands) != "reserve,notify,charge,undo-charge,undo-charge" {
+		t.Fatalf("expected late success compensated, got %v", steps(emitter.commands))
+	}
+	for _, cmd := range emitter.commands[3:] {
+		if err := engine.Handle(ctx, saga.NewResult(cmd, nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state, _ = engine.Get(ctx, state.ID)
+	if state.Status != saga.StatusCompensating || state.Step != 0 {
+		t.Fatalf("expected saga compensating first step, got %+v", state)
+	}
+	if err := engine.Handle(ctx, saga.NewResult(emitter.commands[len(emitter.commands)-1], nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	state, _ = engine.Get(ctx, state.ID)
+	if state.Status != saga.StatusCompensated {
+		t.Fatalf("expected compensated saga, got %+v", state)
+	}
+
+	// late success of compensated saga is compensated too
+	n := len(emitter.commands)
+	if err := engine.Handle(ctx, saga.NewResult(charge, nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(emitter.commands) != n+1 || steps(emitter.commands[n:]) != "undo-charge" {
+		t.Fatalf("expected late success of compensated saga compensated, got %v", steps(emitter.commands[n:]))
+	}
+}
+
+func Test_Engine_StaleResult(t *testing.T) {
+	ctx := context.TODO()
+	emitter := &flakyEmitter{}
+	engine := saga.NewEngine(saga.NewInMemoryStore(), emitter)
+	engine.Register(order(0))
+	state, err := engine.Start(ctx, "order", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := engine.Handle(ctx, saga.NewResult(emitter.commands[i], nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// result of step preceding previous step is stale, command of current step is not emitted again
+	if err := engine.Handle(ctx, saga.NewResult(emitter.commands[0], nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if steps(emitter.commands) != "reserve,notify,charge" {
+		t.Fatalf("expected stale result discarded, got %v", steps(emitter.commands))
+	}
+
+	if err := engine.Handle(ctx, saga.NewResult(emitter.commands[2], nil, errors.New("card declined"))); err != nil {
+		t.Fatal(err)
+	}
+	// failure redelivered emits current compensation again, result of other step is stale
+	engine.Handle(ctx, saga.NewResult(emitter.commands[2], nil, errors.New("card declined")))
+	engine.Handle(ctx, saga.NewResult(emitter.commands[1], nil, nil))
+	if steps(emitter.commands) != "reserve,notify,charge,undo-reserve,undo-reserve" {
+		t.Fatalf("expected only redelivered failure handled, got %v", steps(emitter.commands))
+	}
+	state, _ = engine.Get(ctx, state.ID)
+	if state.Status != saga.StatusCompensating || state.Step != 0 {
+		t.Fatalf("expected saga compensating first step, got %+v", state)
+	}
+}
+
+func Test_Engine_ConcurrentResult(t *testing.T) {
+	ctx := context.TODO()
+	emitter := &flakyEmitter{}
+	store := &racingStore{Store: saga.NewInMemoryStore()}
+	engine := saga.NewEngine(store, emitter)
+	engine.Register(order(0))
+	state, err := engine.Start(ctx, "order", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Handle(ctx, saga.NewResult(emitter.commands[0], nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(emitter.commands) != 1 {
+		t.Fatalf("expected result handled concurrently discarded, got %v", steps(emitter.commands))
+	}
+	state, _ = engine.Get(ctx, state.ID)
+	if state.Step != 0 || state.Version != 2 {
+		t.Fatalf("expected state saved by other engine kept, got %+v", state)
+	}
+}
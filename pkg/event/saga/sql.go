@@ -0,0 +1,199 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/event/eventstore"
+	"github.com/pinkgorilla/go-sample/pkg/event/internal/eventutil"
+)
+
+// SQLStoreConfig is SQLStore configuration, dialect is one of eventstore dialects
+type SQLStoreConfig struct {
+	Dialect string `yaml:"dialect" json:"dialect"`
+	Table   string `yaml:"table" json:"table"`
+}
+
+// DefaultSQLStoreConfig returns default SQLStore config of dialect
+func DefaultSQLStoreConfig(dialect string) *SQLStoreConfig {
+	return &SQLStoreConfig{
+		Dialect: dialect,
+		Table:   "sagas",
+	}
+}
+
+// SQLStore is Store backed by SQL database, database driver must be registered by caller
+type SQLStore struct {
+	db     *sql.DB
+	config SQLStoreConfig
+}
+
+// NewSQLStore returns new SQLStore using db
+func NewSQLStore(db *sql.DB, config *SQLStoreConfig) (*SQLStore, error) {
+	if config == nil {
+		config = DefaultSQLStoreConfig(eventstore.DialectPostgres)
+	}
+	switch config.Dialect {
+	case eventstore.DialectPostgres, eventstore.DialectMySQL, eventstore.DialectSQLite:
+	default:
+		return nil, fmt.Errorf("saga: unsupported sql dialect '%s'", config.Dialect)
+	}
+	return &SQLStore{
+		db:     db,
+		config: *config,
+	}, nil
+}
+
+// CreateTables creates sagas table when it does not exist
+func (s *SQLStore) CreateTables(ctx context.Context) error {
+	text := "TEXT"
+	if s.config.Dialect == eventstore.DialectMySQL {
+		text = "LONGTEXT"
+	}
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id VARCHAR(255) NOT NULL PRIMARY KEY,
+		saga VARCHAR(255) NOT NULL,
+		status VARCHAR(32) NOT NULL,
+		step INTEGER NOT NULL,
+		data %s NOT NULL,
+		error %s,
+		deadline BIGINT NOT NULL,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		version INTEGER NOT NULL
+	)`, s.config.Table, text, text))
+	return err
+}
+
+// Save saves saga state as its next version replacing its previous state
+func (s *SQLStore) Save(ctx context.Context, state State) error {
+	data, err := json.Marshal(state.Data)
+	if err != nil {
+		return err
+	}
+	var deadline int64
+	if !state.Deadline.IsZero() {
+		deadline = state.Deadline.UnixNano()
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, s.query(fmt.Sprintf("UPDATE %s SET saga = ?, status = ?, step = ?, data = ?, error = ?, deadline = ?, created_at = ?, updated_at = ?, version = ? WHERE id = ? AND version = ?", s.config.Table)),
+		state.Saga, state.Status, state.Step, string(data), state.Error, deadline, state.CreatedAt.UnixNano(), state.UpdatedAt.UnixNano(), state.Version+1, state.ID, state.Version)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if state.Version != 0 {
+			return ErrConflict
+		}
+		var count int
+		err = tx.QueryRowContext(ctx, s.query(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE id = ?", s.config.Table)), state.ID).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrConflict
+		}
+		_, err = tx.ExecContext(ctx, s.query(fmt.Sprintf("INSERT INTO %s (%s) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", s.config.Table, columns)),
+			state.ID, state.Saga, state.Status, state.Step, string(data), state.Error, deadline, state.CreatedAt.UnixNano(), state.UpdatedAt.UnixNano(), 1)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Load returns saga state
+func (s *SQLStore) Load(ctx context.Context, id string) (State, error) {
+	rows, err := s.db.QueryContext(ctx, s.query(fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", columns, s.config.Table)), id)
+	if err != nil {
+		return State{}, err
+	}
+	states, err := scanStates(rows)
+	if err != nil {
+		return State{}, err
+	}
+	if len(states) == 0 {
+		return State{}, ErrNotFound
+	}
+	return states[0], nil
+}
+
+// Find returns sagas matching query ordered by creation time
+func (s *SQLStore) Find(ctx context.Context, query Query) ([]State, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if query.Saga != "" {
+		conditions = append(conditions, "saga = ?")
+		args = append(args, query.Saga)
+	}
+	if len(query.Statuses) > 0 {
+		conditions = append(conditions, "status IN (?"+strings.Repeat(", ?", len(query.Statuses)-1)+")")
+		for _, status := range query.Statuses {
+			args = append(args, status)
+		}
+	}
+	if !query.UpdatedBefore.IsZero() {
+		conditions = append(conditions, "updated_at < ?")
+		args = append(args, query.UpdatedBefore.UnixNano())
+	}
+	q := fmt.Sprintf("SELECT %s FROM %s", columns, s.config.Table)
+	if len(conditions) > 0 {
+		q += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := s.db.QueryContext(ctx, s.query(q+" ORDER BY created_at"), args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanStates(rows)
+}
+
+const columns = "id, saga, status, step, data, error, deadline, created_at, updated_at, version"
+
+func scanStates(rows *sql.Rows) ([]State, error) {
+	defer rows.Close()
+	result := []State{}
+	for rows.Next() {
+		var state State
+		var data string
+		var errorMessage sql.NullString
+		var deadline, createdAt, updatedAt int64
+		err := rows.Scan(&state.ID, &state.Saga, &state.Status, &state.Step, &data, &errorMessage, &deadline, &createdAt, &updatedAt, &state.Version)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &state.Data); err != nil {
+			return nil, err
+		}
+		if state.Data == nil {
+			state.Data = map[string]interface{}{}
+		}
+		state.Error = errorMessage.String
+		if deadline != 0 {
+			state.Deadline = time.Unix(0, deadline).UTC()
+		}
+		state.CreatedAt = time.Unix(0, createdAt).UTC()
+		state.UpdatedAt = time.Unix(0, updatedAt).UTC()
+		result = append(result, state)
+	}
+	return result, rows.Err()
+}
+
+// query rewrites ? placeholders into dialect placeholders
+func (s *SQLStore) query(q string) string {
+	if s.config.Dialect != eventstore.DialectPostgres {
+		return q
+	}
+	return eventutil.NumberedPlaceholders(q)
+}
@@ -0,0 +1,79 @@
+package saga_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pinkgorilla/go-sample/pkg/event/eventstore"
+	"github.com/pinkgorilla/go-sample/pkg/event/saga"
+)
+
+func newSQLiteStore(t *testing.T, path string) *saga.SQLStore {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	store, err := saga.NewSQLStore(db, saga.DefaultSQLStoreConfig(eventstore.DialectSQLite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateTables(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func Test_SQLStore(t *testing.T) {
+	testStuck(t, newSQLiteStore(t, filepath.Join(t.TempDir(), "sagas.db")))
+}
+
+func Test_SQLStore_Conflict(t *testing.T) {
+	testConflict(t, newSQLiteStore(t, filepath.Join(t.TempDir(), "sagas.db")))
+}
+
+func Test_SQLStore_Persisted(t *testing.T) {
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "sagas.db")
+	now := time.Now().UTC()
+	state := saga.State{
+		ID:        "1",
+		Saga:      "order",
+		Status:    saga.StatusCompensating,
+		Step:      2,
+		Data:      map[string]interface{}{"order": "o-1"},
+		Error:     "card declined",
+		Deadline:  now.Add(time.Minute),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := newSQLiteStore(t, path).Save(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+	state.Step = 1
+	state.Version = 1
+	if err := newSQLiteStore(t, path).Save(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+
+	// store opened again, e.g. after restart
+	store := newSQLiteStore(t, path)
+	loaded, err := store.Load(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Step != 1 || loaded.Version != 2 || loaded.Status != saga.StatusCompensating || loaded.Data["order"] != "o-1" ||
+		loaded.Error != "card declined" || !loaded.Deadline.Equal(state.Deadline) || !loaded.CreatedAt.Equal(now) {
+		t.Fatalf("unexpected state %+v", loaded)
+	}
+	if _, err := store.Load(ctx, "2"); err != saga.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := saga.NewSQLStore(nil, saga.DefaultSQLStoreConfig("oracle")); err == nil {
+		t.Fatal("expected unsupported dialect error")
+	}
+}
@@ -0,0 +1,143 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by Store when saga does not exist
+var ErrNotFound = errors.New("saga: not found")
+
+// ErrConflict is returned by Store when saved state is not the latest version of saga
+var ErrConflict = errors.New("saga: state was saved concurrently")
+
+// Saga status
+const (
+	StatusRunning      = "running"
+	StatusCompensating = "compensating"
+	StatusCompleted    = "completed"
+	StatusCompensated  = "compensated"
+	// StatusFailed is status of saga whose compensation failed, it requires manual intervention
+	StatusFailed = "failed"
+)
+
+// State is persisted state of a saga instance
+type State struct {
+	ID     string `json:"id"`
+	Saga   string `json:"saga"`
+	Status string `json:"status"`
+	// Step is index of current step, it is being executed or compensated depending on Status
+	Step int `json:"step"`
+	// Data is saga data, it is passed to steps and merged with step results
+	Data  map[string]interface{} `json:"data"`
+	Error string                 `json:"error,omitempty"`
+	// Deadline is time current step times out, zero when step has no timeout
+	Deadline  time.Time `json:"deadline,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Version is incremented by every save, it is 0 for saga never saved
+	Version int `json:"version"`
+}
+
+// Done returns true when saga reached final status
+func (s State) Done() bool {
+	return s.Status == StatusCompleted || s.Status == StatusCompensated || s.Status == StatusFailed
+}
+
+// Query filters sagas, empty fields match any saga
+type Query struct {
+	Saga     string
+	Statuses []string
+	// UpdatedBefore matches sagas not updated since
+	UpdatedBefore time.Time
+}
+
+func (q Query) matches(s State) bool {
+	if q.Saga != "" && q.Saga != s.Saga {
+		return false
+	}
+	if !q.UpdatedBefore.IsZero() && !s.UpdatedAt.Before(q.UpdatedBefore) {
+		return false
+	}
+	if len(q.Statuses) == 0 {
+		return true
+	}
+	for _, status := range q.Statuses {
+		if status == s.Status {
+			return true
+		}
+	}
+	return false
+}
+
+// Store persists saga state
+type Store interface {
+	// Save saves state as its next version, ErrConflict is returned when stored version differs from state version
+	Save(ctx context.Context, state State) error
+	Load(ctx context.Context, id string) (State, error)
+	Find(ctx context.Context, query Query) ([]State, error)
+}
+
+// InMemoryStore is Store keeping saga state in memory
+type InMemoryStore struct {
+	states map[string]State
+	mu     sync.RWMutex
+}
+
+// NewInMemoryStore returns new InMemoryStore
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		states: map[string]State{},
+	}
+}
+
+// Save saves saga state as its next version
+func (s *InMemoryStore) Save(ctx context.Context, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states[state.ID].Version != state.Version {
+		return ErrConflict
+	}
+	state.Version++
+	s.states[state.ID] = copyState(state)
+	return nil
+}
+
+// Load returns saga state
+func (s *InMemoryStore) Load(ctx context.Context, id string) (State, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	state, ok := s.states[id]
+	if !ok {
+		return State{}, ErrNotFound
+	}
+	return copyState(state), nil
+}
+
+// Find returns sagas matching query ordered by creation time
+func (s *InMemoryStore) Find(ctx context.Context, query Query) ([]State, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := []State{}
+	for _, state := range s.states {
+		if query.matches(state) {
+			result = append(result, copyState(state))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func copyState(s State) State {
+	data := make(map[string]interface{}, len(s.Data))
+	for k, v := range s.Data {
+		data[k] = v
+	}
+	s.Data = data
+	return s
+}
@@ -0,0 +1,235 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+)
+
+// Schema is JSON Schema used to validate event data.
+//
+// Only a subset of JSON Schema keywords is supported: type, properties, required,
+// additionalProperties, items, enum, minimum, maximum, minLength, maxLength and pattern
+type Schema struct {
+	Type                 SchemaTypes        `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// SchemaTypes is value of schema type keyword, which is either a type name or list of type names
+type SchemaTypes []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *SchemaTypes) UnmarshalJSON(bs []byte) error {
+	var name string
+	if err := json.Unmarshal(bs, &name); err == nil {
+		*t = SchemaTypes{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(bs, &names); err != nil {
+		return fmt.Errorf("schema type must be a string or array of string: %v", err)
+	}
+	*t = names
+	return nil
+}
+
+var schemaTypeNames = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// ParseSchema parses JSON Schema document
+func ParseSchema(bs []byte) (*Schema, error) {
+	var s Schema
+	err := json.Unmarshal(bs, &s)
+	if err != nil {
+		return nil, err
+	}
+	err = s.compile("")
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Schema) compile(path string) error {
+	for _, t := range s.Type {
+		if !schemaTypeNames[t] {
+			return fmt.Errorf("schema %s: unknown type '%s'", pathName(path), t)
+		}
+	}
+	if s.Pattern != "" {
+		p, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("schema %s: %v", pathName(path), err)
+		}
+		s.pattern = p
+	}
+	for name, p := range s.Properties {
+		if err := p.compile(fieldPath(path, name)); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile(path + "[]")
+	}
+	return nil
+}
+
+// Validate validates data against schema, data is compared by its json representation.
+// Returned error is errors.ValidationError with failing field paths, e.g. items.0.name
+func (s *Schema) Validate(data interface{}) error {
+	v, err := normalize(data)
+	if err != nil {
+		e := errors.NewValidationError("invalid event data")
+		e.FieldError("$", err.Error())
+		return e
+	}
+	e := errors.NewValidationError("invalid event data")
+	s.validate("", v, &e)
+	if e.HasFieldErrors() {
+		return e
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, v interface{}, e *errors.ValidationError) {
+	if len(s.Type) > 0 && !s.Type.matches(v) {
+		e.FieldError(pathName(path), fmt.Sprintf("expected type %s", strings.Join(s.Type, " or ")))
+		return
+	}
+	if len(s.Enum) > 0 && !containsValue(s.Enum, v) {
+		e.FieldError(pathName(path), "value is not one of allowed values")
+		return
+	}
+	switch val := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(val)
+		if s.MinLength != nil && n < *s.MinLength {
+			e.FieldError(pathName(path), fmt.Sprintf("length must be at least %d", *s.MinLength))
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			e.FieldError(pathName(path), fmt.Sprintf("length must be at most %d", *s.MaxLength))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(val) {
+			e.FieldError(pathName(path), fmt.Sprintf("value must match pattern %s", s.Pattern))
+		}
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			e.FieldError(pathName(path), fmt.Sprintf("value must be at least %v", *s.Minimum))
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			e.FieldError(pathName(path), fmt.Sprintf("value must be at most %v", *s.Maximum))
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				e.FieldRequired(fieldPath(path, name))
+			}
+		}
+		for name, fv := range val {
+			p, ok := s.Properties[name]
+			if ok {
+				p.validate(fieldPath(path, name), fv, e)
+				continue
+			}
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				e.FieldError(fieldPath(path, name), "field is not allowed")
+			}
+		}
+	case []interface{}:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range val {
+			s.Items.validate(fieldPath(path, fmt.Sprint(i)), item, e)
+		}
+	}
+}
+
+func (t SchemaTypes) matches(v interface{}) bool {
+	for _, name := range t {
+		if typeMatches(name, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func typeMatches(name string, v interface{}) bool {
+	switch name {
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return false
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, val := range values {
+		if reflect.DeepEqual(val, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize converts data to its generic json representation
+func normalize(data interface{}) (interface{}, error) {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = json.Unmarshal(bs, &v)
+	return v, err
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// pathName returns path used in error messages, $ denotes the root value
+func pathName(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return path
+}
@@ -0,0 +1,141 @@
+package feature
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+	"github.com/pinkgorilla/go-sample/pkg/http/server/middlewares"
+)
+
+// FeatureStatus is feature status reported by AdminHandler, Evaluation is made for the requesting identity
+type FeatureStatus struct {
+	Key        string      `json:"key"`
+	Type       ChangeType  `json:"type"`
+	Flag       *Flag       `json:"flag,omitempty"`
+	Evaluation *Evaluation `json:"evaluation,omitempty"`
+	History    []Change    `json:"history,omitempty"`
+}
+
+// adminHistory is number of changes reported by AdminHandler for a feature
+const adminHistory = 20
+
+// AdminHandler returns http handler to inspect and change features of m, requests are authorized by authorize
+// and changes are made with the authorized identity as actor. History of a feature is reported from audit
+// when not nil, audit is expected to be subscribed to changes of m
+//
+// GET lists features, GET with key query parameter reports the feature with its history,
+// PUT with key query parameter sets flag from json body, POST with key and action query parameters,
+// where action is enable or disable, toggles the flag and DELETE with key query parameter deletes the feature
+func AdminHandler(m *Manager, audit AuditLog, authorize middlewares.AuthorizeFn) http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.URL.Query().Get("key")
+		if key == "" && req.Method != http.MethodGet {
+			writeError(w, http.StatusBadRequest, errors.NewValidationError("key is required"))
+			return
+		}
+		ctx := req.Context()
+		switch req.Method {
+		case http.MethodGet:
+			if key == "" {
+				statuses := []FeatureStatus{}
+				for _, key := range m.keys() {
+					if s, ok := m.status(req, key); ok {
+						statuses = append(statuses, s)
+					}
+				}
+				writeJSON(w, http.StatusOK, statuses)
+				return
+			}
+			s, ok := m.status(req, key)
+			if !ok {
+				writeError(w, http.StatusNotFound, errors.NewNotFoundError(fmt.Sprintf("feature '%s' not found", key)))
+				return
+			}
+			if audit != nil {
+				s.History = audit.Entries(key, adminHistory)
+			}
+			writeJSON(w, http.StatusOK, s)
+		case http.MethodPut:
+			var f Flag
+			if err := json.NewDecoder(req.Body).Decode(&f); err != nil {
+				writeError(w, http.StatusBadRequest, errors.NewValidationError(err.Error()))
+				return
+			}
+			f.Key = key
+			if err := m.SetFlagContext(ctx, f); err != nil {
+				writeError(w, http.StatusUnprocessableEntity, err)
+				return
+			}
+			s, _ := m.status(req, key)
+			writeJSON(w, http.StatusOK, s)
+		case http.MethodPost:
+			f, ok := m.GetFlag(key)
+			if !ok {
+				writeError(w, http.StatusNotFound, errors.NewNotFoundError(fmt.Sprintf("flag '%s' not found", key)))
+				return
+			}
+			switch req.URL.Query().Get("action") {
+			case "enable":
+				f.Enabled = true
+			case "disable":
+				f.Enabled = false
+			default:
+				writeError(w, http.StatusBadRequest, errors.NewValidationError("action must be enable or disable"))
+				return
+			}
+			if err := m.SetFlagContext(ctx, f); err != nil {
+				writeError(w, http.StatusUnprocessableEntity, err)
+				return
+			}
+			s, _ := m.status(req, key)
+			writeJSON(w, http.StatusOK, s)
+		case http.MethodDelete:
+			if _, ok := m.features.Load(key); !ok {
+				writeError(w, http.StatusNotFound, errors.NewNotFoundError(fmt.Sprintf("feature '%s' not found", key)))
+				return
+			}
+			m.DeleteContext(ctx, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	return middlewares.AuthMiddleware(authorize)(h)
+}
+
+// keys returns feature keys in ascending order
+func (m Manager) keys() []string {
+	keys := []string{}
+	m.features.Range(func(k, v interface{}) bool {
+		keys = append(keys, k.(string))
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+func (m Manager) status(req *http.Request, key string) (FeatureStatus, bool) {
+	e, err := m.Evaluate(req.Context(), key)
+	if err != nil {
+		return FeatureStatus{}, false
+	}
+	s := FeatureStatus{Key: key, Type: ChangeState, Evaluation: &e}
+	if f, ok := m.GetFlag(key); ok {
+		s.Type = ChangeFlag
+		s.Flag = &f
+	}
+	return s, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, err)
+}
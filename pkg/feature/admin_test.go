@@ -0,0 +1,118 @@
+package feature_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pinkgorilla/go-sample/pkg/feature"
+	"github.com/pinkgorilla/go-sample/pkg/http/server/middlewares"
+)
+
+func Test_AdminHandler(t *testing.T) {
+	m := feature.GetManager()
+	audit := feature.NewMemoryAuditLog(100)
+	defer m.Subscribe(audit.Record)()
+	notified := []feature.Change{}
+	defer m.Subscribe(func(c feature.Change) {
+		if c.Key == "admin-flag" {
+			notified = append(notified, c)
+		}
+	})()
+
+	srv := httptest.NewServer(feature.AdminHandler(m, audit, middlewares.StaticKeyAuthorizeFn("secret")))
+	defer srv.Close()
+	do := func(method, query, key string, body interface{}, v interface{}) int {
+		bs, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, srv.URL+"?"+query, bytes.NewReader(bs))
+		req.Header.Set("Authorization", "Bearer "+key)
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if v != nil {
+			json.NewDecoder(res.Body).Decode(v)
+		}
+		return res.StatusCode
+	}
+
+	if code := do(http.MethodGet, "", "invalid", nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized, got %v", code)
+	}
+
+	var status feature.FeatureStatus
+	code := do(http.MethodPut, "key=admin-flag", "secret", feature.Flag{Enabled: true, Default: feature.VariantOn}, &status)
+	if code != http.StatusOK || status.Flag == nil || status.Evaluation.Value != true {
+		t.Fatalf("expected flag set, got %v %+v", code, status)
+	}
+	if code := do(http.MethodPut, "key=admin-flag", "secret", feature.Flag{Default: "unknown"}, nil); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected invalid flag rejected, got %v", code)
+	}
+
+	code = do(http.MethodPost, "key=admin-flag&action=disable", "secret", nil, &status)
+	if code != http.StatusOK || status.Evaluation.Value != false || status.Evaluation.Reason != feature.ReasonDisabled {
+		t.Fatalf("expected flag disabled, got %v %+v", code, status)
+	}
+
+	m.SetState("admin-state", 42)
+	var statuses []feature.FeatureStatus
+	do(http.MethodGet, "", "secret", nil, &statuses)
+	found := map[string]feature.ChangeType{}
+	for _, s := range statuses {
+		found[s.Key] = s.Type
+	}
+	if found["admin-flag"] != feature.ChangeFlag || found["admin-state"] != feature.ChangeState {
+		t.Fatalf("expected flag and state listed, got %v", found)
+	}
+
+	do(http.MethodGet, "key=admin-flag", "secret", nil, &status)
+	if len(status.History) != 2 {
+		t.Fatalf("expected 2 changes in history, got %+v", status.History)
+	}
+	latest := status.History[0]
+	if latest.Actor == nil || latest.Actor.Name != "static-key" {
+		t.Fatalf("expected change made by authorized identity, got %+v", latest.Actor)
+	}
+	if latest.Old.(map[string]interface{})["enabled"] != true || latest.New.(map[string]interface{})["enabled"] != false {
+		t.Fatalf("expected old and new value recorded, got %+v", latest)
+	}
+
+	if code := do(http.MethodDelete, "key=admin-flag", "secret", nil, nil); code != http.StatusNoContent {
+		t.Fatalf("expected flag deleted, got %v", code)
+	}
+	if code := do(http.MethodGet, "key=admin-flag", "secret", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("expected deleted flag not found, got %v", code)
+	}
+	if len(notified) != 3 || notified[2].New != nil || notified[2].Type != feature.ChangeFlag {
+		t.Fatalf("expected subscribers notified of 3 changes, got %+v", notified)
+	}
+}
+
+func Test_Subscribe(t *testing.T) {
+	m := feature.GetManager()
+	changes := []feature.Change{}
+	unsubscribe := m.Subscribe(func(c feature.Change) {
+		if c.Key == "subscribed" {
+			changes = append(changes, c)
+		}
+	})
+	m.SetState("subscribed", func() interface{} { return 1 })
+	m.SetState("subscribed", 2)
+	m.Delete("subscribed")
+	m.Delete("subscribed")
+	unsubscribe()
+	m.SetState("subscribed", 3)
+
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+	if changes[0].Old != nil || changes[0].New != 1 || changes[1].Old != 1 || changes[2].New != nil || changes[2].Type != feature.ChangeState {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+	if changes[0].Actor != nil {
+		t.Fatal("expected no actor of change made by code")
+	}
+}
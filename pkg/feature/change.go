@@ -0,0 +1,197 @@
+package feature
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/auth"
+)
+
+// ChangeType is type of changed feature
+type ChangeType string
+
+// Change types
+const (
+	ChangeFlag    ChangeType = "flag"
+	ChangeState   ChangeType = "state"
+	ChangeSegment ChangeType = "segment"
+)
+
+// Change is change of a feature or segment, Old is nil when created and New is nil when deleted.
+// Flags and segments are Flag and Segment values, func() interface{} states are evaluated
+type Change struct {
+	Type ChangeType  `json:"type"`
+	Key  string      `json:"key"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+	// Actor is identity from auth.FromContext of the change, nil when changed by code or providers
+	Actor *auth.Identity `json:"actor,omitempty"`
+	At    time.Time      `json:"at"`
+}
+
+// changes serializes changes and keeps subscribers notified of them
+type changes struct {
+	subscribers map[int]func(Change)
+	next        int
+	mu          sync.Mutex
+}
+
+// Subscribe registers fn called synchronously after every change, e.g. to invalidate caches,
+// and returns function unregistering it
+func (m Manager) Subscribe(fn func(Change)) (unsubscribe func()) {
+	m.changes.mu.Lock()
+	defer m.changes.mu.Unlock()
+	id := m.changes.next
+	m.changes.next++
+	m.changes.subscribers[id] = fn
+	return func() {
+		m.changes.mu.Lock()
+		defer m.changes.mu.Unlock()
+		delete(m.changes.subscribers, id)
+	}
+}
+
+// SetStateContext is SetState recording identity from auth.FromContext as actor of the change
+func (m Manager) SetStateContext(ctx context.Context, key string, state interface{}) {
+	m.store(ctx, m.features, ChangeState, key, state)
+}
+
+// SetFlagContext is SetFlag recording identity from auth.FromContext as actor of the change
+func (m Manager) SetFlagContext(ctx context.Context, f Flag) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	m.store(ctx, m.features, ChangeFlag, f.Key, &f)
+	return nil
+}
+
+// DeleteContext is Delete recording identity from auth.FromContext as actor of the change
+func (m Manager) DeleteContext(ctx context.Context, key string) {
+	m.store(ctx, m.features, "", key, nil)
+}
+
+// SetSegmentContext is SetSegment recording identity from auth.FromContext as actor of the change
+func (m Manager) SetSegmentContext(ctx context.Context, s Segment) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	m.store(ctx, m.segments, ChangeSegment, s.Key, &s)
+	return nil
+}
+
+// DeleteSegmentContext is DeleteSegment recording identity from auth.FromContext as actor of the change
+func (m Manager) DeleteSegmentContext(ctx context.Context, key string) {
+	m.store(ctx, m.segments, ChangeSegment, key, nil)
+}
+
+// store stores v, or deletes key when v is nil, and notifies subscribers,
+// type of deletion is decided by deleted value when t is empty
+func (m Manager) store(ctx context.Context, features *sync.Map, t ChangeType, key string, v interface{}) {
+	m.changes.mu.Lock()
+	old, existed := features.Load(key)
+	if v == nil {
+		if !existed {
+			m.changes.mu.Unlock()
+			return
+		}
+		features.Delete(key)
+	} else {
+		features.Store(key, v)
+	}
+	if features == m.features {
+		m.observeStored(key, !existed, v == nil)
+	}
+	subscribers := make([]func(Change), 0, len(m.changes.subscribers))
+	for i := 0; i < m.changes.next; i++ {
+		if fn, ok := m.changes.subscribers[i]; ok {
+			subscribers = append(subscribers, fn)
+		}
+	}
+	m.changes.mu.Unlock()
+	if len(subscribers) == 0 {
+		return
+	}
+
+	if t == "" {
+		t = changeType(old)
+	}
+	c := Change{
+		Type:  t,
+		Key:   key,
+		Old:   snapshot(old),
+		New:   snapshot(v),
+		Actor: auth.FromContext(ctx),
+		At:    time.Now(),
+	}
+	for _, fn := range subscribers {
+		fn(c)
+	}
+}
+
+func changeType(v interface{}) ChangeType {
+	switch v.(type) {
+	case *Flag:
+		return ChangeFlag
+	case *Segment:
+		return ChangeSegment
+	}
+	return ChangeState
+}
+
+// snapshot returns value of stored feature or segment
+func snapshot(v interface{}) interface{} {
+	switch t := v.(type) {
+	case *Flag:
+		return *t
+	case *Segment:
+		return *t
+	case func() interface{}:
+		return t()
+	}
+	return v
+}
+
+// AuditLog records feature changes, Record subscribes it to Manager changes,
+// e.g. m.Subscribe(audit.Record)
+type AuditLog interface {
+	// Record records change
+	Record(c Change)
+	// Entries returns at most limit latest changes of key, latest first, all keys when key is empty
+	Entries(key string, limit int) []Change
+}
+
+// MemoryAuditLog is AuditLog keeping latest changes in memory
+type MemoryAuditLog struct {
+	size    int
+	entries []Change
+	mu      sync.Mutex
+}
+
+// NewMemoryAuditLog returns new MemoryAuditLog keeping size latest changes
+func NewMemoryAuditLog(size int) *MemoryAuditLog {
+	return &MemoryAuditLog{size: size}
+}
+
+// Record implements AuditLog
+func (l *MemoryAuditLog) Record(c Change) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, c)
+	if len(l.entries) > l.size {
+		l.entries = append([]Change{}, l.entries[len(l.entries)-l.size:]...)
+	}
+}
+
+// Entries implements AuditLog
+func (l *MemoryAuditLog) Entries(key string, limit int) []Change {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := []Change{}
+	for i := len(l.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		if key == "" || l.entries[i].Key == key {
+			entries = append(entries, l.entries[i])
+		}
+	}
+	return entries
+}
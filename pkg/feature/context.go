@@ -0,0 +1,44 @@
+package feature
+
+import "context"
+
+type managerKey struct{}
+
+type overridesKey struct{}
+
+// ToContext puts manager to context
+func ToContext(ctx context.Context, m *Manager) context.Context {
+	return context.WithValue(ctx, managerKey{}, m)
+}
+
+// FromContext returns manager from context, GetManager() when context has no manager
+func FromContext(ctx context.Context) *Manager {
+	m, ok := ctx.Value(managerKey{}).(*Manager)
+	if !ok {
+		return GetManager()
+	}
+	return m
+}
+
+// WithOverride returns context forcing value of feature with corresponding key,
+// evaluations with the context return value regardless of manager features
+func WithOverride(ctx context.Context, key string, value interface{}) context.Context {
+	return WithOverrides(ctx, map[string]interface{}{key: value})
+}
+
+// WithOverrides returns context forcing values of features, merged to overrides of ctx
+func WithOverrides(ctx context.Context, values map[string]interface{}) context.Context {
+	merged := map[string]interface{}{}
+	for k, v := range overrides(ctx) {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, overridesKey{}, merged)
+}
+
+func overrides(ctx context.Context) map[string]interface{} {
+	values, _ := ctx.Value(overridesKey{}).(map[string]interface{})
+	return values
+}
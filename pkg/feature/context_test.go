@@ -0,0 +1,57 @@
+package feature_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pinkgorilla/go-sample/pkg/feature"
+)
+
+func Test_NewManager_Isolated(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		i := i
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+			m := feature.NewManager()
+			ctx := context.Background()
+			if err := m.SetFlag(feature.Flag{Key: "isolated", Enabled: i%2 == 0, Default: feature.VariantOn}); err != nil {
+				t.Fatal(err)
+			}
+			if m.Bool(ctx, "isolated", false) != (i%2 == 0) {
+				t.Fatal("expected manager isolated from other managers")
+			}
+		})
+	}
+	if _, ok := feature.GetManager().GetFlag("isolated"); ok {
+		t.Fatal("expected global manager unaffected")
+	}
+}
+
+func Test_Override(t *testing.T) {
+	m := feature.NewManager()
+	m.SetFlag(feature.Flag{Key: "overridden", Enabled: true, Default: feature.VariantOn})
+
+	ctx := feature.WithOverride(context.Background(), "overridden", false)
+	ctx = feature.WithOverrides(ctx, map[string]interface{}{"unknown": "forced"})
+	if m.Bool(ctx, "overridden", true) {
+		t.Fatal("expected overridden value")
+	}
+	e, err := m.Evaluate(ctx, "unknown")
+	if err != nil || e.Value != "forced" || e.Reason != feature.ReasonOverride {
+		t.Fatalf("expected override of unknown feature, got %+v %v", e, err)
+	}
+	if !m.Bool(context.Background(), "overridden", false) {
+		t.Fatal("expected override limited to context")
+	}
+}
+
+func Test_FromContext(t *testing.T) {
+	if feature.FromContext(context.Background()) != feature.GetManager() {
+		t.Fatal("expected global manager by default")
+	}
+	m := feature.NewManager()
+	if feature.FromContext(feature.ToContext(context.Background(), m)) != m {
+		t.Fatal("expected manager from context")
+	}
+}
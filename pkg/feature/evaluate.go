@@ -0,0 +1,289 @@
+package feature
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/pinkgorilla/go-sample/pkg/auth"
+)
+
+// ErrNotFound is returned by Evaluate when feature is neither flag nor state
+var ErrNotFound = errors.New("feature not found")
+
+// Reason is reason of evaluated variant
+type Reason string
+
+// Evaluation reasons
+const (
+	// ReasonStatic is reason of feature set by SetState
+	ReasonStatic Reason = "static"
+	// ReasonDisabled is reason of disabled flag serving its off variant
+	ReasonDisabled Reason = "disabled"
+	// ReasonDenied is reason of identity in deny list
+	ReasonDenied Reason = "deny_list"
+	// ReasonAllowed is reason of identity in allow list
+	ReasonAllowed Reason = "allow_list"
+	// ReasonRuleMatch is reason of variant served by matching rule
+	ReasonRuleMatch Reason = "rule_match"
+	// ReasonDefault is reason of default variant served when no rule matches
+	ReasonDefault Reason = "default"
+	// ReasonNotFound is reason of default value returned by typed accessors for unknown feature
+	ReasonNotFound Reason = "not_found"
+	// ReasonOverride is reason of value forced by WithOverride
+	ReasonOverride Reason = "override"
+)
+
+// Evaluation is result of evaluating feature against evaluation context,
+// Variant is empty for feature set by SetState
+type Evaluation struct {
+	Key     string      `json:"key"`
+	Variant string      `json:"variant,omitempty"`
+	Value   interface{} `json:"value"`
+	Reason  Reason      `json:"reason"`
+	// Rule is name of matching rule, or its index when the rule has no name
+	Rule string `json:"rule,omitempty"`
+}
+
+type attributesKey struct{}
+
+// WithAttributes returns context with evaluation attributes merged to attributes of ctx
+func WithAttributes(ctx context.Context, attributes map[string]interface{}) context.Context {
+	merged := map[string]interface{}{}
+	for k, v := range Attributes(ctx) {
+		merged[k] = v
+	}
+	for k, v := range attributes {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, attributesKey{}, merged)
+}
+
+// Attributes returns evaluation attributes of ctx
+func Attributes(ctx context.Context) map[string]interface{} {
+	attributes, _ := ctx.Value(attributesKey{}).(map[string]interface{})
+	return attributes
+}
+
+// evaluationContext is identity from auth.FromContext and attributes of context flags are evaluated against
+type evaluationContext struct {
+	identity   *auth.Identity
+	attributes map[string]interface{}
+}
+
+func newEvaluationContext(ctx context.Context) evaluationContext {
+	return evaluationContext{
+		identity:   auth.FromContext(ctx),
+		attributes: Attributes(ctx),
+	}
+}
+
+// attribute returns attribute named name, identity is available as id, type and name attributes
+// unless overridden by context attributes
+func (ec evaluationContext) attribute(name string) (interface{}, bool) {
+	if v, ok := ec.attributes[name]; ok {
+		return v, true
+	}
+	if ec.identity == nil {
+		return nil, false
+	}
+	switch name {
+	case "id":
+		return ec.identity.ID, ec.identity.ID != nil
+	case "type":
+		return ec.identity.Type, true
+	case "name":
+		return ec.identity.Name, true
+	}
+	return nil, false
+}
+
+// id returns identity id as string, empty when there is no identity
+func (ec evaluationContext) id() string {
+	v, ok := ec.attribute("id")
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func (ec evaluationContext) matchAll(conditions []Condition) bool {
+	for _, c := range conditions {
+		v, ok := ec.attribute(c.Attribute)
+		if !c.match(v, ok) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetFlag sets feature flag, replacing feature with the same key
+func (m Manager) SetFlag(f Flag) error {
+	return m.SetFlagContext(context.Background(), f)
+}
+
+// GetFlag returns feature flag with corresponding key
+func (m Manager) GetFlag(key string) (Flag, bool) {
+	i, ok := m.features.Load(key)
+	if !ok {
+		return Flag{}, false
+	}
+	f, ok := i.(*Flag)
+	if !ok {
+		return Flag{}, false
+	}
+	return *f, true
+}
+
+// SetSegment sets segment referenced by flag rules
+func (m Manager) SetSegment(s Segment) error {
+	return m.SetSegmentContext(context.Background(), s)
+}
+
+// GetSegment returns segment with corresponding key
+func (m Manager) GetSegment(key string) (Segment, bool) {
+	i, ok := m.segments.Load(key)
+	if !ok {
+		return Segment{}, false
+	}
+	return *i.(*Segment), true
+}
+
+// DeleteSegment deletes segment with corresponding key
+func (m Manager) DeleteSegment(key string) {
+	m.DeleteSegmentContext(context.Background(), key)
+}
+
+// Evaluate evaluates feature with corresponding key against identity from auth.FromContext
+// and attributes from WithAttributes. Feature set by SetState evaluates to its state
+// and value forced by WithOverride takes precedence, even for unknown feature
+func (m Manager) Evaluate(ctx context.Context, key string) (Evaluation, error) {
+	if v, ok := overrides(ctx)[key]; ok {
+		m.observeEvaluation(key, "", ReasonOverride)
+		return Evaluation{Key: key, Value: v, Reason: ReasonOverride}, nil
+	}
+	i, ok := m.features.Load(key)
+	if !ok {
+		return Evaluation{}, fmt.Errorf("%w: '%s'", ErrNotFound, key)
+	}
+	f, ok := i.(*Flag)
+	if !ok {
+		if fn, ok := i.(func() interface{}); ok {
+			i = fn()
+		}
+		m.observeEvaluation(key, "", ReasonStatic)
+		return Evaluation{Key: key, Value: i, Reason: ReasonStatic}, nil
+	}
+	variant, reason, rule := m.evaluate(f, newEvaluationContext(ctx))
+	m.observeEvaluation(key, variant, reason)
+	return Evaluation{
+		Key:     key,
+		Variant: variant,
+		Value:   f.variants()[variant],
+		Reason:  reason,
+		Rule:    rule,
+	}, nil
+}
+
+func (m Manager) evaluate(f *Flag, ec evaluationContext) (variant string, reason Reason, rule string) {
+	if !f.Enabled {
+		return f.off(), ReasonDisabled, ""
+	}
+	id := ec.id()
+	if id != "" && contains(f.Deny, id) {
+		return f.off(), ReasonDenied, ""
+	}
+	if id != "" && contains(f.Allow, id) {
+		return f.on(), ReasonAllowed, ""
+	}
+	for i, r := range f.Rules {
+		if !m.matchRule(f, r, ec) {
+			continue
+		}
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprint(i)
+		}
+		if len(r.Distribution) > 0 {
+			return distribute(f, r, ec), ReasonRuleMatch, name
+		}
+		return r.Variant, ReasonRuleMatch, name
+	}
+	return f.defaultVariant(), ReasonDefault, ""
+}
+
+func (m Manager) matchRule(f *Flag, r Rule, ec evaluationContext) bool {
+	for _, key := range r.Segments {
+		s, ok := m.GetSegment(key)
+		if !ok || !s.contains(ec) {
+			return false
+		}
+	}
+	if !ec.matchAll(r.Conditions) {
+		return false
+	}
+	if r.Percentage == nil {
+		return true
+	}
+	v, ok := ec.attribute(r.bucketBy())
+	if !ok {
+		return false
+	}
+	return bucket(f.Key, fmt.Sprint(v)) < *r.Percentage
+}
+
+func (r Rule) bucketBy() string {
+	if r.BucketBy == "" {
+		return "id"
+	}
+	return r.BucketBy
+}
+
+// distribute returns variant of rule distribution assigned to evaluation context,
+// contexts without BucketBy attribute get the first variant with positive weight
+func distribute(f *Flag, r Rule, ec evaluationContext) string {
+	total := 0.0
+	for _, w := range r.Distribution {
+		total += w.Weight
+	}
+	b := 0.0
+	if v, ok := ec.attribute(r.bucketBy()); ok {
+		// bucketed separately from percentage so contexts in a partial rollout are spread over all variants
+		b = bucket(f.Key+"#distribution", fmt.Sprint(v)) / 100 * total
+	}
+	for _, w := range r.Distribution {
+		if b < w.Weight {
+			return w.Variant
+		}
+		b -= w.Weight
+	}
+	return r.Distribution[len(r.Distribution)-1].Variant
+}
+
+func (s Segment) contains(ec evaluationContext) bool {
+	id := ec.id()
+	if id != "" && contains(s.Exclude, id) {
+		return false
+	}
+	if id != "" && contains(s.Include, id) {
+		return true
+	}
+	return len(s.Conditions) > 0 && ec.matchAll(s.Conditions)
+}
+
+// bucket returns stable percentage bucket in [0, 100) of value for flag key
+func bucket(key, value string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(key + "/" + value))
+	return float64(h.Sum32()%10000) / 100
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
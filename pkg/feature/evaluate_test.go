@@ -0,0 +1,179 @@
+package feature_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pinkgorilla/go-sample/pkg/auth"
+	perrors "github.com/pinkgorilla/go-sample/pkg/errors"
+	"github.com/pinkgorilla/go-sample/pkg/feature"
+)
+
+func percentage(p float64) *float64 { return &p }
+
+func userContext(id interface{}, attributes map[string]interface{}) context.Context {
+	ctx := auth.ToContext(context.Background(), auth.NewIdentity(id, "user", fmt.Sprint("user ", id)))
+	return feature.WithAttributes(ctx, attributes)
+}
+
+func Test_Evaluate(t *testing.T) {
+	m := feature.GetManager()
+	err := m.SetSegment(feature.Segment{
+		Key:     "beta-testers",
+		Include: []string{"7"},
+		Exclude: []string{"8"},
+		Conditions: []feature.Condition{
+			{Attribute: "email", Operator: feature.OperatorEndsWith, Values: []interface{}{"@example.com"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.SetFlag(feature.Flag{
+		Key:     "checkout",
+		Enabled: true,
+		Variants: map[string]interface{}{
+			"legacy": "v1",
+			"new":    "v2",
+			"beta":   "v3",
+		},
+		On:      "new",
+		Off:     "legacy",
+		Default: "legacy",
+		Allow:   []string{"1"},
+		Deny:    []string{"2"},
+		Rules: []feature.Rule{
+			{Name: "beta", Segments: []string{"beta-testers"}, Variant: "beta"},
+			{
+				Name:       "premium",
+				Conditions: []feature.Condition{{Attribute: "plan", Operator: feature.OperatorIn, Values: []interface{}{"gold", "platinum"}}},
+				Variant:    "new",
+			},
+			{
+				Name:       "tenant",
+				Conditions: []feature.Condition{{Attribute: "seats", Operator: feature.OperatorGreaterEq, Values: []interface{}{100.0}}},
+				Variant:    "new",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type scenario struct {
+		ctx     context.Context
+		variant string
+		reason  feature.Reason
+		rule    string
+	}
+	scenarios := map[string]scenario{
+		"allowed":         {userContext(1, nil), "new", feature.ReasonAllowed, ""},
+		"denied":          {userContext(2, map[string]interface{}{"plan": "gold"}), "legacy", feature.ReasonDenied, ""},
+		"segment include": {userContext(7, nil), "beta", feature.ReasonRuleMatch, "beta"},
+		"segment exclude": {userContext(8, map[string]interface{}{"email": "a@example.com"}), "legacy", feature.ReasonDefault, ""},
+		"segment match":   {userContext(9, map[string]interface{}{"email": "a@example.com"}), "beta", feature.ReasonRuleMatch, "beta"},
+		"attribute":       {userContext(10, map[string]interface{}{"plan": "platinum"}), "new", feature.ReasonRuleMatch, "premium"},
+		"numeric":         {userContext(11, map[string]interface{}{"seats": 150}), "new", feature.ReasonRuleMatch, "tenant"},
+		"no match":        {userContext(12, map[string]interface{}{"plan": "free", "seats": 5}), "legacy", feature.ReasonDefault, ""},
+		"anonymous":       {context.Background(), "legacy", feature.ReasonDefault, ""},
+	}
+	for name, s := range scenarios {
+		e, err := m.Evaluate(s.ctx, "checkout")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Variant != s.variant || e.Reason != s.reason || e.Rule != s.rule {
+			t.Fatalf("%s: expected %v %v %v, got %+v", name, s.variant, s.reason, s.rule, e)
+		}
+		if e.Value != map[string]string{"legacy": "v1", "new": "v2", "beta": "v3"}[s.variant] {
+			t.Fatalf("%s: unexpected value %v", name, e.Value)
+		}
+	}
+
+	f, _ := m.GetFlag("checkout")
+	f.Enabled = false
+	m.SetFlag(f)
+	e, _ := m.Evaluate(userContext(1, nil), "checkout")
+	if e.Variant != "legacy" || e.Reason != feature.ReasonDisabled {
+		t.Fatalf("expected disabled flag to serve off variant, got %+v", e)
+	}
+}
+
+func Test_Evaluate_Percentage(t *testing.T) {
+	m := feature.GetManager()
+	rollout := func(p float64) map[int]bool {
+		err := m.SetFlag(feature.Flag{
+			Key:     "rollout",
+			Enabled: true,
+			Rules:   []feature.Rule{{Percentage: percentage(p), Variant: feature.VariantOn}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		served := map[int]bool{}
+		for i := 0; i < 1000; i++ {
+			e, _ := m.Evaluate(userContext(i, nil), "rollout")
+			if e.Value == true {
+				served[i] = true
+			}
+		}
+		return served
+	}
+
+	quarter := rollout(25)
+	if len(quarter) < 200 || len(quarter) > 300 {
+		t.Fatalf("expected about 250 of 1000 served, got %v", len(quarter))
+	}
+	half := rollout(50)
+	for i := range quarter {
+		if !half[i] {
+			t.Fatalf("expected %v still served after raising percentage", i)
+		}
+	}
+	if len(rollout(0)) != 0 || len(rollout(100)) != 1000 {
+		t.Fatal("expected none served at 0% and all served at 100%")
+	}
+
+	e, _ := m.Evaluate(context.Background(), "rollout")
+	if e.Reason != feature.ReasonDefault || e.Value != false {
+		t.Fatalf("expected context without id not to be bucketed, got %+v", e)
+	}
+}
+
+func Test_Evaluate_State(t *testing.T) {
+	m := feature.GetManager()
+	m.SetState("static", func() interface{} { return "hello" })
+	e, err := m.Evaluate(context.Background(), "static")
+	if err != nil || e.Value != "hello" || e.Reason != feature.ReasonStatic {
+		t.Fatalf("expected static hello, got %+v %v", e, err)
+	}
+	_, err = m.Evaluate(context.Background(), "unknown")
+	if !errors.Is(err, feature.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func Test_SetFlag_Invalid(t *testing.T) {
+	m := feature.GetManager()
+	err := m.SetFlag(feature.Flag{
+		Key: "invalid",
+		Rules: []feature.Rule{
+			{Variant: "missing", Percentage: percentage(120)},
+			{Variant: "on", Conditions: []feature.Condition{{Attribute: "a", Operator: "like", Values: []interface{}{1}}}},
+		},
+	})
+	var verr perrors.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	for _, field := range []string{"rules.0.variant", "rules.0.percentage", "rules.1.conditions.0.operator"} {
+		if verr.GetFieldError(field) == nil {
+			t.Fatalf("expected %s field error, got %v", field, verr.Fields)
+		}
+	}
+	if _, ok := m.GetFlag("invalid"); ok {
+		t.Fatal("expected invalid flag not set")
+	}
+}
@@ -1,24 +1,48 @@
 package feature
 
 import (
+	"context"
 	"fmt"
 	"sync"
 )
 
 type Manager struct {
 	features *sync.Map
+	segments *sync.Map
+	changes  *changes
+	metrics  bool
+}
+
+// ManagerConfig is features manager configuration
+type ManagerConfig struct {
+	// Metrics reports evaluations to global prometheus metrics, shared by every manager reporting them
+	Metrics bool `yaml:"metrics" json:"metrics"`
 }
 
 var m *Manager
 var once sync.Once
 
-// GetManager get singleton features manager instance.
+// NewManager returns new features manager instance with its own features,
+// e.g. for a subsystem or an isolated test, its evaluations are not reported to metrics
+func NewManager() *Manager {
+	return NewManagerWithConfig(&ManagerConfig{})
+}
+
+// NewManagerWithConfig returns new features manager instance with specified config
+func NewManagerWithConfig(config *ManagerConfig) *Manager {
+	return &Manager{
+		features: &sync.Map{},
+		segments: &sync.Map{},
+		changes:  &changes{subscribers: map[int]func(Change){}},
+		metrics:  config.Metrics,
+	}
+}
+
+// GetManager get singleton features manager instance, its evaluations are reported to metrics.
 func GetManager() *Manager {
 	once.Do(func() {
 		if m == nil {
-			m = &Manager{
-				features: &sync.Map{},
-			}
+			m = NewManagerWithConfig(&ManagerConfig{Metrics: true})
 		}
 	})
 	return m
@@ -53,7 +77,7 @@ func (m Manager) WhenEqual(key string, val interface{}, fn func() error) error {
 // when func()interface{} is used as state, the function required to return a value
 // that will be used for evaluation each time WhenEqual method is called
 func (m Manager) SetState(key string, state interface{}) {
-	m.features.Store(key, state)
+	m.SetStateContext(context.Background(), key, state)
 }
 
 // GetState gets the state of feature with corresponding key
@@ -64,3 +88,8 @@ func (m Manager) GetState(key string) interface{} {
 	}
 	return i
 }
+
+// Delete deletes feature with corresponding key
+func (m Manager) Delete(key string) {
+	m.DeleteContext(context.Background(), key)
+}
@@ -0,0 +1,311 @@
+package feature
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+)
+
+const (
+	// VariantOn is variant served by allow list and default on variant of boolean flags
+	VariantOn = "on"
+	// VariantOff is variant served by deny list, disabled flags and flags without matching rule by default
+	VariantOff = "off"
+)
+
+// Flag is feature flag definition evaluated against evaluation context.
+//
+// Disabled flag serves Off variant, otherwise identities in Deny get Off variant, identities in Allow
+// get On variant, then the first matching rule serves its variant and Default variant is served
+// when no rule matches. Flag without Variants is boolean flag with on (true) and off (false) variants
+type Flag struct {
+	Key      string                 `json:"key"`
+	Enabled  bool                   `json:"enabled"`
+	Variants map[string]interface{} `json:"variants,omitempty"`
+	On       string                 `json:"on,omitempty"`
+	Off      string                 `json:"off,omitempty"`
+	Default  string                 `json:"default,omitempty"`
+	Allow    []string               `json:"allow,omitempty"`
+	Deny     []string               `json:"deny,omitempty"`
+	Rules    []Rule                 `json:"rules,omitempty"`
+}
+
+// Rule serves Variant when evaluation context is in all Segments and matches all Conditions,
+// or one of Distribution variants when Distribution is set.
+//
+// When Percentage is set only that percentage of evaluation contexts is served, contexts are
+// assigned by stable hash of flag key and BucketBy attribute, identity id by default,
+// so raising Percentage keeps contexts already served. Distribution variants are assigned the same way
+type Rule struct {
+	Name         string            `json:"name,omitempty"`
+	Segments     []string          `json:"segments,omitempty"`
+	Conditions   []Condition       `json:"conditions,omitempty"`
+	Percentage   *float64          `json:"percentage,omitempty"`
+	BucketBy     string            `json:"bucket_by,omitempty"`
+	Variant      string            `json:"variant,omitempty"`
+	Distribution []WeightedVariant `json:"distribution,omitempty"`
+}
+
+// WeightedVariant is variant served to Weight share of evaluation contexts matching a rule,
+// weights are relative to the sum of weights of the rule distribution
+type WeightedVariant struct {
+	Variant string  `json:"variant"`
+	Weight  float64 `json:"weight"`
+}
+
+// Operator is condition operator
+type Operator string
+
+// Condition operators, ordering operators compare numbers
+const (
+	OperatorEqual      Operator = "eq"
+	OperatorNotEqual   Operator = "neq"
+	OperatorIn         Operator = "in"
+	OperatorNotIn      Operator = "not_in"
+	OperatorGreater    Operator = "gt"
+	OperatorGreaterEq  Operator = "gte"
+	OperatorLess       Operator = "lt"
+	OperatorLessEq     Operator = "lte"
+	OperatorContains   Operator = "contains"
+	OperatorStartsWith Operator = "starts_with"
+	OperatorEndsWith   Operator = "ends_with"
+	OperatorMatches    Operator = "matches"
+)
+
+// Condition compares evaluation context attribute to Values,
+// identity is available as id, type and name attributes.
+// Condition does not match when attribute is missing, except for neq and not_in
+type Condition struct {
+	Attribute string        `json:"attribute"`
+	Operator  Operator      `json:"operator"`
+	Values    []interface{} `json:"values"`
+}
+
+// Segment is named group of evaluation contexts referenced by rules,
+// identities in Include are always in the segment and identities in Exclude never are,
+// otherwise evaluation context is in the segment when it matches all Conditions
+type Segment struct {
+	Key        string      `json:"key"`
+	Include    []string    `json:"include,omitempty"`
+	Exclude    []string    `json:"exclude,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// variants returns flag variants, on and off variants of boolean flag when Variants is empty
+func (f *Flag) variants() map[string]interface{} {
+	if len(f.Variants) == 0 {
+		return map[string]interface{}{VariantOn: true, VariantOff: false}
+	}
+	return f.Variants
+}
+
+func (f *Flag) on() string {
+	if f.On == "" {
+		return VariantOn
+	}
+	return f.On
+}
+
+func (f *Flag) off() string {
+	if f.Off == "" {
+		return VariantOff
+	}
+	return f.Off
+}
+
+func (f *Flag) defaultVariant() string {
+	if f.Default == "" {
+		return f.off()
+	}
+	return f.Default
+}
+
+// Validate validates flag, returned error is errors.ValidationError
+func (f *Flag) Validate() error {
+	e := errors.NewValidationError("invalid feature flag")
+	if f.Key == "" {
+		e.FieldError("key", "key is required")
+	}
+	variants := f.variants()
+	check := func(field, variant string) {
+		if _, ok := variants[variant]; !ok {
+			e.FieldError(field, fmt.Sprintf("variant '%s' is not defined", variant))
+		}
+	}
+	if f.On != "" || len(f.Allow) > 0 {
+		check("on", f.on())
+	}
+	check("off", f.off())
+	check("default", f.defaultVariant())
+	for i, r := range f.Rules {
+		field := fmt.Sprintf("rules.%d", i)
+		if len(r.Distribution) == 0 {
+			check(field+".variant", r.Variant)
+		} else if r.Variant != "" {
+			e.FieldError(field+".variant", "variant and distribution are exclusive")
+		}
+		total := 0.0
+		for j, w := range r.Distribution {
+			check(fmt.Sprintf("%s.distribution.%d.variant", field, j), w.Variant)
+			if w.Weight < 0 {
+				e.FieldError(fmt.Sprintf("%s.distribution.%d.weight", field, j), "weight must not be negative")
+			}
+			total += w.Weight
+		}
+		if len(r.Distribution) > 0 && total <= 0 {
+			e.FieldError(field+".distribution", "sum of weights must be positive")
+		}
+		if r.Percentage != nil && (*r.Percentage < 0 || *r.Percentage > 100) {
+			e.FieldError(field+".percentage", "percentage must be between 0 and 100")
+		}
+		validateConditions(&e, field, r.Conditions)
+	}
+	if len(e.Fields) > 0 {
+		return e
+	}
+	return nil
+}
+
+// Validate validates segment, returned error is errors.ValidationError
+func (s *Segment) Validate() error {
+	e := errors.NewValidationError("invalid feature segment")
+	if s.Key == "" {
+		e.FieldError("key", "key is required")
+	}
+	validateConditions(&e, "", s.Conditions)
+	if len(e.Fields) > 0 {
+		return e
+	}
+	return nil
+}
+
+func validateConditions(e *errors.ValidationError, prefix string, conditions []Condition) {
+	if prefix != "" {
+		prefix += "."
+	}
+	for i, c := range conditions {
+		field := fmt.Sprintf("%sconditions.%d", prefix, i)
+		if c.Attribute == "" {
+			e.FieldError(field+".attribute", "attribute is required")
+		}
+		switch c.Operator {
+		case OperatorEqual, OperatorNotEqual, OperatorIn, OperatorNotIn, OperatorContains,
+			OperatorStartsWith, OperatorEndsWith:
+		case OperatorGreater, OperatorGreaterEq, OperatorLess, OperatorLessEq:
+			for _, v := range c.Values {
+				if _, ok := toFloat(v); !ok {
+					e.FieldError(field+".values", fmt.Sprintf("operator '%s' requires numeric values", c.Operator))
+					break
+				}
+			}
+		case OperatorMatches:
+			for _, v := range c.Values {
+				if _, err := regexp.Compile(fmt.Sprint(v)); err != nil {
+					e.FieldError(field+".values", err.Error())
+					break
+				}
+			}
+		default:
+			e.FieldError(field+".operator", fmt.Sprintf("unknown operator '%s'", c.Operator))
+		}
+		if len(c.Values) == 0 {
+			e.FieldError(field+".values", "values are required")
+		}
+	}
+}
+
+// match returns true when attribute value v matches the condition
+func (c Condition) match(v interface{}, ok bool) bool {
+	if !ok {
+		return c.Operator == OperatorNotEqual || c.Operator == OperatorNotIn
+	}
+	switch c.Operator {
+	case OperatorEqual, OperatorIn:
+		return c.any(func(x interface{}) bool { return equal(v, x) })
+	case OperatorNotEqual, OperatorNotIn:
+		return !c.any(func(x interface{}) bool { return equal(v, x) })
+	case OperatorGreater, OperatorGreaterEq, OperatorLess, OperatorLessEq:
+		f, ok := toFloat(v)
+		if !ok {
+			return false
+		}
+		return c.any(func(x interface{}) bool {
+			y, ok := toFloat(x)
+			if !ok {
+				return false
+			}
+			switch c.Operator {
+			case OperatorGreater:
+				return f > y
+			case OperatorGreaterEq:
+				return f >= y
+			case OperatorLess:
+				return f < y
+			}
+			return f <= y
+		})
+	case OperatorContains:
+		return c.any(func(x interface{}) bool { return strings.Contains(fmt.Sprint(v), fmt.Sprint(x)) })
+	case OperatorStartsWith:
+		return c.any(func(x interface{}) bool { return strings.HasPrefix(fmt.Sprint(v), fmt.Sprint(x)) })
+	case OperatorEndsWith:
+		return c.any(func(x interface{}) bool { return strings.HasSuffix(fmt.Sprint(v), fmt.Sprint(x)) })
+	case OperatorMatches:
+		return c.any(func(x interface{}) bool {
+			re, err := regexp.Compile(fmt.Sprint(x))
+			return err == nil && re.MatchString(fmt.Sprint(v))
+		})
+	}
+	return false
+}
+
+func (c Condition) any(fn func(x interface{}) bool) bool {
+	for _, x := range c.Values {
+		if fn(x) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares numbers by value, e.g. int attribute to float64 decoded from json, and others by string
+func equal(a, b interface{}) bool {
+	x, okx := toFloat(a)
+	y, oky := toFloat(b)
+	if okx && oky {
+		return x == y
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,61 @@
+package feature
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce sync.Once
+
+	evaluationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "feature_evaluations_total",
+			Help: "A counter for feature evaluations, partitioned by served variant and reason, flag is empty for unknown features.",
+		},
+		[]string{"flag", "variant", "reason"},
+	)
+	lastEvaluated = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "feature_last_evaluated_timestamp_seconds",
+			Help: "Unix time of the last evaluation of feature, 0 for features never evaluated since they were set.",
+		},
+		[]string{"flag"},
+	)
+)
+
+func registerMetrics() {
+	metricsOnce.Do(func() {
+		prometheus.MustRegister(evaluationsTotal, lastEvaluated)
+	})
+}
+
+// observeEvaluation reports evaluation of feature, evaluations of unknown features are reported
+// without feature key so arbitrary keys do not create new series
+func (m Manager) observeEvaluation(key, variant string, reason Reason) {
+	if !m.metrics {
+		return
+	}
+	registerMetrics()
+	if _, ok := m.features.Load(key); !ok {
+		evaluationsTotal.WithLabelValues("", variant, string(reason)).Inc()
+		return
+	}
+	evaluationsTotal.WithLabelValues(key, variant, string(reason)).Inc()
+	lastEvaluated.WithLabelValues(key).SetToCurrentTime()
+}
+
+// observeStored reports feature set for the first time as never evaluated and forgets deleted feature
+func (m Manager) observeStored(key string, created, deleted bool) {
+	if !m.metrics {
+		return
+	}
+	registerMetrics()
+	switch {
+	case created:
+		lastEvaluated.WithLabelValues(key).Set(0)
+	case deleted:
+		lastEvaluated.DeleteLabelValues(key)
+	}
+}
@@ -0,0 +1,55 @@
+package feature
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileProvider is Provider reading definitions from json or yaml file, format is decided by file extension
+type FileProvider struct {
+	path     string
+	interval time.Duration
+}
+
+// NewFileProvider returns new FileProvider of file at path, checked for changes every interval
+func NewFileProvider(path string, interval time.Duration) *FileProvider {
+	return &FileProvider{
+		path:     path,
+		interval: interval,
+	}
+}
+
+// Load implements Provider
+func (p *FileProvider) Load(ctx context.Context) (*Definitions, error) {
+	bs, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDefinitions(bs, strings.TrimPrefix(filepath.Ext(p.path), "."))
+}
+
+// Watch implements Provider, file is reloaded when its modification time or size changes
+func (p *FileProvider) Watch(ctx context.Context, fn func(*Definitions, error)) error {
+	var modified time.Time
+	var size int64 = -1
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		info, err := os.Stat(p.path)
+		if err != nil {
+			fn(nil, err)
+		} else if !info.ModTime().Equal(modified) || info.Size() != size {
+			modified, size = info.ModTime(), info.Size()
+			fn(p.Load(ctx))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package feature
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPProvider is Provider polling definitions from url, response is parsed as yaml
+// when its content type contains yaml, otherwise as json
+type HTTPProvider struct {
+	// Client sends requests, http.DefaultClient is used when nil
+	Client *http.Client
+
+	url      string
+	interval time.Duration
+	etag     string
+	last     []byte
+	mu       sync.Mutex
+}
+
+// NewHTTPProvider returns new HTTPProvider of url, polled for changes every interval
+func NewHTTPProvider(url string, interval time.Duration) *HTTPProvider {
+	return &HTTPProvider{
+		url:      url,
+		interval: interval,
+	}
+}
+
+// Load implements Provider
+func (p *HTTPProvider) Load(ctx context.Context) (*Definitions, error) {
+	d, _, err := p.fetch(ctx, false)
+	return d, err
+}
+
+// Watch implements Provider, unchanged responses are detected by ETag or by content
+func (p *HTTPProvider) Watch(ctx context.Context, fn func(*Definitions, error)) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	first := true
+	for {
+		d, changed, err := p.fetch(ctx, !first)
+		if err != nil {
+			fn(nil, err)
+		} else if changed || first {
+			fn(d, nil)
+			first = false
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetch fetches definitions, when conditional is true request is conditional on ETag of last response
+// and nil definitions are returned when unchanged
+func (p *HTTPProvider) fetch(ctx context.Context, conditional bool) (*Definitions, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req = req.WithContext(ctx)
+	p.mu.Lock()
+	if conditional && p.etag != "" {
+		req.Header.Set("If-None-Match", p.etag)
+	}
+	p.mu.Unlock()
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("feature: unexpected status code %d from %s", res.StatusCode, p.url)
+	}
+	bs, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	format := "json"
+	if strings.Contains(res.Header.Get("Content-Type"), "yaml") {
+		format = "yaml"
+	}
+	d, err := ParseDefinitions(bs, format)
+	if err != nil {
+		return nil, false, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	changed := !bytes.Equal(bs, p.last)
+	p.etag = res.Header.Get("ETag")
+	p.last = bs
+	if conditional && !changed {
+		return nil, false, nil
+	}
+	return d, changed, nil
+}
@@ -0,0 +1,127 @@
+package feature
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis"
+)
+
+// RedisProvider is Provider keeping definitions in redis hashes, changes made through
+// SetFlag, DeleteFlag, SetSegment and DeleteSegment are published to watching instances
+type RedisProvider struct {
+	prefix string
+	r      *redis.Client
+}
+
+// NewRedisProvider returns new RedisProvider, keys and channel are prefixed with prefix
+func NewRedisProvider(r *redis.Client, prefix string) *RedisProvider {
+	return &RedisProvider{
+		prefix: prefix,
+		r:      r,
+	}
+}
+
+func (p *RedisProvider) key(name string) string {
+	return fmt.Sprintf("%s:%s", p.prefix, name)
+}
+
+// Load implements Provider
+func (p *RedisProvider) Load(ctx context.Context) (*Definitions, error) {
+	r := p.r.WithContext(ctx)
+	d := &Definitions{}
+	flags, err := r.HGetAll(p.key("flags")).Result()
+	if err != nil {
+		return nil, err
+	}
+	for key, s := range flags {
+		var f Flag
+		if err := json.Unmarshal([]byte(s), &f); err != nil {
+			return nil, fmt.Errorf("feature: invalid flag '%s': %w", key, err)
+		}
+		d.Flags = append(d.Flags, f)
+	}
+	segments, err := r.HGetAll(p.key("segments")).Result()
+	if err != nil {
+		return nil, err
+	}
+	for key, s := range segments {
+		var seg Segment
+		if err := json.Unmarshal([]byte(s), &seg); err != nil {
+			return nil, fmt.Errorf("feature: invalid segment '%s': %w", key, err)
+		}
+		d.Segments = append(d.Segments, seg)
+	}
+	return d, nil
+}
+
+// Watch implements Provider, definitions are reloaded on every published change
+func (p *RedisProvider) Watch(ctx context.Context, fn func(*Definitions, error)) error {
+	sub := p.r.Subscribe(p.key("changes"))
+	defer sub.Close()
+	if _, err := sub.Receive(); err != nil {
+		return err
+	}
+	fn(p.Load(ctx))
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-ch:
+			if !ok {
+				return fmt.Errorf("feature: redis subscription closed")
+			}
+			fn(p.Load(ctx))
+		}
+	}
+}
+
+// SetFlag stores flag and publishes the change
+func (p *RedisProvider) SetFlag(ctx context.Context, f Flag) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	return p.set(ctx, "flags", f.Key, f)
+}
+
+// DeleteFlag deletes flag and publishes the change
+func (p *RedisProvider) DeleteFlag(ctx context.Context, key string) error {
+	return p.del(ctx, "flags", key)
+}
+
+// SetSegment stores segment and publishes the change
+func (p *RedisProvider) SetSegment(ctx context.Context, s Segment) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	return p.set(ctx, "segments", s.Key, s)
+}
+
+// DeleteSegment deletes segment and publishes the change
+func (p *RedisProvider) DeleteSegment(ctx context.Context, key string) error {
+	return p.del(ctx, "segments", key)
+}
+
+func (p *RedisProvider) set(ctx context.Context, hash, key string, v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = p.r.WithContext(ctx).TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HSet(p.key(hash), key, bs)
+		pipe.Publish(p.key("changes"), key)
+		return nil
+	})
+	return err
+}
+
+func (p *RedisProvider) del(ctx context.Context, hash, key string) error {
+	_, err := p.r.WithContext(ctx).TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HDel(p.key(hash), key)
+		pipe.Publish(p.key("changes"), key)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,208 @@
+package feature
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Definitions is set of flags and segments defined by Provider
+type Definitions struct {
+	Flags    []Flag    `json:"flags"`
+	Segments []Segment `json:"segments"`
+}
+
+// Provider provides feature definitions from outside of the code, e.g. file or remote service
+type Provider interface {
+	// Load returns current definitions
+	Load(ctx context.Context) (*Definitions, error)
+	// Watch calls fn with current definitions and then with definitions on every change,
+	// or with error when definitions cannot be loaded, until ctx is done
+	Watch(ctx context.Context, fn func(*Definitions, error)) error
+}
+
+// ParseDefinitions parses json definitions, or yaml definitions when format is yaml or yml.
+// Yaml is parsed as yaml 1.2, so on and off are strings rather than booleans
+func ParseDefinitions(bs []byte, format string) (*Definitions, error) {
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		var v interface{}
+		if err := yaml.Unmarshal(bs, &v); err != nil {
+			return nil, fmt.Errorf("feature: invalid yaml definitions: %w", err)
+		}
+		var err error
+		// decode through json so values are of the same types as json definitions
+		bs, err = json.Marshal(jsonCompatible(v))
+		if err != nil {
+			return nil, fmt.Errorf("feature: invalid yaml definitions: %w", err)
+		}
+	}
+	d := &Definitions{}
+	if err := json.Unmarshal(bs, d); err != nil {
+		return nil, fmt.Errorf("feature: invalid definitions: %w", err)
+	}
+	return d, nil
+}
+
+// jsonCompatible converts maps with non string keys decoded by yaml to map[string]interface{}
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[fmt.Sprint(k)] = jsonCompatible(v)
+		}
+		return m
+	case []interface{}:
+		for i, v := range t {
+			t[i] = jsonCompatible(v)
+		}
+	}
+	return v
+}
+
+// Syncer feeds Manager with definitions of providers. Providers are in ascending precedence,
+// definition of a key from later provider replaces definition of the same key from earlier providers.
+// Keys removed from all providers are deleted from Manager, keys set only in code are kept.
+// Provider definitions take precedence over admin edits: an edited key is kept until its provider definition changes,
+// the changed definition then replaces the edit
+type Syncer struct {
+	// OnError is called with errors of watched providers and invalid definitions, errors are logged when nil
+	OnError func(err error)
+
+	manager     *Manager
+	providers   []Provider
+	definitions []*Definitions
+	flags       map[string]Flag    // applied flag definitions
+	segments    map[string]Segment // applied segment definitions
+	mu          sync.Mutex
+}
+
+// NewSyncer returns new Syncer feeding m, GetManager() is fed when m is nil
+func NewSyncer(m *Manager, providers ...Provider) *Syncer {
+	if m == nil {
+		m = GetManager()
+	}
+	return &Syncer{
+		manager:     m,
+		providers:   providers,
+		definitions: make([]*Definitions, len(providers)),
+		flags:       map[string]Flag{},
+		segments:    map[string]Segment{},
+	}
+}
+
+// Load loads definitions of all providers once
+func (s *Syncer) Load(ctx context.Context) error {
+	for i, p := range s.providers {
+		d, err := p.Load(ctx)
+		if err != nil {
+			return err
+		}
+		s.update(i, d)
+	}
+	return nil
+}
+
+// Run loads definitions of all providers and keeps them updated until ctx is done
+func (s *Syncer) Run(ctx context.Context) error {
+	if err := s.Load(ctx); err != nil {
+		return err
+	}
+	errs := make(chan error, len(s.providers))
+	for i, p := range s.providers {
+		go func(i int, p Provider) {
+			errs <- p.Watch(ctx, func(d *Definitions, err error) {
+				if err != nil {
+					s.error(err)
+					return
+				}
+				s.update(i, d)
+			})
+		}(i, p)
+	}
+	var err error
+	for range s.providers {
+		if e := <-errs; e != nil && e != ctx.Err() && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (s *Syncer) error(err error) {
+	if s.OnError != nil {
+		s.OnError(err)
+		return
+	}
+	log.Println("feature:", err)
+}
+
+// update replaces definitions of provider i and applies merged definitions to manager
+func (s *Syncer) update(i int, d *Definitions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.definitions[i] = d
+
+	flags := map[string]Flag{}
+	segments := map[string]Segment{}
+	for _, d := range s.definitions {
+		if d == nil {
+			continue
+		}
+		for _, f := range d.Flags {
+			flags[f.Key] = f
+		}
+		for _, seg := range d.Segments {
+			segments[seg.Key] = seg
+		}
+	}
+
+	// only definitions changed since last update are applied, so admin edits of other keys are kept
+	// and subscribers are notified only of actual changes
+	for key, seg := range segments {
+		if applied, ok := s.segments[key]; ok && reflect.DeepEqual(applied, seg) {
+			continue
+		}
+		if current, ok := s.manager.GetSegment(key); !ok || !reflect.DeepEqual(current, seg) {
+			if err := s.manager.SetSegment(seg); err != nil {
+				s.error(fmt.Errorf("segment '%s': %w", key, err))
+				continue
+			}
+		}
+		s.segments[key] = seg
+	}
+	for key, f := range flags {
+		if applied, ok := s.flags[key]; ok && reflect.DeepEqual(applied, f) {
+			continue
+		}
+		if current, ok := s.manager.GetFlag(key); !ok || !reflect.DeepEqual(current, f) {
+			if err := s.manager.SetFlag(f); err != nil {
+				s.error(fmt.Errorf("flag '%s': %w", key, err))
+				continue
+			}
+		}
+		s.flags[key] = f
+	}
+	for key := range s.flags {
+		if _, ok := flags[key]; !ok {
+			s.manager.Delete(key)
+			delete(s.flags, key)
+		}
+	}
+	for key := range s.segments {
+		if _, ok := segments[key]; !ok {
+			s.manager.DeleteSegment(key)
+			delete(s.segments, key)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package feature_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pinkgorilla/go-sample/pkg/feature"
+)
+
+type staticProvider struct {
+	definitions *feature.Definitions
+}
+
+func (p *staticProvider) Load(ctx context.Context) (*feature.Definitions, error) {
+	return p.definitions, nil
+}
+
+func (p *staticProvider) Watch(ctx context.Context, fn func(*feature.Definitions, error)) error {
+	fn(p.definitions, nil)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+const yamlDefinitions = `
+flags:
+  - key: %s
+    enabled: true
+    variants:
+      small: {limit: 10}
+      large: {limit: 100}
+    default: %s
+    off: small
+    rules:
+      - name: vip
+        conditions:
+          - {attribute: tier, operator: eq, values: [vip]}
+        variant: large
+`
+
+func waitFor(t *testing.T, what string, fn func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func variant(key string) string {
+	e, err := feature.GetManager().Evaluate(context.Background(), key)
+	if err != nil {
+		return ""
+	}
+	return e.Variant
+}
+
+func Test_ParseDefinitions(t *testing.T) {
+	d, err := feature.ParseDefinitions([]byte(fmt.Sprintf(yamlDefinitions, "limits", "small")), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Flags) != 1 || len(d.Flags[0].Rules) != 1 {
+		t.Fatalf("unexpected definitions %+v", d)
+	}
+	limit := d.Flags[0].Variants["large"].(map[string]interface{})["limit"]
+	if limit != 100.0 {
+		t.Fatalf("expected json compatible value 100.0, got %#v", limit)
+	}
+	if err := d.Flags[0].Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := feature.ParseDefinitions([]byte("flags: ["), "yml"); err == nil {
+		t.Fatal("expected error of invalid yaml")
+	}
+}
+
+func Test_Syncer_Precedence(t *testing.T) {
+	m := feature.GetManager()
+	m.SetState("sync-code", true)
+	base := &staticProvider{&feature.Definitions{
+		Flags: []feature.Flag{
+			{Key: "sync-a", Enabled: false},
+			{Key: "sync-b", Enabled: false},
+		},
+	}}
+	override := &staticProvider{&feature.Definitions{
+		Flags: []feature.Flag{{Key: "sync-a", Enabled: true, Default: feature.VariantOn}},
+	}}
+	s := feature.NewSyncer(nil, base, override)
+	if err := s.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if variant("sync-a") != feature.VariantOn || variant("sync-b") != feature.VariantOff {
+		t.Fatal("expected later provider to take precedence")
+	}
+
+	base.definitions = &feature.Definitions{}
+	override.definitions = &feature.Definitions{Flags: []feature.Flag{{Key: "sync-a", Enabled: true, Default: "unknown"}}}
+	errs := []error{}
+	s.OnError = func(err error) { errs = append(errs, err) }
+	s.Load(context.Background())
+	if _, ok := m.GetFlag("sync-b"); ok {
+		t.Fatal("expected flag removed from providers to be deleted")
+	}
+	if m.GetState("sync-code") != true {
+		t.Fatal("expected state set in code to be kept")
+	}
+	if len(errs) != 1 || variant("sync-a") != feature.VariantOn {
+		t.Fatalf("expected invalid flag reported and previous flag kept, got %v", errs)
+	}
+}
+
+func Test_FileProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "flags.yaml")
+	write := func(def string) {
+		if err := ioutil.WriteFile(path, []byte(fmt.Sprintf(yamlDefinitions, "file-limits", def)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("small")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := feature.NewSyncer(nil, feature.NewFileProvider(path, 10*time.Millisecond))
+	go s.Run(ctx)
+	waitFor(t, "file definitions", func() bool { return variant("file-limits") == "small" })
+
+	write("large")
+	waitFor(t, "reloaded file definitions", func() bool { return variant("file-limits") == "large" })
+
+	e, _ := feature.GetManager().Evaluate(feature.WithAttributes(context.Background(), map[string]interface{}{"tier": "vip"}), "file-limits")
+	if e.Reason != feature.ReasonRuleMatch {
+		t.Fatalf("expected rule match, got %+v", e)
+	}
+}
+
+func Test_HTTPProvider(t *testing.T) {
+	mu := sync.Mutex{}
+	version, requests, notModified := 1, 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		etag := fmt.Sprint(`"`, version, `"`)
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, `{"flags":[{"key":"http-flag","enabled":true,"default":"%s"}]}`,
+			map[int]string{1: "off", 2: "on"}[version])
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := feature.NewSyncer(nil, feature.NewHTTPProvider(srv.URL, 10*time.Millisecond))
+	go s.Run(ctx)
+	waitFor(t, "http definitions", func() bool { return variant("http-flag") == feature.VariantOff })
+	waitFor(t, "not modified responses", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return notModified > 0
+	})
+
+	mu.Lock()
+	version = 2
+	mu.Unlock()
+	waitFor(t, "changed http definitions", func() bool { return variant("http-flag") == feature.VariantOn })
+}
+
+func Test_RedisProvider(t *testing.T) {
+	r := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	defer r.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := feature.NewRedisProvider(r, fmt.Sprintf("feature-test-%d", time.Now().UnixNano()))
+	if err := p.SetFlag(ctx, feature.Flag{Key: "redis-flag", Enabled: false}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := feature.NewSyncer(nil, p)
+	go s.Run(ctx)
+	waitFor(t, "redis definitions", func() bool { return variant("redis-flag") == feature.VariantOff })
+
+	p.SetSegment(ctx, feature.Segment{Key: "redis-segment", Include: []string{"1"}})
+	p.SetFlag(ctx, feature.Flag{
+		Key:     "redis-flag",
+		Enabled: true,
+		Rules:   []feature.Rule{{Segments: []string{"redis-segment"}, Variant: feature.VariantOn}},
+	})
+	waitFor(t, "published flag change", func() bool {
+		e, _ := feature.GetManager().Evaluate(userContext(1, nil), "redis-flag")
+		return e.Variant == feature.VariantOn
+	})
+
+	p.DeleteFlag(ctx, "redis-flag")
+	waitFor(t, "published flag deletion", func() bool {
+		_, ok := feature.GetManager().GetFlag("redis-flag")
+		return !ok
+	})
+}
+
+// watchedProvider is staticProvider signalling when its definitions were sent by Watch
+type watchedProvider struct {
+	*staticProvider
+	watched chan struct{}
+}
+
+func (p *watchedProvider) Watch(ctx context.Context, fn func(*feature.Definitions, error)) error {
+	fn(p.definitions, nil)
+	close(p.watched)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func Test_Syncer_SkipsUnchanged(t *testing.T) {
+	m := feature.NewManager()
+	var mu sync.Mutex
+	changes := map[string]int{}
+	m.Subscribe(func(c feature.Change) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes[c.Key]++
+	})
+	p := &watchedProvider{&staticProvider{&feature.Definitions{
+		Flags:    []feature.Flag{{Key: "unchanged", Enabled: true, Default: feature.VariantOn}, {Key: "changed"}},
+		Segments: []feature.Segment{{Key: "beta", Include: []string{"1"}}},
+	}}, make(chan struct{})}
+	s := feature.NewSyncer(m, p)
+	if err := s.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Run loads and watches the same definitions again
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+	<-p.watched
+	cancel()
+	<-done
+
+	p.definitions = &feature.Definitions{
+		Flags:    []feature.Flag{{Key: "unchanged", Enabled: true, Default: feature.VariantOn}, {Key: "changed", Enabled: true}},
+		Segments: []feature.Segment{{Key: "beta", Include: []string{"1"}}},
+	}
+	if err := s.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(changes) != "map[beta:1 changed:2 unchanged:1]" {
+		t.Fatalf("expected changes of changed definitions only, got %v", changes)
+	}
+}
+
+func Test_Syncer_KeepsAdminEdits(t *testing.T) {
+	m := feature.NewManager()
+	p := &staticProvider{&feature.Definitions{
+		Flags: []feature.Flag{{Key: "edited"}, {Key: "other"}},
+	}}
+	s := feature.NewSyncer(m, p)
+	if err := s.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// admin edit
+	if err := m.SetFlag(feature.Flag{Key: "edited", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	p.definitions = &feature.Definitions{
+		Flags: []feature.Flag{{Key: "edited"}, {Key: "other", Enabled: true}},
+	}
+	if err := s.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := m.GetFlag("edited"); !f.Enabled {
+		t.Fatal("expected admin edit kept when its provider definition did not change")
+	}
+	if f, _ := m.GetFlag("other"); !f.Enabled {
+		t.Fatal("expected changed definition applied")
+	}
+
+	p.definitions = &feature.Definitions{
+		Flags: []feature.Flag{{Key: "edited", Enabled: true, Default: feature.VariantOn}, {Key: "other", Enabled: true}},
+	}
+	if err := s.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := m.GetFlag("edited"); f.Default != feature.VariantOn {
+		t.Fatalf("expected changed provider definition to replace admin edit, got %+v", f)
+	}
+}
@@ -0,0 +1,73 @@
+package feature
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+)
+
+// Bool returns bool value of feature with corresponding key evaluated for ctx,
+// def is returned when feature is unknown or its value is not bool
+func (m Manager) Bool(ctx context.Context, key string, def bool) bool {
+	v, ok := m.value(ctx, key).(bool)
+	if !ok {
+		return def
+	}
+	return v
+}
+
+// String returns string value of feature with corresponding key evaluated for ctx,
+// def is returned when feature is unknown or its value is not string
+func (m Manager) String(ctx context.Context, key string, def string) string {
+	v, ok := m.value(ctx, key).(string)
+	if !ok {
+		return def
+	}
+	return v
+}
+
+// Int returns int value of feature with corresponding key evaluated for ctx, whole float values
+// such as numbers decoded from json are accepted. def is returned when feature is unknown or its value is not integer
+func (m Manager) Int(ctx context.Context, key string, def int) int {
+	f, ok := toFloat(m.value(ctx, key))
+	if !ok || f != math.Trunc(f) {
+		return def
+	}
+	return int(f)
+}
+
+// JSON decodes value of feature with corresponding key evaluated for ctx into v through json,
+// e.g. to a struct. v is left unchanged and false is returned when feature is unknown or not decodable,
+// so v can be prefilled with the default
+func (m Manager) JSON(ctx context.Context, key string, v interface{}) bool {
+	value := m.value(ctx, key)
+	if value == nil {
+		return false
+	}
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return false
+	}
+	// decoded into a new value first so v is unchanged when partially decoded
+	decoded := reflect.New(rv.Elem().Type())
+	if err := json.Unmarshal(bs, decoded.Interface()); err != nil {
+		return false
+	}
+	rv.Elem().Set(decoded.Elem())
+	return true
+}
+
+// value returns evaluated value of feature, nil when unknown
+func (m Manager) value(ctx context.Context, key string) interface{} {
+	e, err := m.Evaluate(ctx, key)
+	if err != nil {
+		m.observeEvaluation(key, "", ReasonNotFound)
+		return nil
+	}
+	return e.Value
+}
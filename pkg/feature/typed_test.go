@@ -0,0 +1,163 @@
+package feature_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pinkgorilla/go-sample/pkg/feature"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Test_TypedAccessors(t *testing.T) {
+	m := feature.GetManager()
+	ctx := context.Background()
+	m.SetState("typed-bool", true)
+	m.SetState("typed-string", "blue")
+	m.SetState("typed-int", 3.0)
+	m.SetState("typed-fraction", 3.5)
+	m.SetFlag(feature.Flag{
+		Key:      "typed-json",
+		Enabled:  true,
+		Variants: map[string]interface{}{"off": map[string]interface{}{"limit": 10.0, "name": "basic"}},
+	})
+
+	if !m.Bool(ctx, "typed-bool", false) || m.Bool(ctx, "typed-string", false) || !m.Bool(ctx, "unknown", true) {
+		t.Fatal("unexpected Bool")
+	}
+	if m.String(ctx, "typed-string", "red") != "blue" || m.String(ctx, "typed-int", "red") != "red" {
+		t.Fatal("unexpected String")
+	}
+	if m.Int(ctx, "typed-int", 0) != 3 || m.Int(ctx, "typed-fraction", 0) != 0 || m.Int(ctx, "unknown", 7) != 7 {
+		t.Fatal("unexpected Int")
+	}
+
+	type plan struct {
+		Limit int    `json:"limit"`
+		Name  string `json:"name"`
+	}
+	p := plan{Limit: 1, Name: "default"}
+	if !m.JSON(ctx, "typed-json", &p) || p.Limit != 10 || p.Name != "basic" {
+		t.Fatalf("unexpected JSON %+v", p)
+	}
+	p = plan{Limit: 1, Name: "default"}
+	if m.JSON(ctx, "unknown", &p) || p.Name != "default" {
+		t.Fatalf("expected default kept, got %+v", p)
+	}
+	var wrong struct {
+		Limit string `json:"limit"`
+		Name  string `json:"name"`
+	}
+	wrong.Name = "default"
+	if m.JSON(ctx, "typed-json", &wrong) || wrong.Name != "default" {
+		t.Fatalf("expected default kept on decode error, got %+v", wrong)
+	}
+}
+
+func Test_Evaluate_Distribution(t *testing.T) {
+	m := feature.GetManager()
+	err := m.SetFlag(feature.Flag{
+		Key:      "distribution",
+		Enabled:  true,
+		Variants: map[string]interface{}{"control": "a", "treatment": "b", "holdout": "c"},
+		Default:  "control",
+		Off:      "control",
+		Rules: []feature.Rule{{
+			Percentage: percentage(50),
+			Distribution: []feature.WeightedVariant{
+				{Variant: "control", Weight: 1},
+				{Variant: "treatment", Weight: 3},
+				{Variant: "holdout", Weight: 0},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		ctx := userContext(i, nil)
+		e, _ := m.Evaluate(ctx, "distribution")
+		if e.Reason == feature.ReasonRuleMatch {
+			served[e.Variant]++
+		}
+		if again, _ := m.Evaluate(ctx, "distribution"); again.Variant != e.Variant {
+			t.Fatal("expected stable variant")
+		}
+	}
+	total := served["control"] + served["treatment"]
+	if total < 1800 || total > 2200 || served["holdout"] != 0 {
+		t.Fatalf("expected about 2000 in rollout and none held out, got %v", served)
+	}
+	if ratio := float64(served["treatment"]) / float64(total); ratio < 0.7 || ratio > 0.8 {
+		t.Fatalf("expected about 75%% treatment, got %v", ratio)
+	}
+
+	err = m.SetFlag(feature.Flag{
+		Key:   "invalid-distribution",
+		Rules: []feature.Rule{{Distribution: []feature.WeightedVariant{{Variant: "on", Weight: 0}}}},
+	})
+	if err == nil {
+		t.Fatal("expected distribution without positive weight rejected")
+	}
+}
+
+func Test_EvaluationMetrics(t *testing.T) {
+	m := feature.GetManager()
+	ctx := context.Background()
+	m.SetFlag(feature.Flag{Key: "metered", Enabled: true, Default: feature.VariantOn})
+	m.SetFlag(feature.Flag{Key: "stale", Enabled: true})
+
+	metrics := func() map[string]float64 {
+		mfs, err := prometheus.DefaultGatherer.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := map[string]float64{}
+		for _, mf := range mfs {
+			for _, metric := range mf.Metric {
+				name := mf.GetName()
+				for _, l := range metric.Label {
+					name += "," + l.GetValue()
+				}
+				if metric.Counter != nil {
+					values[name] = metric.Counter.GetValue()
+				} else if metric.Gauge != nil {
+					values[name] = metric.Gauge.GetValue()
+				}
+			}
+		}
+		return values
+	}
+
+	before := metrics()
+	isolated := feature.NewManager()
+	isolated.SetFlag(feature.Flag{Key: "isolated", Enabled: true, Default: feature.VariantOn})
+	isolated.Bool(ctx, "isolated", false)
+	isolated.Bool(ctx, "isolated-never-set", false)
+
+	m.Bool(ctx, "metered", false)
+	m.Bool(ctx, "metered", false)
+	m.Bool(ctx, "never-set", false)
+	m.Bool(feature.WithOverride(ctx, "overridden-never-set", true), "overridden-never-set", false)
+	values := metrics()
+	if values["feature_evaluations_total,metered,default,on"] != 2 {
+		t.Fatalf("expected 2 evaluations of metered, got %v", values)
+	}
+	notFound := values["feature_evaluations_total,,not_found,"] - before["feature_evaluations_total,,not_found,"]
+	overridden := values["feature_evaluations_total,,override,"] - before["feature_evaluations_total,,override,"]
+	if notFound != 1 || overridden != 1 {
+		t.Fatalf("expected evaluations of unknown flags counted without flag, got %v", values)
+	}
+	for name := range values {
+		if strings.Contains(name, "never-set") || strings.Contains(name, "isolated") {
+			t.Fatalf("expected no series of %s, got %v", name, values)
+		}
+	}
+	if values["feature_last_evaluated_timestamp_seconds,metered"] == 0 {
+		t.Fatal("expected last evaluation of metered reported")
+	}
+	if v, ok := values["feature_last_evaluated_timestamp_seconds,stale"]; !ok || v != 0 {
+		t.Fatalf("expected stale flag reported as never evaluated, got %v %v", v, ok)
+	}
+}
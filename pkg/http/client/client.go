@@ -3,18 +3,34 @@ package client
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
+	"io"
 	"io/ioutil"
 	"math"
 	"net/http"
+	"sync"
 	"time"
+
+	"github.com/pinkgorilla/go-sample/pkg/circuit"
 )
 
+// ErrCircuitOpen is returned by Do when circuit breaker of request is open
+var ErrCircuitOpen = circuit.ErrCircuitOpen
+
+var errServerError = errors.New("server error response")
+
 // ClientConfig is http Client configuration
 type ClientConfig struct {
 	MaxRequestAttempt        int
 	MinRequestAttemptDelay   time.Duration
 	RequestTimeout           time.Duration
 	StopAttemptOnStatusCodes []int
+	// Breakers is consulted on every request attempt when not nil,
+	// transport errors and 5xx responses are recorded as failures
+	Breakers *circuit.Registry
+	// BreakerName returns name of circuit breaker of request, request host is used when nil
+	BreakerName func(req *http.Request) string
 }
 
 // DefaultClientConfig returns default client config
@@ -70,7 +86,11 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 		}
 		attempt++
 
-		res, err = c.http.Do(r2)
+		if res != nil {
+			// response of previous attempt is discarded
+			res.Body.Close()
+		}
+		res, err = c.send(r2)
 		if err != nil {
 			return res, err
 		}
@@ -89,6 +109,70 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	}
 }
 
+// send sends request through its circuit breaker when Breakers is configured.
+// Outcome is recorded once response headers arrive, body is streamed to caller outside of invocation timeout
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.config.Breakers == nil {
+		return c.http.Do(req)
+	}
+	name := req.URL.Host
+	if c.config.BreakerName != nil {
+		name = c.config.BreakerName(req)
+	}
+	// request outlives invocation so body can be read after breaker returns,
+	// it is cancelled on invocation failure or once body is closed
+	ctx, cancel := context.WithCancel(req.Context())
+	var mu sync.Mutex
+	var received *http.Response
+	abandoned := false
+	v, err := c.config.Breakers.Get(name).Do(ctx, func(context.Context) (interface{}, error) {
+		res, err := c.http.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if abandoned {
+			res.Body.Close()
+			return nil, context.Canceled
+		}
+		received = res
+		if res.StatusCode >= 500 {
+			return res, errServerError
+		}
+		return res, nil
+	})
+	res, _ := v.(*http.Response)
+	if res == nil {
+		// response arriving after invocation timed out is closed
+		mu.Lock()
+		abandoned = true
+		if received != nil {
+			received.Body.Close()
+		}
+		mu.Unlock()
+		cancel()
+		return nil, err
+	}
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	if err == errServerError {
+		return res, nil
+	}
+	return res, err
+}
+
+// cancelBody cancels request context once response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 func (c *Client) makeRequestCopier(req *http.Request) func() (*http.Request, error) {
 	var bs []byte
 	if req.ContentLength > 0 {
@@ -102,7 +186,7 @@ func (c *Client) makeRequestCopier(req *http.Request) func() (*http.Request, err
 	h := req.Header
 
 	return func() (*http.Request, error) {
-		r, e := http.NewRequest(req.Method, req.URL.String(), nil)
+		r, e := http.NewRequestWithContext(req.Context(), req.Method, req.URL.String(), nil)
 		if e != nil {
 			return nil, e
 		}
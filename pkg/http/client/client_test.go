@@ -3,11 +3,13 @@ package client_test
 import (
 	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/pinkgorilla/go-sample/pkg/circuit"
 	client "github.com/pinkgorilla/go-sample/pkg/http/client"
 )
 
@@ -150,6 +152,132 @@ func TestPost_3Attempts_ResponseOK_ShouldSuccess(t *testing.T) {
 	assertEqual(t, res.StatusCode, http.StatusOK)
 }
 
+func TestGet_CircuitBreaker_ShouldShortCircuit(t *testing.T) {
+	r := newResponse(10, nil, http.StatusOK, http.StatusServiceUnavailable, 0)
+	svr := httptest.NewServer(r.handler())
+	defer svr.Close()
+
+	breakers := circuit.NewRegistry(func(name string) *circuit.CircuitBreaker {
+		return circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+			Name:              name,
+			InvocationTimeout: time.Second,
+			ResetTimeout:      time.Minute,
+			Strategy:          circuit.ConsecutiveFailures(2),
+		})
+	})
+	cfg := client.DefaultClientConfig()
+	cfg.MaxRequestAttempt = 1
+	cfg.Breakers = breakers
+	c := client.NewClient(cfg)
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", svr.URL, nil)
+		assertNil(t, err)
+		res, err := c.Do(req)
+		assertNil(t, err)
+		assertEqual(t, res.StatusCode, http.StatusServiceUnavailable)
+	}
+	req, err := http.NewRequest("GET", svr.URL, nil)
+	assertNil(t, err)
+	_, err = c.Do(req)
+	assertEqual(t, err, client.ErrCircuitOpen)
+	assertEqual(t, r.attempt, 3)
+
+	cb, ok := breakers.Lookup(req.URL.Host)
+	assertEqual(t, ok, true)
+	assertEqual(t, cb.State(), circuit.State(circuit.StateOpen))
+}
+
+func TestGet_CircuitBreaker_Timeout_ShouldCancelRequest(t *testing.T) {
+	cancelled := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(time.Second):
+		}
+	}))
+	defer svr.Close()
+
+	breakers := circuit.NewRegistry(func(name string) *circuit.CircuitBreaker {
+		return circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+			Name:              name,
+			InvocationTimeout: 50 * time.Millisecond,
+			ResetTimeout:      time.Minute,
+			Strategy:          circuit.ConsecutiveFailures(2),
+		})
+	})
+	cfg := client.DefaultClientConfig()
+	cfg.MaxRequestAttempt = 1
+	cfg.Breakers = breakers
+	c := client.NewClient(cfg)
+
+	req, err := http.NewRequest("GET", svr.URL, nil)
+	assertNil(t, err)
+	res, err := c.Do(req)
+	assertEqual(t, err, circuit.ErrCircuitInvocationTimeoutExceeded)
+	if res != nil {
+		t.Fatal("expected no response on timeout")
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("expected request cancelled on timeout")
+	}
+}
+
+func TestGet_CircuitBreaker_ShouldReturnBody(t *testing.T) {
+	r := newResponse(1, "hello", http.StatusOK, http.StatusServiceUnavailable, 0)
+	svr := httptest.NewServer(r.handler())
+	defer svr.Close()
+
+	cfg := client.DefaultClientConfig()
+	cfg.Breakers = circuit.NewRegistry(nil)
+	c := client.NewClient(cfg)
+
+	req, err := http.NewRequest("GET", svr.URL, nil)
+	assertNil(t, err)
+	res, err := c.Do(req)
+	assertNil(t, err)
+	defer res.Body.Close()
+	var body string
+	assertNil(t, json.NewDecoder(res.Body).Decode(&body))
+	assertEqual(t, body, "hello")
+}
+
+func TestGet_CircuitBreaker_ShouldStreamBodyAfterInvocation(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		// body is written after invocation timeout
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("hello"))
+	}))
+	defer svr.Close()
+
+	cfg := client.DefaultClientConfig()
+	cfg.MaxRequestAttempt = 1
+	cfg.Breakers = circuit.NewRegistry(func(name string) *circuit.CircuitBreaker {
+		return circuit.NewCircuitBreakerWithConfig(&circuit.Config{
+			Name:              name,
+			InvocationTimeout: 50 * time.Millisecond,
+			Strategy:          circuit.ConsecutiveFailures(1),
+		})
+	})
+	c := client.NewClient(cfg)
+
+	req, err := http.NewRequest("GET", svr.URL, nil)
+	assertNil(t, err)
+	res, err := c.Do(req)
+	assertNil(t, err)
+	defer res.Body.Close()
+	bs, err := ioutil.ReadAll(res.Body)
+	assertNil(t, err)
+	assertEqual(t, string(bs), "hello")
+	cb, _ := cfg.Breakers.Lookup(req.URL.Host)
+	assertEqual(t, cb.State(), circuit.State(circuit.StateClosed))
+}
+
 func assertNil(t *testing.T, data interface{}) {
 	if data != nil {
 		t.Fatalf("expected nil, got: %v", data)
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+)
+
+// ProblemContentType is content type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// ErrorMapping describes how error of registered type is written
+type ErrorMapping struct {
+	// Status is http status code of the response
+	Status int
+	// Type is URI reference identifying problem type, about:blank when empty
+	Type string
+	// Title is short summary of problem type, status text when empty
+	Title string
+}
+
+// ErrorBody is error written in response
+type ErrorBody struct {
+	Code    string                        `json:"code"`
+	Message string                        `json:"message"`
+	Fields  []errors.ValidationErrorField `json:"fields,omitempty"`
+}
+
+// ErrorEnvelope is JSON envelope of error responses
+type ErrorEnvelope struct {
+	Error ErrorBody `json:"error"`
+}
+
+// Problem is RFC 7807 problem details, code and fields are extension members
+type Problem struct {
+	Type     string                        `json:"type"`
+	Title    string                        `json:"title"`
+	Status   int                           `json:"status"`
+	Detail   string                        `json:"detail,omitempty"`
+	Instance string                        `json:"instance,omitempty"`
+	Code     string                        `json:"code,omitempty"`
+	Fields   []errors.ValidationErrorField `json:"fields,omitempty"`
+}
+
+type errorEntry struct {
+	typ     reflect.Type
+	mapping ErrorMapping
+}
+
+var (
+	errorRegistryMu sync.RWMutex
+	errorRegistry   []errorEntry
+)
+
+func init() {
+	RegisterError(errors.BaseError{}, ErrorMapping{Status: http.StatusBadRequest})
+	RegisterError(errors.CommonError{}, ErrorMapping{Status: http.StatusBadRequest})
+	RegisterError(errors.ValidationError{}, ErrorMapping{Status: http.StatusUnprocessableEntity})
+	RegisterError(errors.AuthError{}, ErrorMapping{Status: http.StatusUnauthorized})
+	RegisterError(errors.PermissionError{}, ErrorMapping{Status: http.StatusForbidden})
+	RegisterError(errors.ForbiddenError{}, ErrorMapping{Status: http.StatusForbidden})
+	RegisterError(errors.NotFoundError{}, ErrorMapping{Status: http.StatusNotFound})
+	RegisterError(errors.ServiceError{}, ErrorMapping{Status: http.StatusServiceUnavailable})
+}
+
+// RegisterError registers mapping of errors with the same type as target,
+// pointers to the type are matched as well, interface type is registered using pointer to nil interface.
+// Registering type again replaces its mapping, panics when target is not an error
+func RegisterError(target interface{}, mapping ErrorMapping) {
+	typ := reflect.TypeOf(target)
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	if typ != nil && typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Interface {
+		typ = typ.Elem()
+	}
+	if typ == nil || !typ.Implements(errorType) {
+		panic(fmt.Sprintf("server: %v is not an error type", typ))
+	}
+
+	errorRegistryMu.Lock()
+	defer errorRegistryMu.Unlock()
+	for i, e := range errorRegistry {
+		if e.typ == typ {
+			errorRegistry[i].mapping = mapping
+			return
+		}
+	}
+	errorRegistry = append(errorRegistry, errorEntry{typ: typ, mapping: mapping})
+}
+
+// lookupError returns outermost error in chain of err with registered mapping
+func lookupError(err error) (error, ErrorMapping, bool) {
+	errorRegistryMu.RLock()
+	defer errorRegistryMu.RUnlock()
+	return lookup(err)
+}
+
+// lookup returns outermost error in chain of err with registered mapping,
+// error wrapped using errors.Wrap is looked up before its cause
+func lookup(err error) (error, ErrorMapping, bool) {
+	for e := err; e != nil; e = goerrors.Unwrap(e) {
+		if w, ok := e.(interface{ Err() error }); ok {
+			if found, mapping, ok := lookup(w.Err()); ok {
+				return found, mapping, true
+			}
+		}
+		typ := reflect.TypeOf(e)
+		// later registrations take precedence
+		for i := len(errorRegistry) - 1; i >= 0; i-- {
+			entry := errorRegistry[i]
+			if typ == entry.typ ||
+				(typ.Kind() == reflect.Ptr && typ.Elem() == entry.typ) ||
+				(entry.typ.Kind() == reflect.Interface && typ.Implements(entry.typ)) {
+				return e, entry.mapping, true
+			}
+		}
+	}
+	return nil, ErrorMapping{}, false
+}
+
+// StatusOf returns http status code of err, 500 when err has no registered mapping
+func StatusOf(err error) int {
+	_, mapping, ok := lookupError(err)
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	return mapping.Status
+}
+
+// ErrorBodyOf returns error body of err, code and message are taken from errors embedding errors.BaseError,
+// unmapped errors are reported as internal error without exposing their message
+func ErrorBodyOf(err error) ErrorBody {
+	e, _, ok := lookupError(err)
+	if !ok {
+		return ErrorBody{Code: "InternalError", Message: http.StatusText(http.StatusInternalServerError)}
+	}
+	body := ErrorBody{Message: e.Error()}
+	if b, ok := e.(interface{ Base() errors.BaseError }); ok {
+		base := b.Base()
+		body.Code, body.Message = base.Code, base.Message
+	}
+	switch v := e.(type) {
+	case errors.ValidationError:
+		body.Fields = v.Fields
+	case *errors.ValidationError:
+		body.Fields = v.Fields
+	}
+	return body
+}
+
+// ProblemOf returns RFC 7807 problem details of err
+func ProblemOf(err error) Problem {
+	_, mapping, ok := lookupError(err)
+	if !ok {
+		mapping = ErrorMapping{Status: http.StatusInternalServerError}
+	}
+	body := ErrorBodyOf(err)
+	p := Problem{
+		Type:   mapping.Type,
+		Title:  mapping.Title,
+		Status: mapping.Status,
+		Detail: body.Message,
+		Code:   body.Code,
+		Fields: body.Fields,
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	return p
+}
+
+// WriteError writes err with status of its registered mapping,
+// as RFC 7807 problem details when request accepts application/problem+json, as ErrorEnvelope otherwise
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusOf(err)
+	if status >= http.StatusInternalServerError {
+		log.Println("server:", r.Method, r.URL.Path, err)
+	}
+	if strings.Contains(r.Header.Get("Accept"), ProblemContentType) {
+		p := ProblemOf(err)
+		p.Instance = r.URL.Path
+		w.Header().Set("Content-Type", ProblemContentType)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(p)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorEnvelope{Error: ErrorBodyOf(err)})
+}
@@ -0,0 +1,116 @@
+package server_test
+
+import (
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+	"github.com/pinkgorilla/go-sample/pkg/http/server"
+)
+
+type conflictError struct {
+	errors.BaseError
+}
+
+type rateLimited interface {
+	error
+	RetryAfter() int
+}
+
+type throttled struct{}
+
+func (throttled) Error() string   { return "too many requests" }
+func (throttled) RetryAfter() int { return 1 }
+
+func Test_StatusOf(t *testing.T) {
+	server.RegisterError(conflictError{}, server.ErrorMapping{Status: http.StatusConflict, Type: "https://example.com/conflict"})
+	server.RegisterError((*rateLimited)(nil), server.ErrorMapping{Status: http.StatusTooManyRequests})
+
+	validation := errors.NewValidationError("invalid")
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{validation, http.StatusUnprocessableEntity},
+		{&validation, http.StatusUnprocessableEntity},
+		{errors.NewAuthError("unauthorized"), http.StatusUnauthorized},
+		{errors.NewPermissionError("denied"), http.StatusForbidden},
+		{errors.NewForbiddenError("forbidden"), http.StatusForbidden},
+		{errors.NewNotFoundError("not found"), http.StatusNotFound},
+		{errors.NewServiceError("unavailable"), http.StatusServiceUnavailable},
+		{errors.NewCommonError("bad"), http.StatusBadRequest},
+		{fmt.Errorf("handler: %w", errors.NewNotFoundError("not found")), http.StatusNotFound},
+		{errors.Wrap(errors.NewServiceError("failed"), errors.NewNotFoundError("not found")), http.StatusServiceUnavailable},
+		{conflictError{errors.NewBaseError("Conflict", "exists")}, http.StatusConflict},
+		{throttled{}, http.StatusTooManyRequests},
+		{io.EOF, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		if s := server.StatusOf(c.err); s != c.status {
+			t.Fatalf("expected status %d of %v, got %d", c.status, c.err, s)
+		}
+	}
+}
+
+func Test_WriteError(t *testing.T) {
+	validation := errors.NewValidationError("invalid payload")
+	validation.FieldRequired("name")
+	err := errors.Wrap(validation, goerrors.New("internal detail"))
+
+	w := httptest.NewRecorder()
+	server.WriteError(w, httptest.NewRequest("POST", "/users", nil), fmt.Errorf("create: %w", err))
+	if w.Code != http.StatusUnprocessableEntity || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	expected := `{"error":{"code":"ValidationError","message":"invalid payload","fields":[{"field":"name","message":"field is required"}]}}` + "\n"
+	if w.Body.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	server.WriteError(w, httptest.NewRequest("GET", "/users", nil), goerrors.New("dial tcp: connection refused"))
+	var envelope server.ErrorEnvelope
+	json.NewDecoder(w.Body).Decode(&envelope)
+	if w.Code != http.StatusInternalServerError || envelope.Error.Code != "InternalError" || envelope.Error.Message != "Internal Server Error" {
+		t.Fatalf("expected unmapped error hidden, got %d %+v", w.Code, envelope)
+	}
+}
+
+func Test_WriteError_Problem(t *testing.T) {
+	server.RegisterError(conflictError{}, server.ErrorMapping{Status: http.StatusConflict, Type: "https://example.com/conflict", Title: "Conflict"})
+
+	r := httptest.NewRequest("PUT", "/users/1", nil)
+	r.Header.Set("Accept", "application/problem+json, application/json")
+	w := httptest.NewRecorder()
+	server.WriteError(w, r, conflictError{errors.NewBaseError("UserExists", "user already exists")})
+	if w.Code != http.StatusConflict || w.Header().Get("Content-Type") != server.ProblemContentType {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var p server.Problem
+	json.NewDecoder(w.Body).Decode(&p)
+	expected := server.Problem{Type: "https://example.com/conflict", Title: "Conflict", Status: 409, Detail: "user already exists", Instance: "/users/1", Code: "UserExists"}
+	if fmt.Sprint(p) != fmt.Sprint(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, p)
+	}
+
+	w = httptest.NewRecorder()
+	server.WriteError(w, r, errors.NewNotFoundError("user not found"))
+	json.NewDecoder(w.Body).Decode(&p)
+	if p.Type != "about:blank" || p.Title != "Not Found" || p.Status != 404 || p.Detail != "user not found" {
+		t.Fatalf("expected default problem type, got %+v", p)
+	}
+}
+
+func Test_RegisterError_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic registering non error type")
+		}
+	}()
+	server.RegisterError("not an error", server.ErrorMapping{Status: http.StatusTeapot})
+}
@@ -6,12 +6,42 @@ import (
 	"strings"
 
 	"github.com/go-playground/form"
+	"github.com/pinkgorilla/go-sample/pkg/validation"
 )
 
 var formDecoder = form.NewDecoder()
 
-// ParsePayload ...
-func ParsePayload(r *http.Request, payload interface{}) error {
+// PayloadOption configures ParsePayload
+type PayloadOption func(*payloadOptions)
+
+type payloadOptions struct {
+	validate bool
+}
+
+// WithValidation validates parsed payload by its validate struct tags,
+// invalid payload results in errors.ValidationError, see validation.Validate
+func WithValidation() PayloadOption {
+	return func(o *payloadOptions) {
+		o.validate = true
+	}
+}
+
+// ParsePayload decodes json or form request payload, validated when WithValidation is given
+func ParsePayload(r *http.Request, payload interface{}, options ...PayloadOption) error {
+	o := &payloadOptions{}
+	for _, option := range options {
+		option(o)
+	}
+	if err := decodePayload(r, payload); err != nil {
+		return err
+	}
+	if o.validate {
+		return validation.Validate(payload)
+	}
+	return nil
+}
+
+func decodePayload(r *http.Request, payload interface{}) error {
 	contents := r.Header.Get("Content-Type")
 	if strings.Index(contents, "application/json") == 0 {
 		return json.NewDecoder(r.Body).Decode(&payload)
@@ -0,0 +1,43 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+	"github.com/pinkgorilla/go-sample/pkg/http/server"
+)
+
+type signup struct {
+	Email string `json:"email" form:"email" validate:"required,email"`
+	Age   int    `json:"age" form:"age" validate:"min=18"`
+}
+
+func Test_ParsePayload_Validation(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"email":"john","age":17}`))
+	r.Header.Set("Content-Type", "application/json")
+	var p signup
+	if err := server.ParsePayload(r, &p); err != nil || p.Email != "john" || p.Age != 17 {
+		t.Fatalf("expected payload parsed without validation, got %+v %v", p, err)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"email":"john","age":17}`))
+	r.Header.Set("Content-Type", "application/json")
+	err := server.ParsePayload(r, &p, server.WithValidation())
+	e, ok := err.(errors.ValidationError)
+	if !ok || !e.HasFieldError("email") || !e.HasFieldError("age") {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if server.StatusOf(err) != http.StatusUnprocessableEntity {
+		t.Fatal("expected validation error written as 422")
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader("email=john@example.com&age=20"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	p = signup{}
+	if err := server.ParsePayload(r, &p, server.WithValidation()); err != nil || p.Email != "john@example.com" {
+		t.Fatalf("expected valid form payload, got %+v %v", p, err)
+	}
+}
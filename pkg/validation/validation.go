@@ -0,0 +1,316 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+)
+
+// TagName is name of struct tag containing validation rules
+const TagName = "validate"
+
+type rule struct {
+	name  string
+	param string
+}
+
+var patterns sync.Map
+
+// Validate validates v by validate struct tags of its fields, e.g.
+//
+//	Name  string   `json:"name" validate:"required,min=3,max=50"`
+//	Email string   `json:"email" validate:"omitempty,email"`
+//	Role  string   `json:"role" validate:"oneof=admin member"`
+//	Code  string   `json:"code" validate:"len=6,regexp=^[0-9]+$"`
+//	Tags  []string `json:"tags" validate:"max=5,dive,required"`
+//
+// rules before dive apply to slice, array or map itself, rules after dive apply to its elements.
+// regexp must be the last rule as its pattern may contain commas.
+// Nested structs are validated recursively, structs in slices are validated with dive.
+//
+// Returned error is errors.ValidationError with failing fields named by their json names, e.g. items.0.name,
+// other errors indicate invalid rules
+func Validate(v interface{}) error {
+	e := errors.NewValidationError("validation failed")
+	if err := validateValue("", reflect.ValueOf(v), nil, &e); err != nil {
+		return err
+	}
+	if e.HasFieldErrors() {
+		return e
+	}
+	return nil
+}
+
+func validateStruct(path string, v reflect.Value, e *errors.ValidationError) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get(TagName)
+		if tag == "-" {
+			continue
+		}
+		name, named := fieldName(f)
+		if f.Anonymous && !named {
+			// embedded struct fields are promoted the same as in json, including exported fields of unexported structs
+			fv := indirect(v.Field(i))
+			if fv.Kind() == reflect.Struct {
+				if err := validateStruct(path, fv, e); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		rules, err := parseRules(tag)
+		if err != nil {
+			return fmt.Errorf("validation: field %s: %v", f.Name, err)
+		}
+		if err := validateValue(join(path, name), v.Field(i), rules, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(path string, v reflect.Value, rules []rule, e *errors.ValidationError) error {
+	for i, r := range rules {
+		switch r.name {
+		case "required":
+			if isEmpty(v) {
+				e.FieldRequired(pathName(path))
+				return nil
+			}
+			continue
+		case "omitempty":
+			if isEmpty(v) {
+				return nil
+			}
+			continue
+		}
+
+		v := indirect(v)
+		if !v.IsValid() {
+			return nil
+		}
+		if r.name == "dive" {
+			return dive(path, v, rules[i+1:], e)
+		}
+		msg, err := check(r, v)
+		if err != nil {
+			return fmt.Errorf("validation: %s: %v", pathName(path), err)
+		}
+		if msg != "" {
+			e.FieldError(pathName(path), msg)
+			return nil
+		}
+	}
+	if v := indirect(v); v.IsValid() && v.Kind() == reflect.Struct {
+		return validateStruct(path, v, e)
+	}
+	return nil
+}
+
+func dive(path string, v reflect.Value, rules []rule, e *errors.ValidationError) error {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(join(path, strconv.Itoa(i)), v.Index(i), rules, e); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		for _, k := range keys {
+			if err := validateValue(join(path, fmt.Sprint(k)), v.MapIndex(k), rules, e); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("validation: %s: dive is not applicable to %s", pathName(path), v.Kind())
+	}
+	return nil
+}
+
+// check returns message of failed rule, empty when v satisfies rule
+func check(r rule, v reflect.Value) (string, error) {
+	switch r.name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(r.param, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s parameter '%s'", r.name, r.param)
+		}
+		var value float64
+		format := "must be at %s %s"
+		if n, ok := length(v); ok {
+			value = float64(n)
+			format = "length must be at %s %s"
+		} else if value, ok = number(v); !ok {
+			return "", fmt.Errorf("%s is not applicable to %s", r.name, v.Kind())
+		}
+		if r.name == "min" && value < limit {
+			return fmt.Sprintf(format, "least", r.param), nil
+		}
+		if r.name == "max" && value > limit {
+			return fmt.Sprintf(format, "most", r.param), nil
+		}
+	case "len":
+		expected, err := strconv.Atoi(r.param)
+		if err != nil {
+			return "", fmt.Errorf("invalid len parameter '%s'", r.param)
+		}
+		n, ok := length(v)
+		if !ok {
+			return "", fmt.Errorf("len is not applicable to %s", v.Kind())
+		}
+		if n != expected {
+			return fmt.Sprintf("length must be %d", expected), nil
+		}
+	case "email":
+		if v.Kind() != reflect.String {
+			return "", fmt.Errorf("email is not applicable to %s", v.Kind())
+		}
+		addr, err := mail.ParseAddress(v.String())
+		if err != nil || addr.Name != "" || addr.Address != v.String() {
+			return "must be a valid email address", nil
+		}
+	case "regexp":
+		if v.Kind() != reflect.String {
+			return "", fmt.Errorf("regexp is not applicable to %s", v.Kind())
+		}
+		re, err := pattern(r.param)
+		if err != nil {
+			return "", err
+		}
+		if !re.MatchString(v.String()) {
+			return fmt.Sprintf("must match pattern %s", r.param), nil
+		}
+	case "oneof":
+		values := strings.Fields(r.param)
+		s := fmt.Sprint(v)
+		for _, value := range values {
+			if value == s {
+				return "", nil
+			}
+		}
+		return fmt.Sprintf("must be one of %s", strings.Join(values, ", ")), nil
+	default:
+		return "", fmt.Errorf("unknown rule '%s'", r.name)
+	}
+	return "", nil
+}
+
+func parseRules(tag string) ([]rule, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	rules := []rule{}
+	parts := strings.Split(tag, ",")
+	for i, p := range parts {
+		r := rule{name: p}
+		if j := strings.Index(p, "="); j >= 0 {
+			r.name, r.param = p[:j], p[j+1:]
+		}
+		if r.name == "regexp" {
+			r.param = strings.Join(append([]string{r.param}, parts[i+1:]...), ",")
+			if _, err := pattern(r.param); err != nil {
+				return nil, err
+			}
+			return append(rules, r), nil
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func pattern(expr string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexp '%s': %v", expr, err)
+	}
+	patterns.Store(expr, re)
+	return re, nil
+}
+
+// fieldName returns json name of field, false when name is not given by json tag
+func fieldName(f reflect.StructField) (string, bool) {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return f.Name, false
+	}
+	return name, true
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func isEmpty(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func length(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func pathName(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return path
+}
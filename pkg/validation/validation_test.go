@@ -0,0 +1,147 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/pinkgorilla/go-sample/pkg/errors"
+	"github.com/pinkgorilla/go-sample/pkg/validation"
+)
+
+type address struct {
+	City   string `json:"city" validate:"required"`
+	Postal string `json:"postal" validate:"len=5,regexp=^[0-9]{5}$"`
+}
+
+type item struct {
+	Name     string `json:"name" validate:"required,max=10"`
+	Quantity int    `json:"quantity" validate:"min=1,max=99"`
+}
+
+type Audit struct {
+	CreatedBy string `json:"created_by" validate:"required"`
+}
+
+type tracking struct {
+	Channel string `json:"channel" validate:"oneof=web app"`
+}
+
+type order struct {
+	Audit
+	tracking
+	Email    string            `json:"email" validate:"required,email"`
+	Backup   string            `json:"backup_email" validate:"omitempty,email"`
+	Status   string            `json:"status" validate:"oneof=draft paid shipped"`
+	Priority int               `json:"priority" validate:"oneof=1 2 3"`
+	Discount *float64          `json:"discount" validate:"omitempty,min=0,max=0.5"`
+	Address  address           `json:"address"`
+	Billing  *address          `json:"billing"`
+	Items    []item            `json:"items" validate:"required,max=3,dive"`
+	Tags     []string          `json:"tags" validate:"dive,min=2"`
+	Labels   map[string]string `json:"labels" validate:"dive,oneof=a b"`
+	Note     string            `validate:"max=5"`
+	internal string            `validate:"required"`
+}
+
+func validOrder() order {
+	return order{
+		Audit:    Audit{CreatedBy: "system"},
+		tracking: tracking{Channel: "web"},
+		Email:    "john@example.com",
+		Status:   "paid",
+		Priority: 1,
+		Address:  address{City: "Jakarta", Postal: "12345"},
+		Items:    []item{{Name: "book", Quantity: 1}},
+		Tags:     []string{"gift"},
+		Labels:   map[string]string{"x": "a"},
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	o := validOrder()
+	if err := validation.Validate(&o); err != nil {
+		t.Fatalf("expected valid order, got %+v", err)
+	}
+
+	discount := 0.75
+	o = order{
+		Email:    "John <john@example.com>",
+		Backup:   "",
+		Status:   "cancelled",
+		Priority: 4,
+		Discount: &discount,
+		Address:  address{Postal: "1234a"},
+		Billing:  &address{City: "Bandung", Postal: "123"},
+		Items:    []item{{Name: "book", Quantity: 1}, {Name: "a very long name", Quantity: 0}},
+		Tags:     []string{"a", "gift"},
+		Labels:   map[string]string{"y": "c", "x": "a"},
+		Note:     "too long",
+	}
+	err := validation.Validate(o)
+	e, ok := err.(errors.ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	expected := map[string]string{
+		"created_by":       "field is required",
+		"channel":          "must be one of web, app",
+		"email":            "must be a valid email address",
+		"status":           "must be one of draft, paid, shipped",
+		"priority":         "must be one of 1, 2, 3",
+		"discount":         "must be at most 0.5",
+		"address.city":     "field is required",
+		"address.postal":   "must match pattern ^[0-9]{5}$",
+		"billing.postal":   "length must be 5",
+		"items.1.name":     "length must be at most 10",
+		"items.1.quantity": "must be at least 1",
+		"tags.0":           "length must be at least 2",
+		"labels.y":         "must be one of a, b",
+		"Note":             "length must be at most 5",
+	}
+	for field, message := range expected {
+		f := e.GetFieldError(field)
+		if f == nil || f.Message != message {
+			t.Fatalf("expected %s: %s, got %+v", field, message, e.Fields)
+		}
+	}
+	if len(e.Fields) != len(expected) {
+		t.Fatalf("expected %d field errors, got %+v", len(expected), e.Fields)
+	}
+
+	o = validOrder()
+	o.Items = nil
+	err = validation.Validate(o)
+	if e, ok := err.(errors.ValidationError); !ok || !e.HasFieldError("items") {
+		t.Fatalf("expected items required, got %v", err)
+	}
+	o.Items = make([]item, 4)
+	err = validation.Validate(o)
+	if e, ok := err.(errors.ValidationError); !ok || e.GetFieldError("items").Message != "length must be at most 3" || e.HasFieldError("items.0.name") {
+		t.Fatalf("expected items length checked before elements, got %v", err)
+	}
+}
+
+func Test_Validate_InvalidRule(t *testing.T) {
+	cases := []interface{}{
+		struct {
+			Name string `validate:"unknown"`
+		}{"x"},
+		struct {
+			Name string `validate:"min=x"`
+		}{"x"},
+		struct {
+			Name string `validate:"regexp=[a-"`
+		}{"x"},
+		struct {
+			Name string `validate:"dive"`
+		}{"x"},
+		struct {
+			Active bool `validate:"min=1"`
+		}{true},
+	}
+	for _, c := range cases {
+		err := validation.Validate(c)
+		if _, ok := err.(errors.ValidationError); err == nil || ok {
+			t.Fatalf("expected rule error for %+v, got %v", c, err)
+		}
+	}
+}